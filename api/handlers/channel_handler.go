@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"github.com/aramceballos/chat-group-server/pkg/channel"
+	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ChannelDeleted is broadcast to the members of a deleted channel
+type ChannelDeleted struct {
+	ChannelID int `json:"channel_id"`
+}
+
+// channelErrorStatus maps a channel service error to its response status
+func channelErrorStatus(err error) int {
+	switch err {
+	case channel.ErrChannelNotFound, channel.ErrMemberNotFound, channel.ErrUserNotFound, channel.ErrInviteNotFound, channel.ErrNotModerated:
+		return fiber.StatusNotFound
+	case channel.ErrInviteExpired, channel.ErrInviteUsedUp:
+		return fiber.StatusGone
+	case channel.ErrNotMember, channel.ErrNotAllowed, channel.ErrDirectMessage, channel.ErrBanned, channel.ErrSelfModeration:
+		return fiber.StatusForbidden
+	case channel.ErrChannelArchived, channel.ErrAlreadyMember, channel.ErrOwnerCannotLeave:
+		return fiber.StatusConflict
+	case channel.ErrNothingToUpdate, channel.ErrInvalidDuration:
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
+
+func CreateChannel(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var input entities.CreateChannelInput
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		// Validate input
+		validate := validator.New()
+		if err := validate.Struct(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		created, err := service.CreateChannel(currentUserId(c), input)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"status":  "success",
+			"message": "channel created",
+			"data":    created,
+		})
+	}
+}
+
+func GetChannels(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channels, err := service.FetchUserChannels(currentUserId(c))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "channels retrieved",
+			"data":    channels,
+		})
+	}
+}
+
+func GetChannel(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		found, err := service.FetchChannel(channelId, currentUserId(c))
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "channel retrieved",
+			"data":    found,
+		})
+	}
+}
+
+// UpdateChannel renames or describes a channel
+func UpdateChannel(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		var input entities.UpdateChannelInput
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		// Validate input
+		validate := validator.New()
+		if err := validate.Struct(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		updated, err := service.UpdateChannel(channelId, currentUserId(c), input)
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		channelsHub.BroadcastEvent(int64(channelId), EventChannelUpdated, updated)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "channel updated",
+			"data":    updated,
+		})
+	}
+}
+
+func ArchiveChannel(service channel.Service) fiber.Handler {
+	return setArchived(service, true)
+}
+
+func UnarchiveChannel(service channel.Service) fiber.Handler {
+	return setArchived(service, false)
+}
+
+func setArchived(service channel.Service, archived bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		var updated entities.Channel
+		message := "channel archived"
+		if archived {
+			updated, err = service.ArchiveChannel(channelId, currentUserId(c))
+		} else {
+			updated, err = service.UnarchiveChannel(channelId, currentUserId(c))
+			message = "channel unarchived"
+		}
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		channelsHub.BroadcastEvent(int64(channelId), EventChannelUpdated, updated)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": message,
+			"data":    updated,
+		})
+	}
+}
+
+func DeleteChannel(service channel.Service, chatService chat.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		if err := service.DeleteChannel(channelId, currentUserId(c)); err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		channelsHub.BroadcastEvent(int64(channelId), EventChannelDeleted, ChannelDeleted{ChannelID: channelId})
+		channelsHub.DisconnectAll(int64(channelId))
+		chatService.ForgetFilters(channelId)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "channel deleted",
+			"data":    nil,
+		})
+	}
+}
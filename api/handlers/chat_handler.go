@@ -5,9 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"strconv"
-	"sync"
 	"time"
 
 	"github.com/aramceballos/chat-group-server/pkg/chat"
@@ -19,66 +19,43 @@ import (
 
 const MaxMessageLength = 10 * 1024 // 10KB max raw message length
 
+// Result answers a frame. Sends echo the frame's client_msg_id and, once
+// persisted, the id of the stored message.
 type Result struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
+	ChannelID   int64  `json:"channel_id,omitempty"`
+	ClientMsgID string `json:"client_msg_id,omitempty"`
+	MessageID   int    `json:"message_id,omitempty"`
+	Success     bool   `json:"success"`
+	Message     string `json:"message"`
 }
 
-type Message struct {
-	Body json.RawMessage `json:"body"`
+// Event is broadcast for anything that is not a new message, so clients can
+// patch their view in place
+type Event struct {
+	Type      string      `json:"type"`
+	ChannelID int64       `json:"channel_id"`
+	Data      interface{} `json:"data"`
 }
 
-type Client struct {
-	conn         *websocket.Conn
-	send         chan interface{}
-	quit         chan struct{}
-	once         sync.Once
-	userId       int
-	channelId    int
-	failureCount int
-	lastFailure  time.Time
-}
-
-func NewClient(conn *websocket.Conn, userId int, channelId int) *Client {
-	return &Client{
-		conn:         conn,
-		send:         make(chan interface{}, 256),
-		quit:         make(chan struct{}),
-		userId:       userId,
-		channelId:    channelId,
-		failureCount: 0,
-		lastFailure:  time.Now(),
-	}
-}
-
-func validateMessageBody(body map[string]interface{}) error {
-	msgType, ok := body["type"].(string)
-	if !ok {
-		return errors.New("Message body must have a 'type' field")
-	}
-
-	switch msgType {
-	case "text":
-		content, ok := body["content"].(string)
-		if !ok || len(content) == 0 {
-			return errors.New("text messages must have a non-empty 'content' field")
-		}
-	case "file":
-		// Validate file fields
-		fileID, fileIDOk := body["file_id"].(string)
-		filename, filenameOk := body["filename"].(string)
-		mimeType, mimeTypeOk := body["mime_type"].(string)
-		url, urlOk := body["url"].(string)
-		_, sizeOk := body["size_in_bytes"]
-		if !fileIDOk || fileID == "" || !filenameOk || filename == "" || !mimeTypeOk || mimeType == "" || !urlOk || url == "" || !sizeOk {
-			return errors.New("file messages must have non-empty 'file_id', 'filename', 'mime_type', 'url', and 'size_in_bytes' fields")
-		}
-	default:
-		return errors.New("unsupported message type")
-	}
-
-	return nil
-}
+const (
+	EventMessageUpdated    = "message_updated"
+	EventMessageDeleted    = "message_deleted"
+	EventReactionAdded     = "reaction_added"
+	EventReactionRemoved   = "reaction_removed"
+	EventTyping            = "typing"
+	EventPresence          = "presence"
+	EventRead              = "read"
+	EventMessagePinned     = "message_pinned"
+	EventMessageUnpinned   = "message_unpinned"
+	EventPollUpdated       = "poll_updated"
+	EventChannelUpdated    = "channel_updated"
+	EventChannelDeleted    = "channel_deleted"
+	EventMemberJoined      = "member_joined"
+	EventMemberUpdated     = "member_updated"
+	EventMemberRemoved     = "member_removed"
+	EventMemberModerated   = "member_moderated"
+	EventModerationRevoked = "moderation_revoked"
+)
 
 func validateToken(token string) (int, error) {
 	// Parse the token
@@ -115,109 +92,101 @@ func sendWSError(conn *websocket.Conn, message string) {
 		Success: false,
 		Message: message,
 	}
-	err := conn.WriteJSON(errorMessage)
+	err := newWSWriter(conn).writeFrame(errorMessage)
 	if err != nil {
 		log.Printf("write error [error response]: %v", err)
 	}
 }
 
-func (c *Client) writePump() {
-	defer func() {
-		c.conn.Close()
-	}()
+// authenticate validates the token query parameter of a websocket connection
+func authenticate(conn *websocket.Conn) (int, bool) {
+	token := conn.Query("token")
+	if token == "" {
+		sendWSError(conn, "Token is required")
+		return 0, false
+	}
 
-	for {
-		select {
-		case message := <-c.send:
-			if err := c.conn.WriteJSON(message); err != nil {
-				log.Printf("write error [broadcast]: %v", err)
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-		case <-c.quit:
-			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-			return
-		}
+	userId, err := validateToken(token)
+	if err != nil {
+		sendWSError(conn, err.Error())
+		return 0, false
 	}
+
+	return userId, true
 }
 
-func (c *Client) close() {
-	c.once.Do(func() {
-		close(c.quit)
+// readFrames runs the read loop of a connection until it is closed, handing
+// every well-formed frame to handle as JSON. Text frames are JSON and binary
+// frames MessagePack.
+func readFrames(conn *websocket.Conn, client *Client, handle func(body map[string]interface{}, raw json.RawMessage)) {
+	// Peers must answer pings within PongWait or the read below times out
+	conn.SetReadDeadline(time.Now().Add(PongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(PongWait))
 	})
-}
 
-type ChannelsHub struct {
-	channels   map[int64]map[*websocket.Conn]*Client
-	channelsMu sync.RWMutex
-}
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				reap(client, ReapMissedHeartbeat)
+			} else if websocket.IsCloseError(err,
+				websocket.CloseNormalClosure,
+				websocket.CloseGoingAway,
+				websocket.CloseNoStatusReceived) {
+				log.Printf("Client disconnected normally: %v", err)
+			} else if websocket.IsUnexpectedCloseError(err,
+				websocket.CloseNormalClosure,
+				websocket.CloseGoingAway,
+				websocket.CloseNoStatusReceived,
+			) {
+				log.Printf("Unexpected close error: %v", err)
+			} else {
+				log.Printf("read error: %v", err)
+			}
+			break
+		}
 
-func NewChannelsHub() *ChannelsHub {
-	return &ChannelsHub{
-		channels: make(map[int64]map[*websocket.Conn]*Client),
-	}
-}
+		// Check if message is empty
+		if len(data) == 0 {
+			continue
+		}
 
-func (ch *ChannelsHub) AddClient(channelId int64, conn *websocket.Conn, client *Client) {
-	ch.channelsMu.Lock()
-	defer ch.channelsMu.Unlock()
-	// Create client map for channel if doesn't exist
-	if _, ok := ch.channels[channelId]; !ok {
-		ch.channels[channelId] = make(map[*websocket.Conn]*Client)
-	}
-	ch.channels[channelId][conn] = client
-}
+		// Validate message does not exceed max length
+		if len(data) > MaxMessageLength {
+			client.reply(0, false, "Message size exceeds limit")
+			continue
+		}
 
-func (ch *ChannelsHub) RemoveClient(channelId int64, conn *websocket.Conn) {
-	ch.channelsMu.Lock()
-	defer ch.channelsMu.Unlock()
-	delete(ch.channels[channelId], conn)
-	// Clean up channel if empty
-	if len(ch.channels[channelId]) == 0 {
-		delete(ch.channels, channelId)
-		log.Printf("Channel %d cleaned up (empty channel)", channelId)
-	}
-}
+		raw, err := decodeFrame(messageType, data)
+		if err != nil {
+			client.reply(0, false, err.Error())
+			continue
+		}
+		// MessagePack frames are held to the limit once converted, as that is
+		// the form they are stored in
+		if len(raw) > MaxMessageLength {
+			client.reply(0, false, "Message size exceeds limit")
+			continue
+		}
 
-func (ch *ChannelsHub) BroadcastMessage(channelId int64, message entities.Message) {
-	ch.channelsMu.RLock()
-	clients := make([]*Client, 0, len(ch.channels[channelId]))
-	for _, client := range ch.channels[channelId] {
-		clients = append(clients, client)
-	}
-	ch.channelsMu.RUnlock()
-
-	for _, client := range clients {
-		select {
-		case client.send <- message:
-			client.failureCount = 0
-		default:
-			log.Printf("Message dropped for client %d on channel %d", client.userId, client.channelId)
-			client.failureCount++
-			client.lastFailure = time.Now()
-
-			if client.failureCount >= 5 {
-				log.Printf("Removed unresponsive client %d from channel %d", client.userId, client.channelId)
-				client.close()
-				ch.RemoveClient(channelId, client.conn)
-			}
+		// Validate message body JSON structure
+		var body map[string]interface{}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			client.reply(0, false, "Invalid message body JSON")
+			continue
 		}
+
+		handle(body, raw)
 	}
 }
 
-var channelsHub = NewChannelsHub()
-
+// ChatHandler serves a websocket bound to a single channel
 func ChatHandler(service chat.Service) fiber.Handler {
 	return websocket.New(func(conn *websocket.Conn) {
-		token := conn.Query("token")
-		if token == "" {
-			sendWSError(conn, "Token is required")
-			return
-		}
-
-		userId, err := validateToken(token)
-		if err != nil {
-			sendWSError(conn, err.Error())
+		userId, ok := authenticate(conn)
+		if !ok {
 			return
 		}
 
@@ -232,114 +201,44 @@ func ChatHandler(service chat.Service) fiber.Handler {
 		exists, err := service.CheckUserMembership(int(channelId), userId)
 		if err != nil || !exists {
 			log.Println("error checking membership:", err)
-			sendWSError(conn, "You are not a member of this channel")
+			sendWSError(conn, entities.ErrNotMember.Error())
 			return
 		}
 
+		var sinceId int
+		if since := conn.Query("since"); since != "" {
+			sinceId, err = strconv.Atoi(since)
+			if err != nil {
+				sendWSError(conn, "Invalid since message id")
+				return
+			}
+		}
+
 		// Create a new client
-		client := NewClient(conn, userId, int(channelId))
+		client := NewClient(conn, userId)
 
-		// Add client to the channel
-		channelsHub.AddClient(channelId, conn, client)
+		// Add client to the channel before replaying so nothing broadcast
+		// during the replay is missed
+		client.subscribe(channelsHub, channelId)
 
 		log.Printf("User %d joined channel %d from IP %s\n", userId, channelId, conn.RemoteAddr().String())
 
-		go client.writePump()
+		if sinceId > 0 {
+			client.requestReplay(channelId, sinceId)
+		}
+
+		go client.writePump(service)
 
 		// Remove client from the channel
 		defer func() {
 			log.Printf("User %d left channel %d from IP %s\n", userId, channelId, conn.RemoteAddr().String())
 			client.close()
 
-			channelsHub.RemoveClient(channelId, conn)
+			client.unsubscribe(channelsHub, channelId)
 		}()
 
-		for {
-			msg := Message{}
-			err := conn.ReadJSON(&msg.Body)
-			if err != nil {
-				if websocket.IsCloseError(err,
-					websocket.CloseNormalClosure,
-					websocket.CloseGoingAway,
-					websocket.CloseNoStatusReceived) {
-					log.Printf("Client disconnected normally: %v", err)
-					break
-				}
-				if websocket.IsUnexpectedCloseError(err,
-					websocket.CloseNormalClosure,
-					websocket.CloseGoingAway,
-					websocket.CloseNoStatusReceived,
-				) {
-					log.Printf("Unexpected close error: %v", err)
-					break
-				}
-				client.send <- Result{
-					Success: false,
-					Message: err.Error(),
-				}
-				continue
-			}
-
-			// Check if message is empty
-			if len(msg.Body) == 0 {
-				continue
-			}
-
-			// Validate message does not exceed max length
-			if len(msg.Body) > MaxMessageLength {
-				client.send <- Result{
-					Success: false,
-					Message: "Message size exceeds limit",
-				}
-				continue
-			}
-
-			// Validate message body JSON structure
-			var body map[string]interface{}
-			if err := json.Unmarshal(msg.Body, &body); err != nil {
-				client.send <- Result{
-					Success: false,
-					Message: "Invalid message body JSON",
-				}
-				continue
-			}
-
-			if err = validateMessageBody(body); err != nil {
-				client.send <- Result{
-					Success: false,
-					Message: err.Error(),
-				}
-				continue
-			}
-
-			// Insert message into database
-			insertedMessage, err := service.InsertMessage(int(channelId), userId, msg.Body)
-			if err != nil {
-				log.Println("error inserting message:", err)
-				client.send <- Result{
-					Success: false,
-					Message: err.Error(),
-				}
-				continue
-			}
-
-			// Query user from database to populate the message
-			user, err := service.FetchUserById(userId)
-			if err != nil {
-				client.send <- Result{
-					Success: false,
-					Message: err.Error(),
-				}
-				continue
-			}
-			insertedMessage.User = user
-
-			client.send <- Result{
-				Success: true,
-				Message: "Message sent successfully",
-			}
-
-			channelsHub.BroadcastMessage(channelId, insertedMessage)
-		}
-	})
+		readFrames(conn, client, func(body map[string]interface{}, raw json.RawMessage) {
+			processFrame(service, client, channelId, body, raw)
+		})
+	}, wsConfig)
 }
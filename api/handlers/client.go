@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/gofiber/contrib/websocket"
+)
+
+type replayRequest struct {
+	channelId int64
+	since     int
+}
+
+// replayedIds are the message ids replays of a channel wrote, up to last
+type replayedIds struct {
+	ids  map[int]struct{}
+	last int
+}
+
+// frameWriter encodes and writes one frame to the peer of a client
+type frameWriter interface {
+	writeFrame(message interface{}) error
+}
+
+// wsWriter writes frames to a websocket, as MessagePack when the client
+// negotiated it and as JSON otherwise
+type wsWriter struct {
+	conn   *websocket.Conn
+	binary bool
+}
+
+func newWSWriter(conn *websocket.Conn) wsWriter {
+	return wsWriter{
+		conn:   conn,
+		binary: conn.Subprotocol() == SubprotocolMsgpack,
+	}
+}
+
+func (w wsWriter) writeFrame(message interface{}) error {
+	w.conn.SetWriteDeadline(time.Now().Add(WriteWait))
+	if w.binary {
+		data, err := encodeMsgpack(message)
+		if err != nil {
+			return err
+		}
+		return w.conn.WriteMessage(websocket.BinaryMessage, data)
+	}
+
+	// Broadcasts are already encoded
+	if o, ok := message.(outbound); ok {
+		return w.conn.WriteMessage(websocket.TextMessage, o.payload)
+	}
+	return w.conn.WriteJSON(message)
+}
+
+// Client is a connection of a user, a websocket or an event stream. It may be
+// subscribed to several channels at once through ChannelsHub.
+type Client struct {
+	conn         *websocket.Conn
+	out          frameWriter
+	remoteAddr   string
+	send         chan interface{}
+	quit         chan struct{}
+	resume       chan replayRequest
+	once         sync.Once
+	userId       int
+	failureCount int
+	lastFailure  time.Time
+	// Channels the client is subscribed to. The hub evicts clients of
+	// removed members, so it is guarded by channelsMu.
+	channels   map[int64]struct{}
+	channelsMu sync.Mutex
+	// Multiplexed clients stay connected when evicted from a channel
+	multiplexed bool
+	// Ids written by replays of each channel, so live broadcasts queued
+	// meanwhile are not delivered twice. Only touched by writePump.
+	replayed map[int64]*replayedIds
+	// Frames queued while a replay is written. Only touched by writePump.
+	held []interface{}
+}
+
+func NewClient(conn *websocket.Conn, userId int) *Client {
+	client := newClient(userId, conn.RemoteAddr().String())
+	client.conn = conn
+	client.out = newWSWriter(conn)
+	return client
+}
+
+func newClient(userId int, remoteAddr string) *Client {
+	return &Client{
+		remoteAddr:   remoteAddr,
+		send:         make(chan interface{}, 256),
+		quit:         make(chan struct{}),
+		resume:       make(chan replayRequest, 1),
+		userId:       userId,
+		failureCount: 0,
+		lastFailure:  time.Now(),
+		channels:     make(map[int64]struct{}),
+		replayed:     make(map[int64]*replayedIds),
+	}
+}
+
+// subscribe adds the client to the channel in hub
+func (c *Client) subscribe(hub *ChannelsHub, channelId int64) {
+	c.channelsMu.Lock()
+	c.channels[channelId] = struct{}{}
+	c.channelsMu.Unlock()
+	hub.AddClient(channelId, c)
+}
+
+func (c *Client) unsubscribe(hub *ChannelsHub, channelId int64) {
+	c.channelsMu.Lock()
+	delete(c.channels, channelId)
+	c.channelsMu.Unlock()
+	hub.RemoveClient(channelId, c)
+}
+
+// unsubscribeAll removes the client from every channel it subscribed to
+func (c *Client) unsubscribeAll(hub *ChannelsHub) {
+	c.channelsMu.Lock()
+	channelIds := make([]int64, 0, len(c.channels))
+	for channelId := range c.channels {
+		channelIds = append(channelIds, channelId)
+	}
+	c.channelsMu.Unlock()
+
+	for _, channelId := range channelIds {
+		c.unsubscribe(hub, channelId)
+	}
+}
+
+func (c *Client) subscribed(channelId int64) bool {
+	c.channelsMu.Lock()
+	defer c.channelsMu.Unlock()
+	_, ok := c.channels[channelId]
+	return ok
+}
+
+// evict drops the client from a channel its user is no longer a member of.
+// Connections bound to that channel are closed.
+func (c *Client) evict(hub *ChannelsHub, channelId int64) {
+	c.unsubscribe(hub, channelId)
+	if !c.multiplexed {
+		c.close()
+	}
+}
+
+// reply queues a result frame answering one of the client's frames
+func (c *Client) reply(channelId int64, success bool, message string) {
+	c.send <- Result{
+		ChannelID: channelId,
+		Success:   success,
+		Message:   message,
+	}
+}
+
+func (c *Client) writePump(service chat.Service) {
+	ticker := time.NewTicker(PingInterval)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		// A pending replay goes first, so broadcasts already queued in send
+		// are not written ahead of the messages they follow
+		select {
+		case request := <-c.resume:
+			if !c.replayOrClose(service, request) {
+				return
+			}
+			continue
+		default:
+		}
+
+		select {
+		case request := <-c.resume:
+			if !c.replayOrClose(service, request) {
+				return
+			}
+		case message := <-c.send:
+			if c.alreadyReplayed(message) {
+				continue
+			}
+			if err := c.write(message); err != nil {
+				log.Printf("write error [broadcast]: %v", err)
+				reap(c, ReapWriteFailed)
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(WriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				log.Printf("write error [ping]: %v", err)
+				reap(c, ReapWriteFailed)
+				return
+			}
+		case <-c.quit:
+			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+		}
+	}
+}
+
+// replayOrClose runs a replay for writePump, closing the socket if it fails
+func (c *Client) replayOrClose(service chat.Service, request replayRequest) bool {
+	if err := c.replay(service, request); err != nil {
+		log.Printf("write error [replay]: %v", err)
+		reap(c, ReapWriteFailed)
+		c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+		return false
+	}
+	return true
+}
+
+// alreadyReplayed reports whether message is a broadcast of a message the
+// client already received from a replay. The replayed ids of a channel are
+// forgotten once live delivery passes the last of them.
+func (c *Client) alreadyReplayed(message interface{}) bool {
+	o, ok := message.(outbound)
+	if !ok || o.messageId == 0 {
+		return false
+	}
+	replayed, ok := c.replayed[o.channelId]
+	if !ok {
+		return false
+	}
+	if _, seen := replayed.ids[o.messageId]; seen {
+		return true
+	}
+	if o.messageId > replayed.last {
+		delete(c.replayed, o.channelId)
+	}
+	return false
+}
+
+// markReplayed records that a replay of the channel wrote messageId
+func (c *Client) markReplayed(channelId int64, messageId int) {
+	replayed, ok := c.replayed[channelId]
+	if !ok {
+		replayed = &replayedIds{ids: make(map[int]struct{})}
+		c.replayed[channelId] = replayed
+	}
+	replayed.ids[messageId] = struct{}{}
+	replayed.last = max(replayed.last, messageId)
+}
+
+// write sends a frame to the peer
+func (c *Client) write(message interface{}) error {
+	return c.out.writeFrame(message)
+}
+
+// replay writes every persisted message of the channel after since straight
+// to the socket, a page at a time. Broadcasts received meanwhile are held
+// between pages, so a long replay does not overflow c.send, and written once
+// it ends, skipping the replayed ids. The handoff to live delivery has no
+// gaps or duplicates.
+func (c *Client) replay(service chat.Service, request replayRequest) error {
+	since := request.since
+	for {
+		page, err := service.FetchMessages(int(request.channelId), entities.MessageQuery{
+			After:          since,
+			Limit:          chat.MaxMessageLimit,
+			ViewerID:       c.userId,
+			IncludeReplies: true,
+		})
+		if err != nil {
+			if err := c.write(Result{
+				ChannelID: request.channelId,
+				Success:   false,
+				Message:   err.Error(),
+			}); err != nil {
+				return err
+			}
+			return c.flushHeld()
+		}
+
+		for _, message := range page.Messages {
+			if err := c.write(message); err != nil {
+				return err
+			}
+			c.markReplayed(request.channelId, message.ID)
+			since = message.ID
+		}
+		c.hold()
+
+		if !page.HasMore {
+			return c.flushHeld()
+		}
+	}
+}
+
+// hold moves the frames queued in c.send to c.held
+func (c *Client) hold() {
+	for {
+		select {
+		case message := <-c.send:
+			c.held = append(c.held, message)
+		default:
+			return
+		}
+	}
+}
+
+// flushHeld writes the frames held during a replay, except the messages the
+// replay already wrote
+func (c *Client) flushHeld() error {
+	held := c.held
+	c.held = nil
+	for _, message := range held {
+		if c.alreadyReplayed(message) {
+			continue
+		}
+		if err := c.write(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) requestReplay(channelId int64, since int) {
+	select {
+	case c.resume <- replayRequest{channelId, since}:
+	case <-c.quit:
+	}
+}
+
+func (c *Client) close() {
+	c.once.Do(func() {
+		close(c.quit)
+	})
+}
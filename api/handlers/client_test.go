@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockService serves the messages of a channel in pages of pageSize. Only
+// the methods replays use are implemented.
+type mockService struct {
+	chat.Service
+	messages   []entities.Message
+	pageSize   int
+	fetchError error
+	// Called with the query of every page before it is served
+	onFetch func(query entities.MessageQuery)
+}
+
+func (ms mockService) FetchMessages(channelId int, query entities.MessageQuery) (entities.MessagePage, error) {
+	if ms.onFetch != nil {
+		ms.onFetch(query)
+	}
+	if ms.fetchError != nil {
+		return entities.MessagePage{}, ms.fetchError
+	}
+	page := entities.MessagePage{Messages: []entities.Message{}}
+	for _, message := range ms.messages {
+		if message.ID <= query.After {
+			continue
+		}
+		if len(page.Messages) == ms.pageSize {
+			page.HasMore = true
+			break
+		}
+		page.Messages = append(page.Messages, message)
+	}
+	return page, nil
+}
+
+// recordingWriter keeps the frames written to a client
+type recordingWriter struct {
+	frames     []interface{}
+	writeError error
+}
+
+func (w *recordingWriter) writeFrame(message interface{}) error {
+	if w.writeError != nil {
+		return w.writeError
+	}
+	w.frames = append(w.frames, message)
+	return nil
+}
+
+func newRecordedClient(userId int) (*Client, *recordingWriter) {
+	out := &recordingWriter{}
+	client := newClient(userId, "127.0.0.1")
+	client.out = out
+	return client, out
+}
+
+func messagesUpTo(last int) []entities.Message {
+	messages := []entities.Message{}
+	for id := 1; id <= last; id++ {
+		messages = append(messages, entities.Message{ID: id, ChannelID: 1})
+	}
+	return messages
+}
+
+func TestClientReplay(t *testing.T) {
+	t.Run("every page in order", func(t *testing.T) {
+		client, out := newRecordedClient(2)
+		afters := []int{}
+		service := mockService{messages: messagesUpTo(5), pageSize: 2, onFetch: func(query entities.MessageQuery) {
+			afters = append(afters, query.After)
+			assert.Equal(t, 2, query.ViewerID)
+			assert.True(t, query.IncludeReplies)
+		}}
+
+		err := client.replay(service, replayRequest{channelId: 1, since: 0})
+		assert.NoError(t, err)
+		assert.Equal(t, []int{0, 2, 4}, afters)
+		assert.Equal(t, []interface{}{
+			entities.Message{ID: 1, ChannelID: 1},
+			entities.Message{ID: 2, ChannelID: 1},
+			entities.Message{ID: 3, ChannelID: 1},
+			entities.Message{ID: 4, ChannelID: 1},
+			entities.Message{ID: 5, ChannelID: 1},
+		}, out.frames)
+		assert.Equal(t, 5, client.replayed[1].last)
+	})
+
+	t.Run("broadcasts between pages are held until the end", func(t *testing.T) {
+		client, out := newRecordedClient(2)
+		typing := outbound{channelId: 1, payload: []byte(`{"type":"typing"}`)}
+		live := outbound{channelId: 1, messageId: 5, payload: []byte(`{"id":5}`)}
+		service := mockService{messages: messagesUpTo(4), pageSize: 2, onFetch: func(query entities.MessageQuery) {
+			if query.After == 2 {
+				// Broadcast while the first page was written
+				client.send <- outbound{channelId: 1, messageId: 3, payload: []byte(`{"id":3}`)}
+				client.send <- typing
+				client.send <- live
+			}
+		}}
+
+		err := client.replay(service, replayRequest{channelId: 1, since: 0})
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{
+			entities.Message{ID: 1, ChannelID: 1},
+			entities.Message{ID: 2, ChannelID: 1},
+			entities.Message{ID: 3, ChannelID: 1},
+			entities.Message{ID: 4, ChannelID: 1},
+			typing,
+			live,
+		}, out.frames)
+		assert.Empty(t, client.held)
+		assert.Empty(t, client.send)
+	})
+
+	t.Run("replayed ids are skipped until live delivery passes them", func(t *testing.T) {
+		client, _ := newRecordedClient(2)
+		err := client.replay(mockService{messages: messagesUpTo(3), pageSize: 10}, replayRequest{channelId: 1, since: 1})
+		assert.NoError(t, err)
+
+		assert.True(t, client.alreadyReplayed(outbound{channelId: 1, messageId: 3}))
+		assert.False(t, client.alreadyReplayed(outbound{channelId: 2, messageId: 3}))
+		assert.False(t, client.alreadyReplayed(outbound{channelId: 1}))
+		assert.False(t, client.alreadyReplayed(entities.Message{ID: 3, ChannelID: 1}))
+
+		assert.False(t, client.alreadyReplayed(outbound{channelId: 1, messageId: 4}))
+		assert.NotContains(t, client.replayed, int64(1))
+		assert.False(t, client.alreadyReplayed(outbound{channelId: 1, messageId: 3}))
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		client, out := newRecordedClient(2)
+		held := outbound{channelId: 1, payload: []byte(`{"type":"typing"}`)}
+		client.held = []interface{}{held}
+
+		err := client.replay(mockService{fetchError: errors.New("error fetching messages")}, replayRequest{channelId: 1, since: 4})
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{
+			Result{ChannelID: 1, Success: false, Message: "error fetching messages"},
+			held,
+		}, out.frames)
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		client, out := newRecordedClient(2)
+		out.writeError = errors.New("broken pipe")
+
+		err := client.replay(mockService{messages: messagesUpTo(2), pageSize: 10}, replayRequest{channelId: 1, since: 0})
+		assert.Equal(t, out.writeError, err)
+		assert.Empty(t, client.replayed)
+	})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/aramceballos/chat-group-server/pkg/msgpack"
+	"github.com/gofiber/contrib/websocket"
+)
+
+// Websocket subprotocols. Clients that negotiate msgpack exchange binary
+// MessagePack frames, everyone else JSON text frames.
+const (
+	SubprotocolMsgpack = "msgpack"
+	SubprotocolJSON    = "json"
+)
+
+// wsConfig is shared by the websocket endpoints; msgpack is preferred when a
+// client offers both
+var wsConfig = websocket.Config{
+	Subprotocols: []string{SubprotocolMsgpack, SubprotocolJSON},
+}
+
+// decodeFrame returns an inbound frame as JSON, the form frames are validated
+// and stored in, whichever format it was sent in
+func decodeFrame(messageType int, data []byte) (json.RawMessage, error) {
+	if messageType == websocket.BinaryMessage {
+		raw, rest, err := msgpack.ReadJSON(data)
+		if err != nil || len(rest) > 0 {
+			return nil, errors.New("Invalid message body MessagePack")
+		}
+		return raw, nil
+	}
+
+	if !json.Valid(data) {
+		return nil, errors.New("Invalid message body JSON")
+	}
+	return data, nil
+}
+
+// encodeMsgpack encodes an outbound frame as MessagePack
+func encodeMsgpack(message interface{}) ([]byte, error) {
+	if o, ok := message.(outbound); ok {
+		return o.msgpack()
+	}
+
+	// Everything else goes through its JSON encoding to keep the same keys
+	raw, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	return msgpack.AppendJSON(nil, raw)
+}
+
+// packedPayload holds the MessagePack encoding of a broadcast, computed once
+// for all the clients it is delivered to
+type packedPayload struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+func (o outbound) msgpack() ([]byte, error) {
+	if o.packed == nil {
+		return msgpack.AppendJSON(nil, o.payload)
+	}
+	o.packed.once.Do(func() {
+		o.packed.data, o.packed.err = msgpack.AppendJSON(nil, o.payload)
+	})
+	return o.packed.data, o.packed.err
+}
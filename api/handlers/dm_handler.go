@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"github.com/aramceballos/chat-group-server/pkg/dm"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// dmErrorStatus maps a direct message service error to its response status
+func dmErrorStatus(err error) int {
+	switch err {
+	case dm.ErrInvalidRecipient, dm.ErrInvalidBlock:
+		return fiber.StatusBadRequest
+	case dm.ErrUserNotFound:
+		return fiber.StatusNotFound
+	case dm.ErrBlocked:
+		return fiber.StatusForbidden
+	}
+	return fiber.StatusInternalServerError
+}
+
+// OpenDM returns the direct message of the caller with a user, creating it
+// on first use. Its channel id is used with the chat endpoints.
+func OpenDM(service dm.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var input entities.OpenDMInput
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		// Validate input
+		validate := validator.New()
+		if err := validate.Struct(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		conversation, created, err := service.OpenDM(currentUserId(c), input.UserID)
+		if err != nil {
+			return c.Status(dmErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		if created {
+			return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+				"status":  "success",
+				"message": "direct message created",
+				"data":    conversation,
+			})
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "direct message retrieved",
+			"data":    conversation,
+		})
+	}
+}
+
+func GetDMs(service dm.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		conversations, err := service.FetchDMs(currentUserId(c))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "direct messages retrieved",
+			"data":    conversations,
+		})
+	}
+}
+
+func GetBlockedUsers(service dm.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		users, err := service.FetchBlockedUsers(currentUserId(c))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "blocked users retrieved",
+			"data":    users,
+		})
+	}
+}
+
+func BlockUser(service dm.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		blockedId, err := c.ParamsInt("userId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid user id",
+				"data":    nil,
+			})
+		}
+
+		if err := service.BlockUser(currentUserId(c), blockedId); err != nil {
+			return c.Status(dmErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "user blocked",
+			"data":    nil,
+		})
+	}
+}
+
+func UnblockUser(service dm.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		blockedId, err := c.ParamsInt("userId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid user id",
+				"data":    nil,
+			})
+		}
+
+		if err := service.UnblockUser(currentUserId(c), blockedId); err != nil {
+			return c.Status(dmErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "user unblocked",
+			"data":    nil,
+		})
+	}
+}
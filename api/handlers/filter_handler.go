@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"github.com/aramceballos/chat-group-server/pkg/channel"
+	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/aramceballos/chat-group-server/pkg/filter"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// flagErrorStatus maps a review queue error to its response status
+func flagErrorStatus(err error) int {
+	switch err {
+	case chat.ErrFlagNotFound:
+		return fiber.StatusNotFound
+	case chat.ErrReviewNotAllowed:
+		return fiber.StatusForbidden
+	case chat.ErrAlreadyReviewed:
+		return fiber.StatusConflict
+	}
+	return fiber.StatusInternalServerError
+}
+
+// GetFilters returns the filters the messages of a channel go through
+func GetFilters(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		config, err := service.FetchFilters(channelId, currentUserId(c))
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "filters retrieved",
+			"data":    config,
+		})
+	}
+}
+
+// UpdateFilters replaces the filters of a channel. They apply from the next
+// message on this replica, and once their cache expires on the others.
+func UpdateFilters(service channel.Service, chatService chat.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		var input entities.FilterConfig
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		// Validate input
+		validate := validator.New()
+		if err := validate.Struct(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+		// Patterns are compiled now so a broken one is never stored
+		if _, err := filter.Build(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		config, err := service.UpdateFilters(channelId, currentUserId(c), input)
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+		chatService.ForgetFilters(channelId)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "filters updated",
+			"data":    config,
+		})
+	}
+}
+
+// GetFlags returns the messages of a channel waiting for review
+func GetFlags(service chat.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		flags, err := service.FetchFlags(channelId, currentUserId(c))
+		if err != nil {
+			return c.Status(flagErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "flags retrieved",
+			"data":    flags,
+		})
+	}
+}
+
+// ReviewFlag approves or removes a flagged message. Removed messages are
+// deleted for everyone in the channel.
+func ReviewFlag(service chat.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+		flagId, err := c.ParamsInt("id")
+		if err != nil || flagId <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid flag id",
+				"data":    nil,
+			})
+		}
+
+		var input entities.ReviewFlagInput
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		// Validate input
+		validate := validator.New()
+		if err := validate.Struct(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		flag, err := service.ReviewFlag(channelId, currentUserId(c), flagId, input.Action)
+		if err != nil {
+			return c.Status(flagErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		if flag.Message != nil {
+			channelsHub.BroadcastEvent(int64(channelId), EventMessageDeleted, flag.Message)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "flag reviewed",
+			"data":    flag,
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/aramceballos/chat-group-server/pkg/filter"
+	"github.com/aramceballos/chat-group-server/pkg/schema"
+)
+
+// Fields that route a frame rather than being part of the message body
+var envelopeFields = []string{"channel_id", "parent_id", "client_msg_id"}
+
+// frameInt reads a numeric field from a decoded frame
+func frameInt(frame map[string]interface{}, field string) (int, bool) {
+	value, ok := frame[field].(float64)
+	if !ok {
+		return 0, false
+	}
+	return int(value), true
+}
+
+// frameInts reads an array of numbers from a decoded frame
+func frameInts(frame map[string]interface{}, field string) ([]int, bool) {
+	values, ok := frame[field].([]interface{})
+	if !ok {
+		return nil, false
+	}
+	ints := make([]int, len(values))
+	for i, value := range values {
+		number, ok := value.(float64)
+		if !ok {
+			return nil, false
+		}
+		ints[i] = int(number)
+	}
+	return ints, true
+}
+
+// processFrame handles a frame of a client for one of its channels
+func processFrame(service chat.Service, client *Client, channelId int64, body map[string]interface{}, raw json.RawMessage) {
+	// Control frames act on existing state instead of posting a message
+	switch body["type"] {
+	case "resume":
+		since, ok := frameInt(body, "since")
+		if !ok || since <= 0 {
+			client.reply(channelId, false, "resume frames must have a numeric 'since' field")
+			return
+		}
+		client.requestReplay(channelId, since)
+	case "edit":
+		handleEditFrame(service, client, channelId, body)
+	case "delete":
+		handleDeleteFrame(service, client, channelId, body)
+	case "reaction_add", "reaction_remove":
+		handleReactionFrame(service, client, channelId, body)
+	case "typing":
+		typingIndicators.Start(channelId, client.userId, func() bool {
+			return service.CanPost(int(channelId), client.userId) == nil
+		})
+	case "read":
+		handleReadFrame(service, client, channelId, body)
+	case "pin", "unpin":
+		handlePinFrame(service, client, channelId, body)
+	case "vote":
+		handleVoteFrame(service, client, channelId, body)
+	default:
+		handleMessageFrame(service, client, channelId, body, raw)
+	}
+}
+
+func handleMessageFrame(service chat.Service, client *Client, channelId int64, body map[string]interface{}, raw json.RawMessage) {
+	postMessage(service, client.userId, channelId, body, raw, func(ack Result) {
+		client.send <- ack
+	})
+}
+
+// postMessage validates, stores and broadcasts a message frame of a member
+// of the channel. The result acknowledging the frame is passed to respond
+// before the message is broadcast. The returned error is only set when the
+// failure is on the server side.
+func postMessage(service chat.Service, userId int, channelId int64, body map[string]interface{}, raw json.RawMessage, respond func(Result)) error {
+	ack := Result{ChannelID: channelId}
+	fail := func(message string) {
+		ack.Success = false
+		ack.Message = message
+		respond(ack)
+	}
+
+	if _, ok := body["client_msg_id"]; ok {
+		clientMsgId, ok := body["client_msg_id"].(string)
+		if !ok {
+			fail("'client_msg_id' must be a string")
+			return nil
+		}
+		ack.ClientMsgID = clientMsgId
+	}
+
+	// Replies carry their parent id next to the body, it is not persisted in it
+	parentId := 0
+	if _, isReply := body["parent_id"]; isReply {
+		var ok bool
+		parentId, ok = frameInt(body, "parent_id")
+		if !ok {
+			fail("'parent_id' must be a message id")
+			return nil
+		}
+	}
+	for _, field := range envelopeFields {
+		if _, ok := body[field]; ok {
+			delete(body, field)
+			var err error
+			if raw, err = json.Marshal(body); err != nil {
+				fail("Invalid message body JSON")
+				return nil
+			}
+		}
+	}
+
+	raw, err := parseBody(raw)
+	if err != nil {
+		fail(err.Error())
+		return nil
+	}
+
+	// The filters of the channel may reject the body or redact parts of it
+	decision, err := service.ScreenMessage(int(channelId), raw)
+	if err != nil {
+		fail(err.Error())
+		return err
+	}
+	if decision.Action == filter.Reject {
+		fail(decision.Reason())
+		return nil
+	}
+	raw = decision.Body
+
+	// Insert message into database
+	insertedMessage, created, err := service.InsertMessage(int(channelId), userId, parentId, ack.ClientMsgID, raw)
+	if err == chat.ErrInvalidParent || err == chat.ErrInvalidClientMsgId || err == chat.ErrClientMsgIdInUse || err == chat.ErrChannelArchived ||
+		err == chat.ErrNotMember || err == chat.ErrReadOnly || err == chat.ErrBlocked || err == chat.ErrMuted {
+		fail(err.Error())
+		return nil
+	}
+	if err != nil {
+		log.Println("error inserting message:", err)
+		fail(err.Error())
+		return err
+	}
+	ack.MessageID = insertedMessage.ID
+
+	// A retried frame is acknowledged again but never broadcast twice
+	if !created {
+		ack.Success = true
+		ack.Message = "Message already sent"
+		respond(ack)
+		return nil
+	}
+
+	// Flagged messages are sent all the same, the review decides if they stay
+	if decision.Action == filter.Flag {
+		if _, err := service.FlagMessage(insertedMessage, decision.Reasons); err != nil {
+			log.Println("error flagging message:", err)
+		}
+	}
+
+	// Query user from database to populate the message
+	user, err := service.FetchUserById(userId)
+	if err != nil {
+		fail(err.Error())
+		return err
+	}
+	insertedMessage.User = user
+
+	ack.Success = true
+	ack.Message = "Message sent successfully"
+	respond(ack)
+
+	typingIndicators.Stop(channelId, userId)
+	channelsHub.BroadcastMessage(channelId, insertedMessage)
+	return nil
+}
+
+// parseBody validates a message body against the schema of its type and
+// returns it in the encoding of that type
+func parseBody(raw json.RawMessage) (json.RawMessage, error) {
+	body, err := schema.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(body)
+}
+
+func handleEditFrame(service chat.Service, client *Client, channelId int64, frame map[string]interface{}) {
+	messageId, ok := frameInt(frame, "message_id")
+	if !ok {
+		client.reply(channelId, false, "edit frames must have a numeric 'message_id' field")
+		return
+	}
+
+	body, ok := frame["body"].(map[string]interface{})
+	if !ok {
+		client.reply(channelId, false, "edit frames must have a 'body' object")
+		return
+	}
+	msgBody, err := json.Marshal(body)
+	if err != nil {
+		client.reply(channelId, false, "Invalid message body JSON")
+		return
+	}
+	if msgBody, err = parseBody(msgBody); err != nil {
+		client.reply(channelId, false, err.Error())
+		return
+	}
+
+	// Edits go through the filters too, or they would get around them
+	decision, err := service.ScreenMessage(int(channelId), msgBody)
+	if err != nil {
+		client.reply(channelId, false, err.Error())
+		return
+	}
+	if decision.Action == filter.Reject {
+		client.reply(channelId, false, decision.Reason())
+		return
+	}
+
+	message, err := service.EditMessage(int(channelId), client.userId, messageId, decision.Body)
+	if err != nil {
+		client.reply(channelId, false, err.Error())
+		return
+	}
+	if decision.Action == filter.Flag {
+		if _, err := service.FlagMessage(message, decision.Reasons); err != nil {
+			log.Println("error flagging message:", err)
+		}
+	}
+
+	client.reply(channelId, true, "Message edited successfully")
+
+	channelsHub.BroadcastEvent(channelId, EventMessageUpdated, message)
+}
+
+func handleDeleteFrame(service chat.Service, client *Client, channelId int64, frame map[string]interface{}) {
+	messageId, ok := frameInt(frame, "message_id")
+	if !ok {
+		client.reply(channelId, false, "delete frames must have a numeric 'message_id' field")
+		return
+	}
+
+	message, err := service.DeleteMessage(int(channelId), client.userId, messageId)
+	if err != nil {
+		client.reply(channelId, false, err.Error())
+		return
+	}
+
+	client.reply(channelId, true, "Message deleted successfully")
+
+	channelsHub.BroadcastEvent(channelId, EventMessageDeleted, message)
+}
+
+func handleReactionFrame(service chat.Service, client *Client, channelId int64, frame map[string]interface{}) {
+	messageId, ok := frameInt(frame, "message_id")
+	emoji, emojiOk := frame["emoji"].(string)
+	if !ok || !emojiOk {
+		client.reply(channelId, false, "reaction frames must have a numeric 'message_id' and an 'emoji' field")
+		return
+	}
+
+	var update entities.ReactionUpdate
+	var err error
+	eventType := EventReactionAdded
+	if frame["type"] == "reaction_add" {
+		update, err = service.AddReaction(int(channelId), client.userId, messageId, emoji)
+	} else {
+		update, err = service.RemoveReaction(int(channelId), client.userId, messageId, emoji)
+		eventType = EventReactionRemoved
+	}
+	if err != nil {
+		client.reply(channelId, false, err.Error())
+		return
+	}
+
+	channelsHub.BroadcastEvent(channelId, eventType, update)
+}
+
+func handleReadFrame(service chat.Service, client *Client, channelId int64, frame map[string]interface{}) {
+	messageId, ok := frameInt(frame, "message_id")
+	if !ok {
+		client.reply(channelId, false, "read frames must have a numeric 'message_id' field")
+		return
+	}
+
+	receipt, err := service.MarkRead(int(channelId), client.userId, messageId)
+	if err != nil {
+		client.reply(channelId, false, err.Error())
+		return
+	}
+
+	channelsHub.BroadcastEvent(channelId, EventRead, receipt)
+}
+
+func handlePinFrame(service chat.Service, client *Client, channelId int64, frame map[string]interface{}) {
+	messageId, ok := frameInt(frame, "message_id")
+	if !ok {
+		client.reply(channelId, false, "pin frames must have a numeric 'message_id' field")
+		return
+	}
+
+	var data interface{}
+	var err error
+	eventType := EventMessagePinned
+	if frame["type"] == "pin" {
+		data, err = service.PinMessage(int(channelId), client.userId, messageId)
+	} else {
+		data, err = service.UnpinMessage(int(channelId), client.userId, messageId)
+		eventType = EventMessageUnpinned
+	}
+	if err != nil {
+		client.reply(channelId, false, err.Error())
+		return
+	}
+
+	channelsHub.BroadcastEvent(channelId, eventType, data)
+}
+
+func handleVoteFrame(service chat.Service, client *Client, channelId int64, frame map[string]interface{}) {
+	messageId, ok := frameInt(frame, "message_id")
+	options, optionsOk := frameInts(frame, "options")
+	if !ok || !optionsOk {
+		client.reply(channelId, false, "vote frames must have a numeric 'message_id' and an 'options' array of option indexes")
+		return
+	}
+
+	tally, err := service.Vote(int(channelId), client.userId, messageId, options)
+	if err != nil {
+		client.reply(channelId, false, err.Error())
+		return
+	}
+
+	client.reply(channelId, true, "Vote recorded")
+
+	channelsHub.BroadcastEvent(channelId, EventPollUpdated, tally)
+}
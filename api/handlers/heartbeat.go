@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"expvar"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	defaultPingInterval = 30 * time.Second
+	defaultPongWait     = 60 * time.Second
+)
+
+// Heartbeat settings, overridable with WS_PING_INTERVAL and WS_PONG_WAIT
+// (Go durations such as "30s"). PongWait must be longer than PingInterval.
+var PingInterval, PongWait = heartbeatFromEnv()
+
+// Time allowed to write a frame to the peer
+const WriteWait = 10 * time.Second
+
+// Reasons a client is reaped, counted in the reaped_websocket_clients expvar
+const (
+	ReapMissedHeartbeat = "missed_heartbeat"
+	ReapWriteFailed     = "write_failed"
+	ReapSlowConsumer    = "slow_consumer"
+)
+
+var reapedClients = expvar.NewMap("reaped_websocket_clients")
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("invalid %s %q, using %s", name, value, fallback)
+		return fallback
+	}
+	return duration
+}
+
+// heartbeatFromEnv reads the heartbeat settings. A pong wait no longer than
+// the ping interval would drop every client, so both fall back to their
+// defaults then.
+func heartbeatFromEnv() (time.Duration, time.Duration) {
+	pingInterval := durationFromEnv("WS_PING_INTERVAL", defaultPingInterval)
+	pongWait := durationFromEnv("WS_PONG_WAIT", defaultPongWait)
+	if pongWait <= pingInterval {
+		log.Printf("WS_PONG_WAIT %s must be longer than WS_PING_INTERVAL %s, using %s and %s", pongWait, pingInterval, defaultPongWait, defaultPingInterval)
+		return defaultPingInterval, defaultPongWait
+	}
+	return pingInterval, pongWait
+}
+
+// reap logs and counts why a client is being dropped
+func reap(client *Client, reason string) {
+	log.Printf("Reaped client %d from IP %s: %s", client.userId, client.remoteAddr, reason)
+	reapedClients.Add(reason, 1)
+}
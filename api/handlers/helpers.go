@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// currentUserId returns the user id from the JWT stored by middleware.Protected
+func currentUserId(c *fiber.Ctx) int {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	return int(claims["user_id"].(float64))
+}
+
+// checkMembership writes the error response and returns false when the user
+// is not a member of the channel
+func checkMembership(c *fiber.Ctx, service chat.Service, channelId int, userId int) (bool, error) {
+	exists, err := service.CheckUserMembership(channelId, userId)
+	if err != nil {
+		return false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+	if !exists {
+		return false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": entities.ErrNotMember.Error(),
+			"data":    nil,
+		})
+	}
+	return true, nil
+}
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aramceballos/chat-group-server/pkg/broker"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+)
+
+type ChannelsHub struct {
+	channels   map[int64]map[*Client]struct{}
+	presence   map[int64]map[int]int // connection count per user of each channel
+	channelsMu sync.RWMutex
+	broker     broker.Broker
+	brokerMu   sync.RWMutex
+}
+
+// Broadcast is the envelope published on the broker for every replica
+type Broadcast struct {
+	ChannelID     int64 `json:"channel_id"`
+	ExcludeUserID int   `json:"exclude_user_id,omitempty"`
+	// Set for new messages so clients can skip the ones they got from a replay
+	MessageID int `json:"message_id,omitempty"`
+	// Set to disconnect the clients of a user from the channel, e.g. once
+	// they are removed from it. Such broadcasts have no payload.
+	DisconnectUserID int `json:"disconnect_user_id,omitempty"`
+	// Set to disconnect every client from the channel, e.g. once it is
+	// deleted. Such broadcasts have no payload.
+	DisconnectAll bool            `json:"disconnect_all,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// outbound is a broadcast payload queued for a client, already encoded
+type outbound struct {
+	channelId int64
+	messageId int
+	payload   json.RawMessage
+	packed    *packedPayload
+}
+
+type PresenceUpdate struct {
+	UserID int  `json:"user_id"`
+	Online bool `json:"online"`
+}
+
+func NewChannelsHub(b broker.Broker) *ChannelsHub {
+	ch := &ChannelsHub{
+		channels: make(map[int64]map[*Client]struct{}),
+		presence: make(map[int64]map[int]int),
+		broker:   b,
+	}
+	b.Subscribe(ch.deliver)
+	b.OnMissed(ch.resync)
+	return ch
+}
+
+// SetBroker makes the hub publish broadcasts on b, e.g. to reach the
+// sockets of other replicas. The previous broker is closed.
+func SetBroker(b broker.Broker) {
+	channelsHub.setBroker(b)
+}
+
+func (ch *ChannelsHub) setBroker(b broker.Broker) {
+	b.Subscribe(ch.deliver)
+	b.OnMissed(ch.resync)
+
+	ch.brokerMu.Lock()
+	previous := ch.broker
+	ch.broker = b
+	ch.brokerMu.Unlock()
+
+	if err := previous.Close(); err != nil {
+		log.Printf("error closing previous broker: %v", err)
+	}
+}
+
+func (ch *ChannelsHub) AddClient(channelId int64, client *Client) {
+	ch.channelsMu.Lock()
+	// Create client map for channel if doesn't exist
+	if _, ok := ch.channels[channelId]; !ok {
+		ch.channels[channelId] = make(map[*Client]struct{})
+		ch.presence[channelId] = make(map[int]int)
+	}
+	ch.channels[channelId][client] = struct{}{}
+	ch.presence[channelId][client.userId]++
+	// Only the first connection of a user (e.g. first tab) brings them online
+	joined := ch.presence[channelId][client.userId] == 1
+	ch.channelsMu.Unlock()
+
+	if joined {
+		ch.BroadcastEvent(channelId, EventPresence, PresenceUpdate{
+			UserID: client.userId,
+			Online: true,
+		})
+	}
+}
+
+func (ch *ChannelsHub) RemoveClient(channelId int64, client *Client) {
+	ch.channelsMu.Lock()
+	if _, ok := ch.channels[channelId][client]; !ok {
+		ch.channelsMu.Unlock()
+		return
+	}
+	delete(ch.channels[channelId], client)
+	ch.presence[channelId][client.userId]--
+	left := ch.presence[channelId][client.userId] == 0
+	if left {
+		delete(ch.presence[channelId], client.userId)
+	}
+	// Clean up channel if empty
+	if len(ch.channels[channelId]) == 0 {
+		delete(ch.channels, channelId)
+		delete(ch.presence, channelId)
+		log.Printf("Channel %d cleaned up (empty channel)", channelId)
+	}
+	ch.channelsMu.Unlock()
+
+	if left {
+		ch.BroadcastEvent(channelId, EventPresence, PresenceUpdate{
+			UserID: client.userId,
+			Online: false,
+		})
+	}
+}
+
+// OnlineUsers returns the ids of the users connected to the channel
+func (ch *ChannelsHub) OnlineUsers(channelId int64) []int {
+	ch.channelsMu.RLock()
+	defer ch.channelsMu.RUnlock()
+	userIds := make([]int, 0, len(ch.presence[channelId]))
+	for userId := range ch.presence[channelId] {
+		userIds = append(userIds, userId)
+	}
+	slices.Sort(userIds)
+	return userIds
+}
+
+func (ch *ChannelsHub) BroadcastMessage(channelId int64, message entities.Message) {
+	ch.Broadcast(channelId, message)
+}
+
+func (ch *ChannelsHub) BroadcastEvent(channelId int64, eventType string, data interface{}) {
+	ch.Broadcast(channelId, Event{
+		Type:      eventType,
+		ChannelID: channelId,
+		Data:      data,
+	})
+}
+
+func (ch *ChannelsHub) Broadcast(channelId int64, message interface{}) {
+	ch.BroadcastExcept(channelId, message, 0)
+}
+
+// BroadcastExcept publishes message for every client of the channel except
+// the connections of excludeUserId, on every replica
+func (ch *ChannelsHub) BroadcastExcept(channelId int64, message interface{}, excludeUserId int) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("error encoding broadcast for channel %d: %v", channelId, err)
+		return
+	}
+
+	envelope := Broadcast{
+		ChannelID:     channelId,
+		ExcludeUserID: excludeUserId,
+		Payload:       payload,
+	}
+	if m, ok := message.(entities.Message); ok {
+		envelope.MessageID = m.ID
+	}
+	ch.publish(envelope)
+}
+
+// Disconnect drops the clients of userId from the channel on every replica.
+// Connections bound to the channel are closed, multiplexed ones are only
+// unsubscribed from it.
+func (ch *ChannelsHub) Disconnect(channelId int64, userId int) {
+	ch.publish(Broadcast{
+		ChannelID:        channelId,
+		DisconnectUserID: userId,
+	})
+}
+
+// DisconnectAll drops every client from the channel on every replica, like
+// Disconnect does for the clients of one user
+func (ch *ChannelsHub) DisconnectAll(channelId int64) {
+	ch.publish(Broadcast{
+		ChannelID:     channelId,
+		DisconnectAll: true,
+	})
+}
+
+func (ch *ChannelsHub) publish(envelope Broadcast) {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		log.Printf("error encoding broadcast for channel %d: %v", envelope.ChannelID, err)
+		return
+	}
+	ch.brokerMu.RLock()
+	b := ch.broker
+	ch.brokerMu.RUnlock()
+	if err := b.Publish(data); err != nil {
+		log.Printf("error publishing broadcast for channel %d: %v", envelope.ChannelID, err)
+	}
+}
+
+// deliver sends a broadcast received from the broker to the local clients
+func (ch *ChannelsHub) deliver(data []byte) {
+	var envelope Broadcast
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		log.Printf("error decoding broadcast: %v", err)
+		return
+	}
+	channelId := envelope.ChannelID
+	if envelope.DisconnectUserID != 0 || envelope.DisconnectAll {
+		ch.disconnect(channelId, envelope.DisconnectUserID)
+		return
+	}
+
+	ch.channelsMu.RLock()
+	clients := make([]*Client, 0, len(ch.channels[channelId]))
+	for client := range ch.channels[channelId] {
+		if envelope.ExcludeUserID != 0 && client.userId == envelope.ExcludeUserID {
+			continue
+		}
+		clients = append(clients, client)
+	}
+	ch.channelsMu.RUnlock()
+
+	message := outbound{
+		channelId: channelId,
+		messageId: envelope.MessageID,
+		payload:   envelope.Payload,
+		packed:    &packedPayload{},
+	}
+	for _, client := range clients {
+		select {
+		case client.send <- message:
+			client.failureCount = 0
+		default:
+			log.Printf("Message dropped for client %d on channel %d", client.userId, channelId)
+			client.failureCount++
+			client.lastFailure = time.Now()
+
+			if client.failureCount >= 5 {
+				log.Printf("Removed unresponsive client %d from channel %d", client.userId, channelId)
+				reap(client, ReapSlowConsumer)
+				client.close()
+				ch.RemoveClient(channelId, client)
+			}
+		}
+	}
+}
+
+// disconnect evicts the local clients of userId from the channel, every one
+// of them if userId is 0
+func (ch *ChannelsHub) disconnect(channelId int64, userId int) {
+	ch.channelsMu.RLock()
+	clients := []*Client{}
+	for client := range ch.channels[channelId] {
+		if userId == 0 || client.userId == userId {
+			clients = append(clients, client)
+		}
+	}
+	ch.channelsMu.RUnlock()
+
+	for _, client := range clients {
+		log.Printf("Disconnected client %d from channel %d", client.userId, channelId)
+		client.evict(ch, channelId)
+	}
+}
+
+// resync closes every local client after the broker lost broadcasts, so they
+// reconnect and resume with since instead of silently missing messages
+func (ch *ChannelsHub) resync() {
+	ch.channelsMu.RLock()
+	clients := make(map[*Client]struct{})
+	for _, channelClients := range ch.channels {
+		for client := range channelClients {
+			clients[client] = struct{}{}
+		}
+	}
+	ch.channelsMu.RUnlock()
+
+	log.Printf("Closing %d clients to resume broadcasts missed by the broker", len(clients))
+	for client := range clients {
+		client.close()
+	}
+}
+
+var channelsHub = NewChannelsHub(broker.NewMemoryBroker())
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aramceballos/chat-group-server/pkg/broker"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockBroker delivers payloads synchronously, like the memory broker, and
+// lets tests report missed payloads
+type mockBroker struct {
+	handlers []broker.Handler
+	missed   []func()
+}
+
+func (b *mockBroker) Publish(payload []byte) error {
+	for _, handler := range b.handlers {
+		handler(payload)
+	}
+	return nil
+}
+
+func (b *mockBroker) Subscribe(handler broker.Handler) {
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *mockBroker) OnMissed(missed func()) {
+	b.missed = append(b.missed, missed)
+}
+
+func (b *mockBroker) Close() error {
+	return nil
+}
+
+func (b *mockBroker) miss() {
+	for _, missed := range b.missed {
+		missed()
+	}
+}
+
+// drain returns the frames queued for a client
+func drain(client *Client) []interface{} {
+	frames := []interface{}{}
+	for {
+		select {
+		case message := <-client.send:
+			frames = append(frames, message)
+		default:
+			return frames
+		}
+	}
+}
+
+func closed(client *Client) bool {
+	select {
+	case <-client.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// setupHub subscribes alice (1) and bob (2) to channel 1 and carol (3) to
+// channel 2, with their presence events drained
+func setupHub() (*ChannelsHub, *mockBroker, *Client, *Client, *Client) {
+	b := &mockBroker{}
+	hub := NewChannelsHub(b)
+	alice := newClient(1, "127.0.0.1")
+	bob := newClient(2, "127.0.0.1")
+	carol := newClient(3, "127.0.0.1")
+	alice.subscribe(hub, 1)
+	bob.subscribe(hub, 1)
+	carol.subscribe(hub, 2)
+	for _, client := range []*Client{alice, bob, carol} {
+		drain(client)
+	}
+	return hub, b, alice, bob, carol
+}
+
+func TestChannelsHubDeliver(t *testing.T) {
+	t.Run("clients of the channel", func(t *testing.T) {
+		hub, _, alice, bob, carol := setupHub()
+
+		hub.BroadcastMessage(1, entities.Message{ID: 9, ChannelID: 1})
+
+		for _, client := range []*Client{alice, bob} {
+			frames := drain(client)
+			if assert.Len(t, frames, 1) {
+				o := frames[0].(outbound)
+				assert.Equal(t, int64(1), o.channelId)
+				assert.Equal(t, 9, o.messageId)
+				var message entities.Message
+				assert.NoError(t, json.Unmarshal(o.payload, &message))
+				assert.Equal(t, 9, message.ID)
+			}
+		}
+		assert.Empty(t, drain(carol))
+	})
+
+	t.Run("except the excluded user", func(t *testing.T) {
+		hub, _, alice, bob, _ := setupHub()
+
+		hub.BroadcastExcept(1, Event{Type: EventTyping, ChannelID: 1}, 1)
+
+		assert.Empty(t, drain(alice))
+		frames := drain(bob)
+		if assert.Len(t, frames, 1) {
+			assert.Equal(t, 0, frames[0].(outbound).messageId)
+		}
+	})
+
+	t.Run("slow consumers are removed", func(t *testing.T) {
+		hub, _, alice, bob, _ := setupHub()
+		for i := 0; i < cap(alice.send); i++ {
+			alice.send <- Result{}
+		}
+
+		for i := 0; i < 5; i++ {
+			hub.Broadcast(1, Event{Type: EventTyping, ChannelID: 1})
+		}
+
+		assert.True(t, closed(alice))
+		assert.Equal(t, []int{2}, hub.OnlineUsers(1))
+		assert.False(t, closed(bob))
+	})
+}
+
+func TestChannelsHubDisconnect(t *testing.T) {
+	t.Run("clients of a user", func(t *testing.T) {
+		hub, _, alice, bob, _ := setupHub()
+
+		hub.Disconnect(1, 1)
+
+		assert.True(t, closed(alice))
+		assert.False(t, alice.subscribed(1))
+		assert.False(t, closed(bob))
+		assert.Equal(t, []int{2}, hub.OnlineUsers(1))
+	})
+
+	t.Run("multiplexed clients stay connected", func(t *testing.T) {
+		hub, _, alice, _, _ := setupHub()
+		alice.multiplexed = true
+		alice.subscribe(hub, 2)
+
+		hub.Disconnect(1, 1)
+
+		assert.False(t, closed(alice))
+		assert.False(t, alice.subscribed(1))
+		assert.True(t, alice.subscribed(2))
+	})
+
+	t.Run("every client of a deleted channel", func(t *testing.T) {
+		hub, _, alice, bob, carol := setupHub()
+
+		hub.DisconnectAll(1)
+
+		assert.True(t, closed(alice))
+		assert.True(t, closed(bob))
+		assert.Empty(t, hub.OnlineUsers(1))
+		assert.False(t, closed(carol))
+		assert.Equal(t, []int{3}, hub.OnlineUsers(2))
+	})
+}
+
+func TestChannelsHubResync(t *testing.T) {
+	_, b, alice, bob, carol := setupHub()
+
+	b.miss()
+
+	assert.True(t, closed(alice))
+	assert.True(t, closed(bob))
+	assert.True(t, closed(carol))
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"github.com/aramceballos/chat-group-server/pkg/channel"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+func CreateInvite(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		// Both limits are optional, so an empty body is a valid invite
+		var input entities.CreateInviteInput
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&input); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status":  "error",
+					"message": err.Error(),
+					"data":    nil,
+				})
+			}
+		}
+
+		// Validate input
+		validate := validator.New()
+		if err := validate.Struct(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		invite, err := service.CreateInvite(channelId, currentUserId(c), input)
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"status":  "success",
+			"message": "invite created",
+			"data":    invite,
+		})
+	}
+}
+
+func GetInvites(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		invites, err := service.FetchInvites(channelId, currentUserId(c))
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "invites retrieved",
+			"data":    invites,
+		})
+	}
+}
+
+func RevokeInvite(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		if err := service.RevokeInvite(channelId, currentUserId(c), c.Params("code")); err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "invite revoked",
+			"data":    nil,
+		})
+	}
+}
+
+// AcceptInvite joins the channel of an invite and announces the new member
+// with a system message
+func AcceptInvite(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		member, message, err := service.AcceptInvite(c.Params("code"), currentUserId(c))
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		channelId := int64(message.ChannelID)
+		channelsHub.BroadcastEvent(channelId, EventMemberJoined, member)
+		channelsHub.BroadcastMessage(channelId, message)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "invite accepted",
+			"data":    fiber.Map{"channel_id": message.ChannelID, "member": member},
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"github.com/aramceballos/chat-group-server/pkg/channel"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// MemberRemoved is broadcast when a user leaves or is removed from a channel
+type MemberRemoved struct {
+	UserID int `json:"user_id"`
+}
+
+// removedFromChannel tells the channel a user is gone and closes their
+// connections to it
+func removedFromChannel(channelId int, userId int) {
+	channelsHub.BroadcastEvent(int64(channelId), EventMemberRemoved, MemberRemoved{UserID: userId})
+	channelsHub.Disconnect(int64(channelId), userId)
+}
+
+func GetMembers(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		members, err := service.FetchMembers(channelId, currentUserId(c))
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "members retrieved",
+			"data":    members,
+		})
+	}
+}
+
+func JoinChannel(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		member, err := service.JoinChannel(channelId, currentUserId(c))
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		channelsHub.BroadcastEvent(int64(channelId), EventMemberJoined, member)
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"status":  "success",
+			"message": "joined channel",
+			"data":    member,
+		})
+	}
+}
+
+func LeaveChannel(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		userId := currentUserId(c)
+		if err := service.LeaveChannel(channelId, userId); err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		removedFromChannel(channelId, userId)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "left channel",
+			"data":    nil,
+		})
+	}
+}
+
+func AddMember(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		var input entities.AddMemberInput
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		// Validate input
+		validate := validator.New()
+		if err := validate.Struct(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		member, err := service.AddMember(channelId, currentUserId(c), input)
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		channelsHub.BroadcastEvent(int64(channelId), EventMemberJoined, member)
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"status":  "success",
+			"message": "member added",
+			"data":    member,
+		})
+	}
+}
+
+// UpdateMember changes the role of a member
+func UpdateMember(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		memberId, err := c.ParamsInt("userId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid user id",
+				"data":    nil,
+			})
+		}
+
+		var input entities.UpdateMemberInput
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		// Validate input
+		validate := validator.New()
+		if err := validate.Struct(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		member, err := service.UpdateMember(channelId, currentUserId(c), memberId, input)
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		channelsHub.BroadcastEvent(int64(channelId), EventMemberUpdated, member)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "member updated",
+			"data":    member,
+		})
+	}
+}
+
+func RemoveMember(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		memberId, err := c.ParamsInt("userId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid user id",
+				"data":    nil,
+			})
+		}
+
+		if err := service.RemoveMember(channelId, currentUserId(c), memberId); err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		removedFromChannel(channelId, memberId)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "member removed",
+			"data":    nil,
+		})
+	}
+}
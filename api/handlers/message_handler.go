@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/gofiber/fiber/v2"
+)
+
+func GetMessages(service chat.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return sendMessagePage(c, service, 0)
+	}
+}
+
+func GetReplies(service chat.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		parentId, err := c.ParamsInt("id")
+		if err != nil || parentId <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid message id",
+				"data":    nil,
+			})
+		}
+		return sendMessagePage(c, service, parentId)
+	}
+}
+
+// sendMessagePage responds with a page of the channel timeline, or of the
+// thread under parentId when it is set
+func sendMessagePage(c *fiber.Ctx, service chat.Service, parentId int) error {
+	channelId, err := c.ParamsInt("channelId")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "invalid channel id",
+			"data":    nil,
+		})
+	}
+
+	userId := currentUserId(c)
+	query := entities.MessageQuery{
+		Before:   c.QueryInt("before"),
+		After:    c.QueryInt("after"),
+		Limit:    c.QueryInt("limit", chat.DefaultMessageLimit),
+		ViewerID: userId,
+		ParentID: parentId,
+	}
+	if query.Before > 0 && query.After > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "only one of before or after may be set",
+			"data":    nil,
+		})
+	}
+
+	if ok, err := checkMembership(c, service, channelId, userId); !ok {
+		return err
+	}
+
+	page, err := service.FetchMessages(channelId, query)
+	if err == chat.ErrMessageNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "messages retrieved",
+		"data":    page,
+	})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"github.com/aramceballos/chat-group-server/pkg/channel"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+func KickMember(service channel.Service) fiber.Handler {
+	return moderate(service, entities.ModerationKick)
+}
+
+func MuteMember(service channel.Service) fiber.Handler {
+	return moderate(service, entities.ModerationMute)
+}
+
+func BanMember(service channel.Service) fiber.Handler {
+	return moderate(service, entities.ModerationBan)
+}
+
+func UnmuteMember(service channel.Service) fiber.Handler {
+	return revokeModeration(service, entities.ModerationMute)
+}
+
+func UnbanMember(service channel.Service) fiber.Handler {
+	return revokeModeration(service, entities.ModerationBan)
+}
+
+// moderate takes action against a member. Kicked and banned members are
+// disconnected from the channel right away; mutes apply to their next send.
+func moderate(service channel.Service, action string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		memberId, err := c.ParamsInt("userId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid user id",
+				"data":    nil,
+			})
+		}
+
+		// A reason is optional, so is the body of kicks and bans
+		var input entities.ModerationInput
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&input); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status":  "error",
+					"message": err.Error(),
+					"data":    nil,
+				})
+			}
+		}
+
+		// Validate input
+		validate := validator.New()
+		if err := validate.Struct(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		moderation, err := service.Moderate(channelId, currentUserId(c), memberId, action, input)
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		channelsHub.BroadcastEvent(int64(channelId), EventMemberModerated, moderation)
+		if action != entities.ModerationMute {
+			removedFromChannel(channelId, memberId)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "member moderated",
+			"data":    moderation,
+		})
+	}
+}
+
+func revokeModeration(service channel.Service, action string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		memberId, err := c.ParamsInt("userId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid user id",
+				"data":    nil,
+			})
+		}
+
+		moderation, err := service.RevokeModeration(channelId, currentUserId(c), memberId, action)
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		channelsHub.BroadcastEvent(int64(channelId), EventModerationRevoked, moderation)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "moderation revoked",
+			"data":    moderation,
+		})
+	}
+}
+
+// GetModerations returns the mutes and bans in force in a channel
+func GetModerations(service channel.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		moderations, err := service.FetchModerations(channelId, currentUserId(c))
+		if err != nil {
+			return c.Status(channelErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "moderations retrieved",
+			"data":    moderations,
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/gofiber/fiber/v2"
+)
+
+// pinErrorStatus maps a pin or unpin error to its response status
+func pinErrorStatus(err error) int {
+	switch err {
+	case chat.ErrMessageNotFound, chat.ErrNotPinned:
+		return fiber.StatusNotFound
+	case chat.ErrPinNotAllowed:
+		return fiber.StatusForbidden
+	case chat.ErrAlreadyPinned, chat.ErrPinLimit:
+		return fiber.StatusConflict
+	}
+	return fiber.StatusInternalServerError
+}
+
+func GetPins(service chat.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		if ok, err := checkMembership(c, service, channelId, currentUserId(c)); !ok {
+			return err
+		}
+
+		pins, err := service.FetchPins(channelId)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "pins retrieved",
+			"data":    pins,
+		})
+	}
+}
+
+func PinMessage(service chat.Service) fiber.Handler {
+	return updatePin(service, true)
+}
+
+func UnpinMessage(service chat.Service) fiber.Handler {
+	return updatePin(service, false)
+}
+
+// updatePin pins or unpins the message of the route and broadcasts the change
+func updatePin(service chat.Service, pin bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+		messageId, err := c.ParamsInt("id")
+		if err != nil || messageId <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid message id",
+				"data":    nil,
+			})
+		}
+
+		userId := currentUserId(c)
+		if ok, err := checkMembership(c, service, channelId, userId); !ok {
+			return err
+		}
+
+		var data interface{}
+		message := "message pinned"
+		eventType := EventMessagePinned
+		if pin {
+			data, err = service.PinMessage(channelId, userId, messageId)
+		} else {
+			data, err = service.UnpinMessage(channelId, userId, messageId)
+			message = "message unpinned"
+			eventType = EventMessageUnpinned
+		}
+		if err != nil {
+			return c.Status(pinErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		channelsHub.BroadcastEvent(int64(channelId), eventType, data)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": message,
+			"data":    data,
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetPollResults responds with the tally of a poll and the caller's votes
+func GetPollResults(service chat.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+		messageId, err := c.ParamsInt("id")
+		if err != nil || messageId <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid message id",
+				"data":    nil,
+			})
+		}
+
+		userId := currentUserId(c)
+		if ok, err := checkMembership(c, service, channelId, userId); !ok {
+			return err
+		}
+
+		results, err := service.FetchPollResults(channelId, userId, messageId)
+		if err == chat.ErrMessageNotFound || err == chat.ErrNotAPoll {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "poll results retrieved",
+			"data":    results,
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/gofiber/fiber/v2"
+)
+
+func GetPresence(service chat.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		if ok, err := checkMembership(c, service, channelId, currentUserId(c)); !ok {
+			return err
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "presence retrieved",
+			"data":    channelsHub.OnlineUsers(int64(channelId)),
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/aramceballos/chat-group-server/pkg/report"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// reportErrorStatus maps a report service error to its response status
+func reportErrorStatus(err error) int {
+	switch err {
+	case report.ErrMessageNotFound, report.ErrReportNotFound:
+		return fiber.StatusNotFound
+	case report.ErrNotAllowed, report.ErrCannotMuteAuthor:
+		return fiber.StatusForbidden
+	case report.ErrAlreadyReported, report.ErrNotOpen, report.ErrAlreadyResolved:
+		return fiber.StatusConflict
+	case report.ErrOwnMessage, report.ErrSystemMessage, report.ErrDirectMessage, report.ErrInvalidStatus, report.ErrInvalidDuration:
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
+
+// ReportMessage reports a message to the admins of its channel. Nothing is
+// broadcast, so neither the author nor other reporters learn about it.
+func ReportMessage(service report.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		messageId, err := c.ParamsInt("id")
+		if err != nil || messageId <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid message id",
+				"data":    nil,
+			})
+		}
+
+		var input entities.ReportInput
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		// Validate input
+		validate := validator.New()
+		if err := validate.Struct(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		created, err := service.ReportMessage(currentUserId(c), messageId, input)
+		if err != nil {
+			return c.Status(reportErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"status":  "success",
+			"message": "message reported",
+			"data":    created,
+		})
+	}
+}
+
+// GetReports returns the reports of a channel, filtered by the status query
+// parameter
+func GetReports(service report.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		reports, err := service.FetchReports(channelId, currentUserId(c), c.Query("status"))
+		if err != nil {
+			return c.Status(reportErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "reports retrieved",
+			"data":    reports,
+		})
+	}
+}
+
+func TriageReport(service report.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+		reportId, err := c.ParamsInt("id")
+		if err != nil || reportId <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid report id",
+				"data":    nil,
+			})
+		}
+
+		// A note is optional, so is the body
+		var input entities.TriageReportInput
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&input); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status":  "error",
+					"message": err.Error(),
+					"data":    nil,
+				})
+			}
+		}
+
+		// Validate input
+		validate := validator.New()
+		if err := validate.Struct(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		triaged, err := service.TriageReport(channelId, currentUserId(c), reportId, input)
+		if err != nil {
+			return c.Status(reportErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "report triaged",
+			"data":    triaged,
+		})
+	}
+}
+
+// ResolveReport closes a report. Deleting the message or muting its author
+// is broadcast like any other deletion or mute.
+func ResolveReport(service report.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+		reportId, err := c.ParamsInt("id")
+		if err != nil || reportId <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid report id",
+				"data":    nil,
+			})
+		}
+
+		var input entities.ResolveReportInput
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		// Validate input
+		validate := validator.New()
+		if err := validate.Struct(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		resolution, err := service.ResolveReport(channelId, currentUserId(c), reportId, input)
+		if err != nil {
+			return c.Status(reportErrorStatus(err)).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		if resolution.Message != nil {
+			channelsHub.BroadcastEvent(int64(channelId), EventMessageDeleted, resolution.Message)
+		}
+		if resolution.Moderation != nil {
+			channelsHub.BroadcastEvent(int64(channelId), EventMemberModerated, resolution.Moderation)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "report resolved",
+			"data":    resolution,
+		})
+	}
+}
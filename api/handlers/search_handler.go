@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/gofiber/fiber/v2"
+)
+
+const dateLayout = "2006-01-02"
+
+// parseSearchTime accepts an RFC 3339 timestamp or a date. A date in an
+// upper bound covers the whole day.
+func parseSearchTime(value string, upper bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func SearchMessages(service chat.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, err := parseSearchTime(c.Query("from"), false)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid from date",
+				"data":    nil,
+			})
+		}
+		to, err := parseSearchTime(c.Query("to"), true)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid to date",
+				"data":    nil,
+			})
+		}
+
+		page, err := service.SearchMessages(entities.SearchQuery{
+			Text:      c.Query("q"),
+			UserID:    currentUserId(c),
+			ChannelID: c.QueryInt("channel_id"),
+			AuthorID:  c.QueryInt("author_id"),
+			From:      from,
+			To:        to,
+			Limit:     c.QueryInt("limit", chat.DefaultSearchLimit),
+			Offset:    c.QueryInt("offset"),
+		})
+		if err == chat.ErrInvalidSearch {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "messages found",
+			"data":    page,
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/gofiber/fiber/v2"
+)
+
+// sseWriter writes frames as server-sent events. Messages carry their id so
+// a reconnecting EventSource resumes from Last-Event-ID. Other events have no
+// id and are not replayed: a resumed stream gets a resync event instead.
+type sseWriter struct {
+	w *bufio.Writer
+}
+
+func (s sseWriter) writeFrame(message interface{}) error {
+	var id int
+	var data []byte
+	switch m := message.(type) {
+	case outbound:
+		id = m.messageId
+		data = m.payload
+	case entities.Message:
+		id = m.ID
+	}
+	if data == nil {
+		var err error
+		if data, err = json.Marshal(message); err != nil {
+			return err
+		}
+	}
+
+	if id != 0 {
+		fmt.Fprintf(s.w, "id: %d\n", id)
+	}
+	fmt.Fprintf(s.w, "data: %s\n\n", data)
+	return s.w.Flush()
+}
+
+// resync tells a resumed stream that the events other than messages sent
+// while it was away, e.g. edits, reactions or pins, were not replayed, so the
+// client refetches the state they change
+func (s sseWriter) resync(channelId int64) error {
+	fmt.Fprintf(s.w, "event: resync\ndata: {\"type\":\"resync\",\"channel_id\":%d}\n\n", channelId)
+	return s.w.Flush()
+}
+
+// ping keeps proxies from closing an idle stream
+func (s sseWriter) ping() error {
+	fmt.Fprint(s.w, ": ping\n\n")
+	return s.w.Flush()
+}
+
+// ChannelEvents streams the events of a channel as server-sent events, for
+// clients behind proxies that block websocket upgrades. EventSource cannot
+// set headers, so the token is passed in the query string like on websockets.
+func ChannelEvents(service chat.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Query("token")
+		if token == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Token is required",
+				"data":    nil,
+			})
+		}
+		userId, err := validateToken(token)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		if ok, err := checkMembership(c, service, channelId, userId); !ok {
+			return err
+		}
+
+		// Browsers resume with Last-Event-ID, other clients may use since
+		lastEventId := c.Get("Last-Event-ID", c.Query("since"))
+		var since int
+		if lastEventId != "" {
+			since, err = strconv.Atoi(lastEventId)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Invalid last event id",
+					"data":    nil,
+				})
+			}
+		}
+
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+		c.Set("X-Accel-Buffering", "no")
+
+		remoteAddr := c.IP()
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			out := sseWriter{w}
+			client := newClient(userId, remoteAddr)
+			client.out = out
+
+			// Subscribe before replaying so nothing broadcast during the replay is missed
+			client.subscribe(channelsHub, int64(channelId))
+			log.Printf("User %d streaming channel %d from IP %s\n", userId, channelId, remoteAddr)
+
+			defer func() {
+				log.Printf("User %d stopped streaming channel %d from IP %s\n", userId, channelId, remoteAddr)
+				client.close()
+				client.unsubscribe(channelsHub, int64(channelId))
+			}()
+
+			streamEvents(service, client, out, int64(channelId), since)
+		})
+
+		return nil
+	}
+}
+
+// streamEvents writes the client's events until the stream breaks
+func streamEvents(service chat.Service, client *Client, out sseWriter, channelId int64, since int) {
+	if since > 0 {
+		if err := client.replay(service, replayRequest{channelId, since}); err != nil {
+			reap(client, ReapWriteFailed)
+			return
+		}
+		if err := out.resync(channelId); err != nil {
+			reap(client, ReapWriteFailed)
+			return
+		}
+	}
+
+	ticker := time.NewTicker(PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message := <-client.send:
+			if client.alreadyReplayed(message) {
+				continue
+			}
+			if err := client.write(message); err != nil {
+				reap(client, ReapWriteFailed)
+				return
+			}
+		case <-ticker.C:
+			if err := out.ping(); err != nil {
+				reap(client, ReapWriteFailed)
+				return
+			}
+		case <-client.quit:
+			return
+		}
+	}
+}
+
+// PostMessage sends a message through the same validation and storage as a
+// websocket frame, for clients that only receive events over SSE
+func PostMessage(service chat.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channelId, err := c.ParamsInt("channelId")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "invalid channel id",
+				"data":    nil,
+			})
+		}
+
+		userId := currentUserId(c)
+		if ok, err := checkMembership(c, service, channelId, userId); !ok {
+			return err
+		}
+
+		raw := c.Body()
+		if len(raw) > MaxMessageLength {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Message size exceeds limit",
+				"data":    nil,
+			})
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid message body JSON",
+				"data":    nil,
+			})
+		}
+
+		var ack Result
+		err = postMessage(service, userId, int64(channelId), body, raw, func(result Result) {
+			ack = result
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": ack.Message,
+				"data":    ack,
+			})
+		}
+		if !ack.Success {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": ack.Message,
+				"data":    ack,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": ack.Message,
+			"data":    ack,
+		})
+	}
+}
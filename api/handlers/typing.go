@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// Minimum time between two typing broadcasts for the same user
+	TypingThrottle = 2 * time.Second
+	// Time without typing frames after which a user stops typing
+	TypingTimeout = 5 * time.Second
+)
+
+type TypingUpdate struct {
+	UserID int  `json:"user_id"`
+	Typing bool `json:"typing"`
+}
+
+type typingKey struct {
+	channelId int64
+	userId    int
+}
+
+type typingState struct {
+	lastSent time.Time
+	timer    *time.Timer
+}
+
+// typingTracker fans typing frames out to the other members of a channel.
+// Nothing is persisted: state only lives until the expiry timer fires.
+type typingTracker struct {
+	hub    *ChannelsHub
+	mu     sync.Mutex
+	typing map[typingKey]*typingState
+}
+
+func newTypingTracker(hub *ChannelsHub) *typingTracker {
+	return &typingTracker{
+		hub:    hub,
+		typing: make(map[typingKey]*typingState),
+	}
+}
+
+// Start marks the user as typing and pushes the expiry back. Repeated frames
+// within TypingThrottle only extend the expiry. canType is only asked before
+// a broadcast, so users who may not post are never shown typing without a
+// check on every frame.
+func (t *typingTracker) Start(channelId int64, userId int, canType func() bool) {
+	key := typingKey{channelId, userId}
+
+	t.mu.Lock()
+	state, ok := t.typing[key]
+	throttled := ok && time.Since(state.lastSent) < TypingThrottle
+	t.mu.Unlock()
+	if !throttled && !canType() {
+		t.Stop(channelId, userId)
+		return
+	}
+
+	t.mu.Lock()
+	state, ok = t.typing[key]
+	if !ok {
+		state = &typingState{}
+		t.typing[key] = state
+	}
+	if state.timer != nil {
+		state.timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(TypingTimeout, func() {
+		t.expire(key, timer)
+	})
+	state.timer = timer
+
+	notify := time.Since(state.lastSent) >= TypingThrottle
+	if notify {
+		state.lastSent = time.Now()
+	}
+	t.mu.Unlock()
+
+	if notify {
+		t.broadcast(key, true)
+	}
+}
+
+// Stop clears the typing state right away, e.g. once the user sent a message
+func (t *typingTracker) Stop(channelId int64, userId int) {
+	key := typingKey{channelId, userId}
+
+	t.mu.Lock()
+	state, ok := t.typing[key]
+	if ok {
+		state.timer.Stop()
+		delete(t.typing, key)
+	}
+	t.mu.Unlock()
+
+	if ok {
+		t.broadcast(key, false)
+	}
+}
+
+func (t *typingTracker) expire(key typingKey, timer *time.Timer) {
+	t.mu.Lock()
+	state, ok := t.typing[key]
+	// A newer frame replaced the timer that fired
+	if !ok || state.timer != timer {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.typing, key)
+	t.mu.Unlock()
+
+	t.broadcast(key, false)
+}
+
+func (t *typingTracker) broadcast(key typingKey, typing bool) {
+	t.hub.BroadcastExcept(key.channelId, Event{
+		Type:      EventTyping,
+		ChannelID: key.channelId,
+		Data: TypingUpdate{
+			UserID: key.userId,
+			Typing: typing,
+		},
+	}, key.userId)
+}
+
+var typingIndicators = newTypingTracker(channelsHub)
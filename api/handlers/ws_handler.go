@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// MultiplexHandler serves a single websocket for all of a user's channels.
+// Channels are joined and left with subscribe and unsubscribe frames, and
+// every other frame names the channel it is meant for.
+func MultiplexHandler(service chat.Service) fiber.Handler {
+	return websocket.New(func(conn *websocket.Conn) {
+		userId, ok := authenticate(conn)
+		if !ok {
+			return
+		}
+
+		client := NewClient(conn, userId)
+		client.multiplexed = true
+
+		log.Printf("User %d connected from IP %s\n", userId, conn.RemoteAddr().String())
+
+		go client.writePump(service)
+
+		// Remove client from every channel it subscribed to
+		defer func() {
+			log.Printf("User %d disconnected from IP %s\n", userId, conn.RemoteAddr().String())
+			client.close()
+			client.unsubscribeAll(channelsHub)
+		}()
+
+		readFrames(conn, client, func(body map[string]interface{}, raw json.RawMessage) {
+			id, ok := frameInt(body, "channel_id")
+			if !ok {
+				client.reply(0, false, "frames must have a numeric 'channel_id' field")
+				return
+			}
+			channelId := int64(id)
+
+			switch body["type"] {
+			case "subscribe":
+				handleSubscribeFrame(service, client, channelId, body)
+			case "unsubscribe":
+				if !client.subscribed(channelId) {
+					client.reply(channelId, false, "Not subscribed to this channel")
+					return
+				}
+				client.unsubscribe(channelsHub, channelId)
+				client.reply(channelId, true, "Unsubscribed from channel")
+			default:
+				if !client.subscribed(channelId) {
+					client.reply(channelId, false, "Not subscribed to this channel")
+					return
+				}
+				processFrame(service, client, channelId, body, raw)
+			}
+		})
+	}, wsConfig)
+}
+
+func handleSubscribeFrame(service chat.Service, client *Client, channelId int64, frame map[string]interface{}) {
+	if client.subscribed(channelId) {
+		client.reply(channelId, false, "Already subscribed to this channel")
+		return
+	}
+
+	// Check if user is a member of the channel
+	exists, err := service.CheckUserMembership(int(channelId), client.userId)
+	if err != nil || !exists {
+		log.Println("error checking membership:", err)
+		client.reply(channelId, false, entities.ErrNotMember.Error())
+		return
+	}
+
+	// Subscribe before replaying so nothing broadcast during the replay is missed
+	client.subscribe(channelsHub, channelId)
+	client.reply(channelId, true, "Subscribed to channel")
+
+	if since, ok := frameInt(frame, "since"); ok && since > 0 {
+		client.requestReplay(channelId, since)
+	}
+}
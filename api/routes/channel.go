@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"github.com/aramceballos/chat-group-server/api/handlers"
+	"github.com/aramceballos/chat-group-server/pkg/channel"
+	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/aramceballos/chat-group-server/pkg/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ChannelRouter routes channel management. chatService drops the compiled
+// filters of channels whose filters change or that are deleted.
+func ChannelRouter(app fiber.Router, service channel.Service, chatService chat.Service) {
+	app.Post("/channels", middleware.Protected(), handlers.CreateChannel(service))
+	app.Get("/channels", middleware.Protected(), handlers.GetChannels(service))
+	app.Get("/channels/:channelId", middleware.Protected(), handlers.GetChannel(service))
+	app.Patch("/channels/:channelId", middleware.Protected(), handlers.UpdateChannel(service))
+	app.Post("/channels/:channelId/archive", middleware.Protected(), handlers.ArchiveChannel(service))
+	app.Post("/channels/:channelId/unarchive", middleware.Protected(), handlers.UnarchiveChannel(service))
+	app.Delete("/channels/:channelId", middleware.Protected(), handlers.DeleteChannel(service, chatService))
+	app.Post("/channels/:channelId/join", middleware.Protected(), handlers.JoinChannel(service))
+	app.Post("/channels/:channelId/leave", middleware.Protected(), handlers.LeaveChannel(service))
+	app.Get("/channels/:channelId/members", middleware.Protected(), handlers.GetMembers(service))
+	app.Post("/channels/:channelId/members", middleware.Protected(), handlers.AddMember(service))
+	app.Patch("/channels/:channelId/members/:userId", middleware.Protected(), handlers.UpdateMember(service))
+	app.Delete("/channels/:channelId/members/:userId", middleware.Protected(), handlers.RemoveMember(service))
+	app.Post("/channels/:channelId/invites", middleware.Protected(), handlers.CreateInvite(service))
+	app.Get("/channels/:channelId/invites", middleware.Protected(), handlers.GetInvites(service))
+	app.Delete("/channels/:channelId/invites/:code", middleware.Protected(), handlers.RevokeInvite(service))
+	app.Post("/invites/:code/accept", middleware.Protected(), handlers.AcceptInvite(service))
+	app.Get("/channels/:channelId/moderation", middleware.Protected(), handlers.GetModerations(service))
+	app.Post("/channels/:channelId/moderation/:userId/kick", middleware.Protected(), handlers.KickMember(service))
+	app.Post("/channels/:channelId/moderation/:userId/mute", middleware.Protected(), handlers.MuteMember(service))
+	app.Delete("/channels/:channelId/moderation/:userId/mute", middleware.Protected(), handlers.UnmuteMember(service))
+	app.Post("/channels/:channelId/moderation/:userId/ban", middleware.Protected(), handlers.BanMember(service))
+	app.Delete("/channels/:channelId/moderation/:userId/ban", middleware.Protected(), handlers.UnbanMember(service))
+	app.Get("/channels/:channelId/filters", middleware.Protected(), handlers.GetFilters(service))
+	app.Put("/channels/:channelId/filters", middleware.Protected(), handlers.UpdateFilters(service, chatService))
+}
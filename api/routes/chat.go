@@ -3,9 +3,25 @@ package routes
 import (
 	"github.com/aramceballos/chat-group-server/api/handlers"
 	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/aramceballos/chat-group-server/pkg/middleware"
 	"github.com/gofiber/fiber/v2"
 )
 
 func ChatRouter(app fiber.Router, service chat.Service) {
 	app.Get("/chat/:channelId", handlers.ChatHandler(service))
+	app.Get("/ws", handlers.MultiplexHandler(service))
+	app.Get("/channels/:channelId/messages", middleware.Protected(), handlers.GetMessages(service))
+	app.Post("/channels/:channelId/messages", middleware.Protected(), handlers.PostMessage(service))
+	app.Get("/channels/:channelId/events", handlers.ChannelEvents(service))
+	app.Get("/channels/:channelId/messages/:id/replies", middleware.Protected(), handlers.GetReplies(service))
+	app.Get("/channels/:channelId/messages/:id/poll", middleware.Protected(), handlers.GetPollResults(service))
+	app.Get("/channels/:channelId/presence", middleware.Protected(), handlers.GetPresence(service))
+	app.Get("/channels/:channelId/pins", middleware.Protected(), handlers.GetPins(service))
+	app.Put("/channels/:channelId/pins/:id", middleware.Protected(), handlers.PinMessage(service))
+	app.Delete("/channels/:channelId/pins/:id", middleware.Protected(), handlers.UnpinMessage(service))
+	app.Get("/channels/:channelId/flags", middleware.Protected(), handlers.GetFlags(service))
+	app.Post("/channels/:channelId/flags/:id/review", middleware.Protected(), handlers.ReviewFlag(service))
+	app.Put("/channels/:channelId/read", middleware.Protected(), handlers.MarkRead(service))
+	app.Get("/me/unread", middleware.Protected(), handlers.GetUnreadCounts(service))
+	app.Get("/search/messages", middleware.Protected(), handlers.SearchMessages(service))
 }
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/aramceballos/chat-group-server/api/handlers"
+	"github.com/aramceballos/chat-group-server/pkg/dm"
+	"github.com/aramceballos/chat-group-server/pkg/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+func DMRouter(app fiber.Router, service dm.Service) {
+	app.Post("/dms", middleware.Protected(), handlers.OpenDM(service))
+	app.Get("/dms", middleware.Protected(), handlers.GetDMs(service))
+	app.Get("/blocks", middleware.Protected(), handlers.GetBlockedUsers(service))
+	app.Put("/blocks/:userId", middleware.Protected(), handlers.BlockUser(service))
+	app.Delete("/blocks/:userId", middleware.Protected(), handlers.UnblockUser(service))
+}
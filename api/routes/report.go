@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/aramceballos/chat-group-server/api/handlers"
+	"github.com/aramceballos/chat-group-server/pkg/middleware"
+	"github.com/aramceballos/chat-group-server/pkg/report"
+	"github.com/gofiber/fiber/v2"
+)
+
+func ReportRouter(app fiber.Router, service report.Service) {
+	app.Post("/messages/:id/report", middleware.Protected(), handlers.ReportMessage(service))
+	app.Get("/channels/:channelId/reports", middleware.Protected(), handlers.GetReports(service))
+	app.Post("/channels/:channelId/reports/:id/triage", middleware.Protected(), handlers.TriageReport(service))
+	app.Post("/channels/:channelId/reports/:id/resolve", middleware.Protected(), handlers.ResolveReport(service))
+}
@@ -9,12 +9,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aramceballos/chat-group-server/api/handlers"
 	"github.com/aramceballos/chat-group-server/api/routes"
+	"github.com/aramceballos/chat-group-server/pkg/broker"
+	"github.com/aramceballos/chat-group-server/pkg/channel"
 	"github.com/aramceballos/chat-group-server/pkg/chat"
+	"github.com/aramceballos/chat-group-server/pkg/dm"
+	"github.com/aramceballos/chat-group-server/pkg/report"
 	"github.com/aramceballos/chat-group-server/pkg/user"
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/expvar"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
 
 	_ "github.com/lib/pq"
@@ -23,6 +29,17 @@ import (
 // Database instance
 var db *sql.DB
 
+func dataSourceName(
+	dbHost string,
+	dbPort string,
+	dbUser string,
+	dbPassword string,
+	dbName string,
+	sslMode string,
+) string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", dbHost, dbPort, dbUser, dbPassword, dbName, sslMode)
+}
+
 func Connect(
 	dbHost string,
 	dbPort string,
@@ -32,7 +49,7 @@ func Connect(
 	sslMode string,
 ) error {
 	var err error
-	db, err = sql.Open("postgres", fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", dbHost, dbPort, dbUser, dbPassword, dbName, sslMode))
+	db, err = sql.Open("postgres", dataSourceName(dbHost, dbPort, dbUser, dbPassword, dbName, sslMode))
 	if err != nil {
 		return err
 	}
@@ -97,17 +114,56 @@ func main() {
 
 	routes.UserRouter(v1, userService)
 
-	v1.Use("/chat", func(c *fiber.Ctx) error {
+	requireUpgrade := func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
 			return c.Next()
 		}
 		return c.SendStatus(fiber.StatusUpgradeRequired)
-	})
+	}
+	v1.Use("/chat", requireUpgrade)
+	v1.Use("/ws", requireUpgrade)
+
+	// Replicas behind a load balancer share broadcasts through Postgres
+	if os.Getenv("BROKER") == "postgres" {
+		b, err := broker.NewPostgresBroker(db, dataSourceName(dbHost, dbPort, dbUser, dbPassword, dbName, sslMode))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer b.Close()
+		handlers.SetBroker(b)
+	}
 
 	chatRepo := chat.NewRepository(db)
 	defer chatRepo.Close()
 	chatService := chat.NewService(chatRepo)
 	routes.ChatRouter(v1, chatService)
 
+	channelRepo := channel.NewRepository(db)
+	defer channelRepo.Close()
+	channelService := channel.NewService(channelRepo)
+	routes.ChannelRouter(v1, channelService, chatService)
+
+	dmRepo := dm.NewRepository(db)
+	defer dmRepo.Close()
+	dmService := dm.NewService(dmRepo)
+	routes.DMRouter(v1, dmService)
+
+	reportRepo := report.NewRepository(db)
+	defer reportRepo.Close()
+	reportService := report.NewService(reportRepo)
+	routes.ReportRouter(v1, reportService)
+
+	// Runtime counters such as reaped websocket clients are served at
+	// /debug/vars on a separate, internal address, never on the public port
+	if debugAddr := os.Getenv("DEBUG_ADDR"); debugAddr != "" {
+		debug := fiber.New(fiber.Config{DisableStartupMessage: true})
+		debug.Use(expvar.New())
+		go func() {
+			if err := debug.Listen(debugAddr); err != nil {
+				log.Printf("error serving debug vars on %s: %v", debugAddr, err)
+			}
+		}()
+	}
+
 	app.Listen(":4000")
 }
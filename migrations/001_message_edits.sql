@@ -0,0 +1,8 @@
+-- Edited and soft-deleted messages
+ALTER TABLE messages
+    ADD COLUMN edited_at TIMESTAMPTZ,
+    ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- Per-channel role, admins may edit and delete any message in the channel
+ALTER TABLE memberships
+    ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'member';
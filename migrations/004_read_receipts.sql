@@ -0,0 +1,2 @@
+ALTER TABLE memberships
+    ADD COLUMN last_read_message_id INTEGER NOT NULL DEFAULT 0;
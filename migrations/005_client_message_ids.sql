@@ -0,0 +1,5 @@
+ALTER TABLE messages
+    ADD COLUMN client_msg_id VARCHAR(64);
+
+CREATE UNIQUE INDEX messages_user_id_client_msg_id_idx ON messages (user_id, client_msg_id)
+    WHERE client_msg_id IS NOT NULL;
@@ -0,0 +1,6 @@
+-- Broadcasts too large for a NOTIFY payload, read by every replica
+CREATE TABLE hub_broadcasts (
+    id BIGSERIAL PRIMARY KEY,
+    payload BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,6 @@
+ALTER TABLE messages
+    ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+        to_tsvector('english', COALESCE(body->>'content', '') || ' ' || COALESCE(body->>'filename', ''))
+    ) STORED;
+
+CREATE INDEX messages_search_vector_idx ON messages USING GIN (search_vector);
@@ -0,0 +1,8 @@
+CREATE TABLE message_pins (
+    message_id INTEGER PRIMARY KEY REFERENCES messages (id) ON DELETE CASCADE,
+    channel_id INTEGER NOT NULL,
+    pinned_by INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    pinned_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX message_pins_channel_id_idx ON message_pins (channel_id, pinned_at);
@@ -0,0 +1,3 @@
+-- Roles are owner, admin, member or read_only. A user is a member of a
+-- channel at most once, so memberships can be added idempotently.
+CREATE UNIQUE INDEX IF NOT EXISTS memberships_channel_user_idx ON memberships (channel_id, user_id);
@@ -0,0 +1,13 @@
+-- Direct messages are channels between two users, keyed by their ids as
+-- "<lower id>:<higher id>" so a pair always resolves to the same one
+ALTER TABLE channels
+    ADD COLUMN IF NOT EXISTS dm_key VARCHAR(40) UNIQUE;
+
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    blocked_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (blocker_id, blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_blocks_blocked_idx ON user_blocks (blocked_id);
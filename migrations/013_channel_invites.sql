@@ -0,0 +1,14 @@
+-- Invite links to join a channel. max_uses and expires_at are NULL for
+-- invites without a limit.
+CREATE TABLE IF NOT EXISTS channel_invites (
+    code VARCHAR(32) PRIMARY KEY,
+    channel_id INTEGER NOT NULL REFERENCES channels (id) ON DELETE CASCADE,
+    created_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    max_uses INTEGER CHECK (max_uses > 0),
+    uses INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS channel_invites_channel_idx ON channel_invites (channel_id, created_at DESC);
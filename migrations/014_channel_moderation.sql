@@ -0,0 +1,16 @@
+-- Kicks, mutes and bans of channel members, with who did it and why. Mutes
+-- end at expires_at, bans last until revoked. Kicks are only recorded.
+CREATE TABLE IF NOT EXISTS channel_moderations (
+    id SERIAL PRIMARY KEY,
+    channel_id INTEGER NOT NULL REFERENCES channels (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('kick', 'mute', 'ban')),
+    reason TEXT NOT NULL DEFAULT '',
+    actor_id INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ,
+    revoked_by INTEGER REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS channel_moderations_active_idx ON channel_moderations (channel_id, user_id, action) WHERE revoked_at IS NULL;
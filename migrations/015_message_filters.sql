@@ -0,0 +1,23 @@
+-- The filters the messages of a channel go through before they are stored,
+-- as set by its admins. Channels without a row are not filtered.
+CREATE TABLE IF NOT EXISTS channel_filters (
+    channel_id INTEGER PRIMARY KEY REFERENCES channels (id) ON DELETE CASCADE,
+    config JSONB NOT NULL DEFAULT '{}',
+    updated_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Messages flagged by the filters, queued for review by the channel admins
+-- until they are approved or removed
+CREATE TABLE IF NOT EXISTS message_flags (
+    id SERIAL PRIMARY KEY,
+    message_id INTEGER NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    channel_id INTEGER NOT NULL,
+    reasons TEXT[] NOT NULL DEFAULT '{}',
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'removed')),
+    reviewed_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS message_flags_pending_idx ON message_flags (channel_id, created_at) WHERE status = 'pending';
@@ -0,0 +1,22 @@
+-- Messages reported by members of their channel, for its admins to triage and
+-- resolve. A user reports a message once.
+CREATE TABLE IF NOT EXISTS reports (
+    id SERIAL PRIMARY KEY,
+    message_id INTEGER NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    channel_id INTEGER NOT NULL,
+    reporter_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    category VARCHAR(20) NOT NULL CHECK (category IN ('spam', 'harassment', 'hate_speech', 'violence', 'sexual_content', 'misinformation', 'other')),
+    details TEXT NOT NULL DEFAULT '',
+    status VARCHAR(10) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'triaged', 'resolved')),
+    triaged_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    triaged_at TIMESTAMPTZ,
+    triage_note TEXT NOT NULL DEFAULT '',
+    resolution VARCHAR(20) CHECK (resolution IN ('dismiss', 'delete_message', 'mute_author')),
+    resolution_note TEXT NOT NULL DEFAULT '',
+    resolved_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (message_id, reporter_id)
+);
+
+CREATE INDEX IF NOT EXISTS reports_channel_idx ON reports (channel_id, status, created_at);
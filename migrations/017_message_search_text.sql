@@ -0,0 +1,24 @@
+-- The searchable text of a message body: every field its schema screens as
+-- text, so that search covers captions, link previews, code and polls too.
+-- Search snippets are highlighted from the same text.
+CREATE OR REPLACE FUNCTION message_search_text(body JSONB) RETURNS TEXT
+LANGUAGE SQL IMMUTABLE AS $$
+    SELECT array_to_string(ARRAY[
+        body->>'content',
+        body->>'filename',
+        body->>'caption',
+        body->>'title',
+        body->>'description',
+        body->>'question',
+        (SELECT string_agg(option, ' ') FROM jsonb_array_elements_text(body->'options') AS option)
+    ], ' ')
+$$;
+
+ALTER TABLE messages DROP COLUMN search_vector;
+
+ALTER TABLE messages
+    ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+        to_tsvector('english', message_search_text(body))
+    ) STORED;
+
+CREATE INDEX messages_search_vector_idx ON messages USING GIN (search_vector);
@@ -0,0 +1,16 @@
+package broker
+
+// Handler receives every payload published on the broker
+type Handler func(payload []byte)
+
+// Broker fans hub broadcasts out to every server replica. Each published
+// payload is handed exactly once to the handlers of every replica, including
+// the one that published it.
+type Broker interface {
+	Publish(payload []byte) error
+	Subscribe(handler Handler)
+	// OnMissed registers a function called when payloads may have been lost,
+	// e.g. while the broker reconnected, so subscribers can resynchronize
+	OnMissed(missed func())
+	Close() error
+}
@@ -0,0 +1,42 @@
+package broker
+
+import "sync"
+
+type memoryBroker struct {
+	handlers   []Handler
+	handlersMu sync.RWMutex
+}
+
+// NewMemoryBroker returns a broker for a single replica that delivers
+// payloads synchronously to its handlers
+func NewMemoryBroker() Broker {
+	return &memoryBroker{}
+}
+
+func (b *memoryBroker) Publish(payload []byte) error {
+	b.handlersMu.RLock()
+	handlers := b.handlers
+	b.handlersMu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(payload)
+	}
+	return nil
+}
+
+func (b *memoryBroker) Subscribe(handler Handler) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// OnMissed does nothing, payloads are delivered synchronously and never lost
+func (b *memoryBroker) OnMissed(missed func()) {}
+
+// Close drops the handlers, so a replaced broker stops delivering
+func (b *memoryBroker) Close() error {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+	b.handlers = nil
+	return nil
+}
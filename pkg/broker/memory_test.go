@@ -0,0 +1,36 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	t.Run("delivers to every handler", func(t *testing.T) {
+		b := NewMemoryBroker()
+		var first, second [][]byte
+		b.Subscribe(func(payload []byte) { first = append(first, payload) })
+		b.Subscribe(func(payload []byte) { second = append(second, payload) })
+
+		assert.NoError(t, b.Publish([]byte("hello")))
+		assert.Equal(t, [][]byte{[]byte("hello")}, first)
+		assert.Equal(t, [][]byte{[]byte("hello")}, second)
+	})
+
+	t.Run("closed broker drops its handlers", func(t *testing.T) {
+		b := NewMemoryBroker()
+		var received [][]byte
+		b.Subscribe(func(payload []byte) { received = append(received, payload) })
+
+		assert.NoError(t, b.Close())
+		assert.NoError(t, b.Publish([]byte("hello")))
+		assert.Empty(t, received)
+	})
+
+	t.Run("publish without handlers", func(t *testing.T) {
+		b := NewMemoryBroker()
+		assert.NoError(t, b.Publish([]byte("hello")))
+		assert.NoError(t, b.Close())
+	})
+}
@@ -0,0 +1,175 @@
+package broker
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// NotifyChannel is the Postgres channel broadcasts are published on
+	NotifyChannel = "chat_broadcasts"
+	// Postgres rejects NOTIFY payloads of 8000 bytes or more. Larger ones are
+	// stored in hub_broadcasts and only their id is notified.
+	MaxNotifyPayload = 7900
+	// How long stored payloads are kept for replicas to read them
+	StoredPayloadTTL = 5 * time.Minute
+
+	refPrefix = "ref:"
+)
+
+type postgresBroker struct {
+	db         *sql.DB
+	listener   *pq.Listener
+	handlers   []Handler
+	missed     []func()
+	handlersMu sync.RWMutex
+	quit       chan struct{}
+}
+
+// NewPostgresBroker returns a broker that fans payloads out to every replica
+// connected to the same database through LISTEN/NOTIFY. connInfo is the
+// connection string used for the dedicated listening connection.
+func NewPostgresBroker(db *sql.DB, connInfo string) (Broker, error) {
+	b := &postgresBroker{
+		db:   db,
+		quit: make(chan struct{}),
+	}
+
+	b.listener = pq.NewListener(connInfo, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("[postgres broker error] listener event %d: %s", event, err.Error())
+		}
+	})
+	if err := b.listener.Listen(NotifyChannel); err != nil {
+		b.listener.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", NotifyChannel, err)
+	}
+
+	go b.listen()
+	go b.cleanup()
+
+	return b, nil
+}
+
+func (b *postgresBroker) Publish(payload []byte) error {
+	message := string(payload)
+	if len(payload) >= MaxNotifyPayload {
+		var id int64
+		err := b.db.QueryRow("INSERT INTO hub_broadcasts (payload) VALUES ($1) RETURNING id", payload).Scan(&id)
+		if err != nil {
+			return err
+		}
+		message = refPrefix + strconv.FormatInt(id, 10)
+	}
+
+	_, err := b.db.Exec("SELECT pg_notify($1, $2)", NotifyChannel, message)
+	return err
+}
+
+func (b *postgresBroker) Subscribe(handler Handler) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *postgresBroker) OnMissed(missed func()) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+	b.missed = append(b.missed, missed)
+}
+
+func (b *postgresBroker) Close() error {
+	close(b.quit)
+	return b.listener.Close()
+}
+
+func (b *postgresBroker) listen() {
+	// Make sure the connection is still alive when nothing is published
+	ticker := time.NewTicker(90 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case notification := <-b.listener.Notify:
+			// A nil notification means the connection was re-established
+			if notification == nil {
+				log.Printf("[postgres broker error] listener reconnected, broadcasts sent meanwhile were missed")
+				b.notifyMissed()
+				continue
+			}
+			payload, err := b.resolve(notification.Extra)
+			if err != nil {
+				log.Printf("[postgres broker error] error reading stored payload: %s", err.Error())
+				continue
+			}
+			b.deliver(payload)
+		case <-ticker.C:
+			if err := b.listener.Ping(); err != nil {
+				log.Printf("[postgres broker error] listener ping: %s", err.Error())
+			}
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+// resolve returns the payload of a notification, reading it from
+// hub_broadcasts when it was too large to be notified
+func (b *postgresBroker) resolve(message string) ([]byte, error) {
+	if !strings.HasPrefix(message, refPrefix) {
+		return []byte(message), nil
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(message, refPrefix), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	var payload []byte
+	err = b.db.QueryRow("SELECT payload FROM hub_broadcasts WHERE id = $1", id).Scan(&payload)
+	return payload, err
+}
+
+func (b *postgresBroker) deliver(payload []byte) {
+	b.handlersMu.RLock()
+	handlers := b.handlers
+	b.handlersMu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(payload)
+	}
+}
+
+func (b *postgresBroker) notifyMissed() {
+	b.handlersMu.RLock()
+	missed := b.missed
+	b.handlersMu.RUnlock()
+
+	for _, fn := range missed {
+		fn()
+	}
+}
+
+// cleanup deletes stored payloads every replica had time to read
+func (b *postgresBroker) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_, err := b.db.Exec("DELETE FROM hub_broadcasts WHERE created_at < $1", time.Now().Add(-StoredPayloadTTL))
+			if err != nil {
+				log.Printf("[postgres broker error] error cleaning up stored payloads: %s", err.Error())
+			}
+		case <-b.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package broker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresPublish(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	b := &postgresBroker{db: db}
+
+	t.Run("small payload is notified", func(t *testing.T) {
+		mock.ExpectExec("SELECT pg_notify\\(\\$1, \\$2\\)").
+			WithArgs(NotifyChannel, "{}").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, b.Publish([]byte("{}")))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("large payload is stored", func(t *testing.T) {
+		payload := []byte(strings.Repeat("a", MaxNotifyPayload))
+		mock.ExpectQuery("INSERT INTO hub_broadcasts \\(payload\\) VALUES \\(\\$1\\) RETURNING id").
+			WithArgs(payload).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+		mock.ExpectExec("SELECT pg_notify\\(\\$1, \\$2\\)").
+			WithArgs(NotifyChannel, "ref:42").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, b.Publish(payload))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectExec("SELECT pg_notify").
+			WillReturnError(errors.New("database error"))
+
+		assert.Error(t, b.Publish([]byte("{}")))
+	})
+}
+
+func TestPostgresResolve(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	b := &postgresBroker{db: db}
+
+	t.Run("inline payload", func(t *testing.T) {
+		payload, err := b.resolve("{}")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("{}"), payload)
+	})
+
+	t.Run("stored payload", func(t *testing.T) {
+		mock.ExpectQuery("SELECT payload FROM hub_broadcasts WHERE id = \\$1").
+			WithArgs(int64(42)).
+			WillReturnRows(sqlmock.NewRows([]string{"payload"}).AddRow([]byte("{\"large\":true}")))
+
+		payload, err := b.resolve("ref:42")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("{\"large\":true}"), payload)
+	})
+
+	t.Run("invalid reference", func(t *testing.T) {
+		_, err := b.resolve("ref:abc")
+		assert.Error(t, err)
+	})
+}
+
+func TestPostgresDeliver(t *testing.T) {
+	b := &postgresBroker{}
+	var received []byte
+	b.Subscribe(func(payload []byte) { received = payload })
+
+	b.deliver([]byte("hello"))
+	assert.Equal(t, []byte("hello"), received)
+}
@@ -0,0 +1,428 @@
+package channel
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/aramceballos/chat-group-server/pkg/store"
+)
+
+type Repository interface {
+	CreateChannel(userId int, input entities.CreateChannelInput) (entities.Channel, error)
+	FetchChannel(channelId int) (entities.Channel, error)
+	FetchUserChannels(userId int) ([]entities.Channel, error)
+	UpdateChannel(channelId int, input entities.UpdateChannelInput) (entities.Channel, error)
+	SetArchived(channelId int, archived bool) (entities.Channel, error)
+	DeleteChannel(channelId int) (bool, error)
+	FetchMembershipRole(channelId int, userId int) (string, error)
+	FetchMembers(channelId int) ([]entities.Member, error)
+	FetchMember(channelId int, userId int) (entities.Member, error)
+	AddMember(channelId int, userId int, role string) (bool, error)
+	SetMemberRole(channelId int, userId int, role string) (bool, error)
+	RemoveMember(channelId int, userId int) (bool, error)
+	FetchUser(userId int) (entities.User, error)
+	CreateInvite(channelId int, userId int, code string, maxUses *int, expiresAt *time.Time) (entities.Invite, error)
+	FetchInvite(code string) (entities.Invite, error)
+	FetchInvites(channelId int) ([]entities.Invite, error)
+	RevokeInvite(channelId int, code string) (bool, error)
+	RedeemInvite(code string, userId int, msgBody []byte) (entities.Message, bool, error)
+	AddModeration(channelId int, userId int, actorId int, action string, reason string, expiresAt *time.Time) (entities.Moderation, error)
+	RevokeModeration(channelId int, userId int, actorId int, action string) (entities.Moderation, error)
+	FetchActiveModerations(channelId int) ([]entities.Moderation, error)
+	IsBanned(channelId int, userId int) (bool, error)
+	FetchFilterConfig(channelId int) (entities.FilterConfig, error)
+	SetFilterConfig(channelId int, userId int, config entities.FilterConfig) error
+	Close() error
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (r *repository) Close() error {
+	return nil
+}
+
+// channelColumns selects a channel, read by scanChannel
+const channelColumns = "c.id, c.name, c.description, COALESCE(c.created_by, 0), c.created_at, c.archived_at, c.dm_key IS NOT NULL"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanChannel(row rowScanner) (entities.Channel, error) {
+	channel := entities.Channel{}
+	err := row.Scan(&channel.ID, &channel.Name, &channel.Description, &channel.CreatedBy, &channel.CreatedAt, &channel.ArchivedAt, &channel.Direct)
+	if err != nil {
+		return entities.Channel{}, err
+	}
+	return channel, nil
+}
+
+// CreateChannel creates a channel owned by userId
+func (r *repository) CreateChannel(userId int, input entities.CreateChannelInput) (entities.Channel, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return entities.Channel{}, err
+	}
+	defer tx.Rollback()
+
+	channel, err := scanChannel(tx.QueryRow("INSERT INTO channels AS c (name, description, created_by) VALUES ($1, $2, $3) RETURNING "+channelColumns, input.Name, input.Description, userId))
+	if err != nil {
+		return entities.Channel{}, err
+	}
+
+	if _, err := tx.Exec("INSERT INTO memberships (channel_id, user_id, role) VALUES ($1, $2, $3)", channel.ID, userId, entities.RoleOwner); err != nil {
+		return entities.Channel{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entities.Channel{}, err
+	}
+	return channel, nil
+}
+
+func (r *repository) FetchChannel(channelId int) (entities.Channel, error) {
+	return scanChannel(r.db.QueryRow("SELECT "+channelColumns+" FROM channels c WHERE c.id = $1", channelId))
+}
+
+// FetchUserChannels returns the channels the user is a member of, other
+// than direct messages
+func (r *repository) FetchUserChannels(userId int) ([]entities.Channel, error) {
+	rows, err := r.db.Query("SELECT "+channelColumns+" FROM channels c JOIN memberships ms ON ms.channel_id = c.id WHERE ms.user_id = $1 AND c.dm_key IS NULL ORDER BY c.name, c.id", userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []entities.Channel{}
+	for rows.Next() {
+		channel, err := scanChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return channels, nil
+}
+
+// UpdateChannel changes the fields set in input
+func (r *repository) UpdateChannel(channelId int, input entities.UpdateChannelInput) (entities.Channel, error) {
+	return scanChannel(r.db.QueryRow("UPDATE channels AS c SET name = COALESCE($2, c.name), description = COALESCE($3, c.description) WHERE c.id = $1 RETURNING "+channelColumns, channelId, input.Name, input.Description))
+}
+
+// SetArchived archives or restores a channel. Archiving keeps the original
+// archive time of an archived channel.
+func (r *repository) SetArchived(channelId int, archived bool) (entities.Channel, error) {
+	return scanChannel(r.db.QueryRow("UPDATE channels AS c SET archived_at = CASE WHEN $2 THEN COALESCE(c.archived_at, NOW()) END WHERE c.id = $1 RETURNING "+channelColumns, channelId, archived))
+}
+
+// DeleteChannel deletes a channel with its messages and memberships, and
+// reports whether it existed
+func (r *repository) DeleteChannel(channelId int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Reactions, pins, votes and replies go with the messages
+	if _, err := tx.Exec("DELETE FROM messages WHERE channel_id = $1", channelId); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("DELETE FROM memberships WHERE channel_id = $1", channelId); err != nil {
+		return false, err
+	}
+	result, err := tx.Exec("DELETE FROM channels WHERE id = $1", channelId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *repository) FetchMembershipRole(channelId int, userId int) (string, error) {
+	var role string
+	err := r.db.QueryRow("SELECT role FROM memberships WHERE channel_id = $1 AND user_id = $2", channelId, userId).Scan(&role)
+	return role, err
+}
+
+// memberColumns selects a member, read by scanMember
+const memberColumns = "u.id, u.name, u.avatar_url, u.created_at, ms.role"
+
+func scanMember(row rowScanner) (entities.Member, error) {
+	member := entities.Member{}
+	err := row.Scan(&member.User.ID, &member.User.Name, &member.User.AvatarURL, &member.User.CreatedAt, &member.Role)
+	if err != nil {
+		return entities.Member{}, err
+	}
+	return member, nil
+}
+
+// FetchMembers returns the members of a channel by name
+func (r *repository) FetchMembers(channelId int) ([]entities.Member, error) {
+	rows, err := r.db.Query("SELECT "+memberColumns+" FROM memberships ms JOIN users u ON u.id = ms.user_id WHERE ms.channel_id = $1 ORDER BY u.name, u.id", channelId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []entities.Member{}
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (r *repository) FetchMember(channelId int, userId int) (entities.Member, error) {
+	return scanMember(r.db.QueryRow("SELECT "+memberColumns+" FROM memberships ms JOIN users u ON u.id = ms.user_id WHERE ms.channel_id = $1 AND ms.user_id = $2", channelId, userId))
+}
+
+// AddMember adds a user to a channel and reports whether they were added,
+// which they are not if they do not exist or are already a member
+func (r *repository) AddMember(channelId int, userId int, role string) (bool, error) {
+	result, err := r.db.Exec("INSERT INTO memberships (channel_id, user_id, role) SELECT $1, u.id, $3 FROM users u WHERE u.id = $2 ON CONFLICT (channel_id, user_id) DO NOTHING", channelId, userId, role)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SetMemberRole changes the role of a member and reports whether they are one
+func (r *repository) SetMemberRole(channelId int, userId int, role string) (bool, error) {
+	result, err := r.db.Exec("UPDATE memberships SET role = $3 WHERE channel_id = $1 AND user_id = $2", channelId, userId, role)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// RemoveMember removes a user from a channel and reports whether they were
+// a member
+func (r *repository) RemoveMember(channelId int, userId int) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM memberships WHERE channel_id = $1 AND user_id = $2", channelId, userId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *repository) FetchUser(userId int) (entities.User, error) {
+	user := entities.User{}
+	err := r.db.QueryRow("SELECT id, name, avatar_url, created_at FROM users WHERE id = $1", userId).Scan(&user.ID, &user.Name, &user.AvatarURL, &user.CreatedAt)
+	return user, err
+}
+
+// inviteColumns selects an invite, read by scanInvite
+const inviteColumns = "i.code, i.channel_id, COALESCE(i.created_by, 0), i.max_uses, i.uses, i.expires_at, i.created_at"
+
+func scanInvite(row rowScanner) (entities.Invite, error) {
+	invite := entities.Invite{}
+	err := row.Scan(&invite.Code, &invite.ChannelID, &invite.CreatedBy, &invite.MaxUses, &invite.Uses, &invite.ExpiresAt, &invite.CreatedAt)
+	if err != nil {
+		return entities.Invite{}, err
+	}
+	return invite, nil
+}
+
+func (r *repository) CreateInvite(channelId int, userId int, code string, maxUses *int, expiresAt *time.Time) (entities.Invite, error) {
+	return scanInvite(r.db.QueryRow("INSERT INTO channel_invites AS i (code, channel_id, created_by, max_uses, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING "+inviteColumns, code, channelId, userId, maxUses, expiresAt))
+}
+
+// FetchInvite returns an invite that was not revoked
+func (r *repository) FetchInvite(code string) (entities.Invite, error) {
+	return scanInvite(r.db.QueryRow("SELECT "+inviteColumns+" FROM channel_invites i WHERE i.code = $1 AND i.revoked_at IS NULL", code))
+}
+
+// FetchInvites returns the invites of a channel that were not revoked,
+// latest first
+func (r *repository) FetchInvites(channelId int) ([]entities.Invite, error) {
+	rows, err := r.db.Query("SELECT "+inviteColumns+" FROM channel_invites i WHERE i.channel_id = $1 AND i.revoked_at IS NULL ORDER BY i.created_at DESC", channelId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []entities.Invite{}
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invites, nil
+}
+
+// RevokeInvite revokes an invite of the channel and reports whether there
+// was one to revoke
+func (r *repository) RevokeInvite(channelId int, code string) (bool, error) {
+	result, err := r.db.Exec("UPDATE channel_invites SET revoked_at = NOW() WHERE code = $1 AND channel_id = $2 AND revoked_at IS NULL", code, channelId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// RedeemInvite uses an invite to make userId a member of its channel and
+// stores msgBody as their message announcing it. It returns sql.ErrNoRows
+// if the invite is revoked, expired or used up. joined is false, and the
+// invite left unused, if the user already is a member.
+func (r *repository) RedeemInvite(code string, userId int, msgBody []byte) (entities.Message, bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return entities.Message{}, false, err
+	}
+	defer tx.Rollback()
+
+	var channelId int
+	err = tx.QueryRow("UPDATE channel_invites SET uses = uses + 1 WHERE code = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW()) AND (max_uses IS NULL OR uses < max_uses) RETURNING channel_id", code).Scan(&channelId)
+	if err != nil {
+		return entities.Message{}, false, err
+	}
+
+	result, err := tx.Exec("INSERT INTO memberships (channel_id, user_id, role) VALUES ($1, $2, $3) ON CONFLICT (channel_id, user_id) DO NOTHING", channelId, userId, entities.RoleMember)
+	if err != nil {
+		return entities.Message{}, false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return entities.Message{}, false, err
+	}
+	if affected == 0 {
+		return entities.Message{}, false, nil
+	}
+
+	message, err := store.InsertSystemMessage(tx, channelId, userId, msgBody)
+	if err != nil {
+		return entities.Message{}, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entities.Message{}, false, err
+	}
+	return message, true, nil
+}
+
+// Condition of the moderations in force
+const activeModeration = "mo.revoked_at IS NULL AND (mo.expires_at IS NULL OR mo.expires_at > NOW())"
+
+// AddModeration records a moderation of userId by actorId. Kicks and bans
+// remove the membership of userId, and a mute or ban replaces the one of the
+// same kind in force.
+func (r *repository) AddModeration(channelId int, userId int, actorId int, action string, reason string, expiresAt *time.Time) (entities.Moderation, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return entities.Moderation{}, err
+	}
+	defer tx.Rollback()
+
+	moderation, err := store.AddModeration(tx, channelId, userId, actorId, action, reason, expiresAt)
+	if err != nil {
+		return entities.Moderation{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entities.Moderation{}, err
+	}
+	return moderation, nil
+}
+
+// RevokeModeration lifts the mute or ban of userId in force. It returns
+// sql.ErrNoRows if there is none.
+func (r *repository) RevokeModeration(channelId int, userId int, actorId int, action string) (entities.Moderation, error) {
+	return store.ScanModeration(r.db.QueryRow("UPDATE channel_moderations AS mo SET revoked_at = NOW(), revoked_by = $3 WHERE mo.channel_id = $1 AND mo.user_id = $2 AND mo.action = $4 AND "+activeModeration+" RETURNING "+store.ModerationColumns, channelId, userId, actorId, action))
+}
+
+// FetchActiveModerations returns the mutes and bans in force in a channel,
+// latest first
+func (r *repository) FetchActiveModerations(channelId int) ([]entities.Moderation, error) {
+	rows, err := r.db.Query("SELECT "+store.ModerationColumns+" FROM channel_moderations mo WHERE mo.channel_id = $1 AND mo.action <> $2 AND "+activeModeration+" ORDER BY mo.created_at DESC", channelId, entities.ModerationKick)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moderations := []entities.Moderation{}
+	for rows.Next() {
+		moderation, err := store.ScanModeration(rows)
+		if err != nil {
+			return nil, err
+		}
+		moderations = append(moderations, moderation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return moderations, nil
+}
+
+func (r *repository) IsBanned(channelId int, userId int) (bool, error) {
+	var banned bool
+	err := r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM channel_moderations mo WHERE mo.channel_id = $1 AND mo.user_id = $2 AND mo.action = $3 AND "+activeModeration+")", channelId, userId, entities.ModerationBan).Scan(&banned)
+	return banned, err
+}
+
+// FetchFilterConfig returns the filters of a channel, none if they were never
+// set
+func (r *repository) FetchFilterConfig(channelId int) (entities.FilterConfig, error) {
+	return store.FetchFilterConfig(r.db, channelId)
+}
+
+// SetFilterConfig replaces the filters of a channel
+func (r *repository) SetFilterConfig(channelId int, userId int, config entities.FilterConfig) error {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec("INSERT INTO channel_filters (channel_id, config, updated_by) VALUES ($1, $2::jsonb, $3) ON CONFLICT (channel_id) DO UPDATE SET config = EXCLUDED.config, updated_by = EXCLUDED.updated_by, updated_at = NOW()", channelId, raw, userId)
+	return err
+}
@@ -0,0 +1,478 @@
+package channel
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+var channelRowColumns = []string{"id", "name", "description", "created_by", "created_at", "archived_at", "direct"}
+
+func setupMockRepository(t *testing.T) (*sql.DB, sqlmock.Sqlmock, Repository) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	repo := NewRepository(db)
+	assert.NotNil(t, repo)
+	return db, mock, repo
+}
+
+func TestCreateChannel(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	input := entities.CreateChannelInput{Name: "general", Description: "Company wide"}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO channels AS c \\(name, description, created_by\\) VALUES \\(\\$1, \\$2, \\$3\\) RETURNING c.id").
+			WithArgs("general", "Company wide", 2).
+			WillReturnRows(sqlmock.NewRows(channelRowColumns).AddRow(1, "general", "Company wide", 2, "2025-07-19T10:30:00Z", nil, false))
+		mock.ExpectExec("INSERT INTO memberships \\(channel_id, user_id, role\\) VALUES \\(\\$1, \\$2, \\$3\\)").
+			WithArgs(1, 2, entities.RoleOwner).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		created, err := repo.CreateChannel(2, input)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.Channel{ID: 1, Name: "general", Description: "Company wide", CreatedBy: 2, CreatedAt: "2025-07-19T10:30:00Z"}, created)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("membership error rolls back", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO channels").
+			WillReturnRows(sqlmock.NewRows(channelRowColumns).AddRow(1, "general", "Company wide", 2, "2025-07-19T10:30:00Z", nil, false))
+		mock.ExpectExec("INSERT INTO memberships").
+			WillReturnError(errors.New("database error"))
+		mock.ExpectRollback()
+
+		_, err := repo.CreateChannel(2, input)
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestFetchChannel(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery("SELECT c.id, c.name, c.description, COALESCE\\(c.created_by, 0\\), c.created_at, c.archived_at, c.dm_key IS NOT NULL FROM channels c WHERE c.id = \\$1").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(channelRowColumns).AddRow(1, "general", "", 0, "2025-07-19T10:30:00Z", "2025-07-20T10:30:00Z", false))
+
+		found, err := repo.FetchChannel(1)
+		assert.NoError(t, err)
+		assert.Equal(t, "general", found.Name)
+		assert.Equal(t, "2025-07-20T10:30:00Z", *found.ArchivedAt)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM channels c WHERE c.id = \\$1").
+			WithArgs(9).
+			WillReturnRows(sqlmock.NewRows(channelRowColumns))
+
+		_, err := repo.FetchChannel(9)
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+}
+
+func TestFetchUserChannels(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM channels c JOIN memberships ms ON ms.channel_id = c.id WHERE ms.user_id = \\$1 AND c.dm_key IS NULL").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(channelRowColumns).
+			AddRow(1, "general", "", 2, "2025-07-19T10:30:00Z", nil, false).
+			AddRow(2, "random", "", 3, "2025-07-19T10:31:00Z", nil, false))
+
+	channels, err := repo.FetchUserChannels(2)
+	assert.NoError(t, err)
+	assert.Len(t, channels, 2)
+	assert.Equal(t, "random", channels[1].Name)
+}
+
+func TestUpdateChannel(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	name := "announcements"
+	mock.ExpectQuery("UPDATE channels AS c SET name = COALESCE\\(\\$2, c.name\\), description = COALESCE\\(\\$3, c.description\\) WHERE c.id = \\$1").
+		WithArgs(1, &name, nil).
+		WillReturnRows(sqlmock.NewRows(channelRowColumns).AddRow(1, "announcements", "", 2, "2025-07-19T10:30:00Z", nil, false))
+
+	updated, err := repo.UpdateChannel(1, entities.UpdateChannelInput{Name: &name})
+	assert.NoError(t, err)
+	assert.Equal(t, "announcements", updated.Name)
+}
+
+func TestSetArchived(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE channels AS c SET archived_at = CASE WHEN \\$2 THEN COALESCE\\(c.archived_at, NOW\\(\\)\\) END WHERE c.id = \\$1").
+		WithArgs(1, true).
+		WillReturnRows(sqlmock.NewRows(channelRowColumns).AddRow(1, "general", "", 2, "2025-07-19T10:30:00Z", "2025-07-20T10:30:00Z", false))
+
+	archived, err := repo.SetArchived(1, true)
+	assert.NoError(t, err)
+	assert.NotNil(t, archived.ArchivedAt)
+}
+
+func TestDeleteChannel(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	t.Run("deleted", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM messages WHERE channel_id = \\$1").
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 10))
+		mock.ExpectExec("DELETE FROM memberships WHERE channel_id = \\$1").
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec("DELETE FROM channels WHERE id = \\$1").
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		deleted, err := repo.DeleteChannel(1)
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("missing channel", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM messages").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("DELETE FROM memberships").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("DELETE FROM channels").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		deleted, err := repo.DeleteChannel(9)
+		assert.NoError(t, err)
+		assert.False(t, deleted)
+	})
+}
+
+func TestFetchMembershipRole(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT role FROM memberships WHERE channel_id = \\$1 AND user_id = \\$2").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(entities.RoleOwner))
+
+	role, err := repo.FetchMembershipRole(1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, entities.RoleOwner, role)
+}
+
+func TestFetchMembers(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT u.id, u.name, u.avatar_url, u.created_at, ms.role FROM memberships ms JOIN users u ON u.id = ms.user_id WHERE ms.channel_id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "avatar_url", "created_at", "role"}).
+			AddRow(2, "Jane", "", "2025-07-19T10:30:00Z", entities.RoleOwner).
+			AddRow(3, "John", "", "2025-07-19T10:31:00Z", entities.RoleReadOnly))
+
+	members, err := repo.FetchMembers(1)
+	assert.NoError(t, err)
+	assert.Len(t, members, 2)
+	assert.Equal(t, int64(3), members[1].User.ID)
+	assert.Equal(t, entities.RoleReadOnly, members[1].Role)
+}
+
+func TestAddMember(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	t.Run("added", func(t *testing.T) {
+		mock.ExpectExec("INSERT INTO memberships \\(channel_id, user_id, role\\) SELECT \\$1, u.id, \\$3 FROM users u WHERE u.id = \\$2 ON CONFLICT \\(channel_id, user_id\\) DO NOTHING").
+			WithArgs(1, 3, entities.RoleMember).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		added, err := repo.AddMember(1, 3, entities.RoleMember)
+		assert.NoError(t, err)
+		assert.True(t, added)
+	})
+
+	t.Run("not added", func(t *testing.T) {
+		mock.ExpectExec("INSERT INTO memberships").
+			WithArgs(1, 3, entities.RoleMember).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		added, err := repo.AddMember(1, 3, entities.RoleMember)
+		assert.NoError(t, err)
+		assert.False(t, added)
+	})
+}
+
+func TestSetMemberRole(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE memberships SET role = \\$3 WHERE channel_id = \\$1 AND user_id = \\$2").
+		WithArgs(1, 3, entities.RoleAdmin).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	changed, err := repo.SetMemberRole(1, 3, entities.RoleAdmin)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+}
+
+func TestRemoveMember(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	t.Run("removed", func(t *testing.T) {
+		mock.ExpectExec("DELETE FROM memberships WHERE channel_id = \\$1 AND user_id = \\$2").
+			WithArgs(1, 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		removed, err := repo.RemoveMember(1, 3)
+		assert.NoError(t, err)
+		assert.True(t, removed)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mock.ExpectExec("DELETE FROM memberships").
+			WithArgs(1, 3).
+			WillReturnError(errors.New("database error"))
+
+		_, err := repo.RemoveMember(1, 3)
+		assert.Error(t, err)
+	})
+}
+
+var inviteRowColumns = []string{"code", "channel_id", "created_by", "max_uses", "uses", "expires_at", "created_at"}
+
+func TestCreateInvite(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	maxUses := 5
+	mock.ExpectQuery("INSERT INTO channel_invites AS i \\(code, channel_id, created_by, max_uses, expires_at\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5\\) RETURNING i.code").
+		WithArgs("abc", 1, 2, &maxUses, nil).
+		WillReturnRows(sqlmock.NewRows(inviteRowColumns).AddRow("abc", 1, 2, 5, 0, nil, "2025-07-19T10:30:00Z"))
+
+	invite, err := repo.CreateInvite(1, 2, "abc", &maxUses, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", invite.Code)
+	assert.Equal(t, 5, *invite.MaxUses)
+	assert.Nil(t, invite.ExpiresAt)
+}
+
+func TestFetchInvites(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM channel_invites i WHERE i.channel_id = \\$1 AND i.revoked_at IS NULL").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(inviteRowColumns).
+			AddRow("abc", 1, 2, nil, 3, "2025-07-20T10:30:00Z", "2025-07-19T10:30:00Z"))
+
+	invites, err := repo.FetchInvites(1)
+	assert.NoError(t, err)
+	assert.Len(t, invites, 1)
+	assert.Nil(t, invites[0].MaxUses)
+	assert.Equal(t, "2025-07-20T10:30:00Z", *invites[0].ExpiresAt)
+}
+
+func TestRevokeInvite(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE channel_invites SET revoked_at = NOW\\(\\) WHERE code = \\$1 AND channel_id = \\$2 AND revoked_at IS NULL").
+		WithArgs("abc", 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	revoked, err := repo.RevokeInvite(1, "abc")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func TestRedeemInvite(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	body := []byte(`{"type":"system","event":"member_joined","content":"Jane joined the channel"}`)
+
+	t.Run("joined", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE channel_invites SET uses = uses \\+ 1 WHERE code = \\$1 AND revoked_at IS NULL (.+) RETURNING channel_id").
+			WithArgs("abc").
+			WillReturnRows(sqlmock.NewRows([]string{"channel_id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO memberships \\(channel_id, user_id, role\\) VALUES \\(\\$1, \\$2, \\$3\\) ON CONFLICT").
+			WithArgs(1, 3, entities.RoleMember).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO messages \\(channel_id, user_id, body\\) VALUES \\(\\$1, \\$2, \\$3::jsonb\\)").
+			WithArgs(1, 3, body).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "channel_id", "body", "created_at"}).AddRow(9, 3, 1, body, "2025-07-19T10:30:00Z"))
+		mock.ExpectCommit()
+
+		message, joined, err := repo.RedeemInvite("abc", 3, body)
+		assert.NoError(t, err)
+		assert.True(t, joined)
+		assert.Equal(t, 9, message.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already a member", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE channel_invites").
+			WithArgs("abc").
+			WillReturnRows(sqlmock.NewRows([]string{"channel_id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO memberships").
+			WithArgs(1, 3, entities.RoleMember).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		_, joined, err := repo.RedeemInvite("abc", 3, body)
+		assert.NoError(t, err)
+		assert.False(t, joined)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid invite", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE channel_invites").
+			WithArgs("abc").
+			WillReturnRows(sqlmock.NewRows([]string{"channel_id"}))
+		mock.ExpectRollback()
+
+		_, _, err := repo.RedeemInvite("abc", 3, body)
+		assert.Equal(t, sql.ErrNoRows, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+var moderationRowColumns = []string{"id", "channel_id", "user_id", "action", "reason", "actor_id", "expires_at", "created_at", "revoked_at", "revoked_by"}
+
+func TestAddModeration(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	t.Run("ban", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM memberships WHERE channel_id = \\$1 AND user_id = \\$2").
+			WithArgs(1, 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE channel_moderations SET revoked_at = NOW\\(\\), revoked_by = \\$3 WHERE channel_id = \\$1 AND user_id = \\$2 AND action = \\$4 AND revoked_at IS NULL").
+			WithArgs(1, 3, 2, entities.ModerationBan).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("INSERT INTO channel_moderations AS mo \\(channel_id, user_id, action, reason, actor_id, expires_at\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6\\) RETURNING mo.id").
+			WithArgs(1, 3, entities.ModerationBan, "spam", 2, nil).
+			WillReturnRows(sqlmock.NewRows(moderationRowColumns).AddRow(4, 1, 3, entities.ModerationBan, "spam", 2, nil, "2025-07-19T10:30:00Z", nil, nil))
+		mock.ExpectCommit()
+
+		moderation, err := repo.AddModeration(1, 3, 2, entities.ModerationBan, "spam", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, moderation.ID)
+		assert.Equal(t, "spam", moderation.Reason)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("mute keeps the membership", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE channel_moderations SET revoked_at").
+			WithArgs(1, 3, 2, entities.ModerationMute).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO channel_moderations").
+			WillReturnRows(sqlmock.NewRows(moderationRowColumns).AddRow(5, 1, 3, entities.ModerationMute, "", 2, "2025-07-19T11:30:00Z", "2025-07-19T10:30:00Z", nil, nil))
+		mock.ExpectCommit()
+
+		moderation, err := repo.AddModeration(1, 3, 2, entities.ModerationMute, "", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "2025-07-19T11:30:00Z", *moderation.ExpiresAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("kick is not replaced", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM memberships").
+			WithArgs(1, 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO channel_moderations").
+			WillReturnRows(sqlmock.NewRows(moderationRowColumns).AddRow(6, 1, 3, entities.ModerationKick, "", 2, nil, "2025-07-19T10:30:00Z", nil, nil))
+		mock.ExpectCommit()
+
+		_, err := repo.AddModeration(1, 3, 2, entities.ModerationKick, "", nil)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRevokeModeration(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE channel_moderations AS mo SET revoked_at = NOW\\(\\), revoked_by = \\$3 WHERE mo.channel_id = \\$1 AND mo.user_id = \\$2 AND mo.action = \\$4 AND mo.revoked_at IS NULL").
+		WithArgs(1, 3, 2, entities.ModerationMute).
+		WillReturnRows(sqlmock.NewRows(moderationRowColumns))
+
+	_, err := repo.RevokeModeration(1, 3, 2, entities.ModerationMute)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestIsBanned(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM channel_moderations mo WHERE mo.channel_id = \\$1 AND mo.user_id = \\$2 AND mo.action = \\$3").
+		WithArgs(1, 3, entities.ModerationBan).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	banned, err := repo.IsBanned(1, 3)
+	assert.NoError(t, err)
+	assert.True(t, banned)
+}
+
+func TestFetchFilterConfig(t *testing.T) {
+	t.Run("set", func(t *testing.T) {
+		db, mock, repo := setupMockRepository(t)
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT config FROM channel_filters WHERE channel_id = \\$1").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"config"}).AddRow([]byte(`{"repeated_chars":{"max":5,"action":"flag"}}`)))
+
+		config, err := repo.FetchFilterConfig(1)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.FilterConfig{RepeatedChars: &entities.RepeatFilterConfig{Max: 5, Action: "flag"}}, config)
+	})
+
+	t.Run("never set", func(t *testing.T) {
+		db, mock, repo := setupMockRepository(t)
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT config FROM channel_filters").
+			WithArgs(1).
+			WillReturnError(sql.ErrNoRows)
+
+		config, err := repo.FetchFilterConfig(1)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.FilterConfig{}, config)
+	})
+}
+
+func TestSetFilterConfig(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO channel_filters \\(channel_id, config, updated_by\\) VALUES \\(\\$1, \\$2::jsonb, \\$3\\) ON CONFLICT \\(channel_id\\) DO UPDATE").
+		WithArgs(1, []byte(`{"invite_links":{"action":"reject"}}`), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.SetFilterConfig(1, 2, entities.FilterConfig{InviteLinks: &entities.InviteFilterConfig{Action: "reject"}})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,661 @@
+package channel
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/aramceballos/chat-group-server/pkg/schema"
+)
+
+type Service interface {
+	CreateChannel(userId int, input entities.CreateChannelInput) (entities.Channel, error)
+	FetchChannel(channelId int, userId int) (entities.Channel, error)
+	FetchUserChannels(userId int) ([]entities.Channel, error)
+	UpdateChannel(channelId int, userId int, input entities.UpdateChannelInput) (entities.Channel, error)
+	ArchiveChannel(channelId int, userId int) (entities.Channel, error)
+	UnarchiveChannel(channelId int, userId int) (entities.Channel, error)
+	DeleteChannel(channelId int, userId int) error
+	FetchMembers(channelId int, userId int) ([]entities.Member, error)
+	JoinChannel(channelId int, userId int) (entities.Member, error)
+	LeaveChannel(channelId int, userId int) error
+	AddMember(channelId int, userId int, input entities.AddMemberInput) (entities.Member, error)
+	UpdateMember(channelId int, userId int, memberId int, input entities.UpdateMemberInput) (entities.Member, error)
+	RemoveMember(channelId int, userId int, memberId int) error
+	CreateInvite(channelId int, userId int, input entities.CreateInviteInput) (entities.Invite, error)
+	FetchInvites(channelId int, userId int) ([]entities.Invite, error)
+	RevokeInvite(channelId int, userId int, code string) error
+	AcceptInvite(code string, userId int) (entities.Member, entities.Message, error)
+	Moderate(channelId int, userId int, memberId int, action string, input entities.ModerationInput) (entities.Moderation, error)
+	RevokeModeration(channelId int, userId int, memberId int, action string) (entities.Moderation, error)
+	FetchModerations(channelId int, userId int) ([]entities.Moderation, error)
+	FetchFilters(channelId int, userId int) (entities.FilterConfig, error)
+	UpdateFilters(channelId int, userId int, config entities.FilterConfig) (entities.FilterConfig, error)
+}
+
+var (
+	ErrChannelNotFound  = errors.New("channel not found")
+	ErrNotMember        = entities.ErrNotMember
+	ErrNotAllowed       = errors.New("you are not allowed to manage this channel")
+	ErrChannelArchived  = errors.New("channel is archived")
+	ErrNothingToUpdate  = errors.New("nothing to update")
+	ErrAlreadyMember    = errors.New("user is already a member of this channel")
+	ErrMemberNotFound   = errors.New("member not found")
+	ErrUserNotFound     = errors.New("user not found")
+	ErrOwnerCannotLeave = errors.New("the owner cannot leave the channel, delete it instead")
+	ErrDirectMessage    = errors.New("direct message members cannot be changed")
+	ErrInviteNotFound   = errors.New("invite not found")
+	ErrInviteExpired    = errors.New("invite has expired")
+	ErrInviteUsedUp     = errors.New("invite has reached its maximum number of uses")
+	ErrBanned           = errors.New("user is banned from this channel")
+	ErrInvalidDuration  = errors.New("mutes must have a duration")
+	ErrNotModerated     = errors.New("user is not muted or banned")
+	ErrSelfModeration   = errors.New("you cannot lift your own mute or ban")
+)
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{
+		repo,
+	}
+}
+
+func (s *service) CreateChannel(userId int, input entities.CreateChannelInput) (entities.Channel, error) {
+	channel, err := s.repo.CreateChannel(userId, input)
+	if err != nil {
+		log.Printf("[channel service error] error creating channel: %s", err.Error())
+		return entities.Channel{}, fmt.Errorf("error creating channel")
+	}
+	return channel, nil
+}
+
+// fetchRole returns the role of userId in the channel, failing if they are
+// not a member
+func (s *service) fetchRole(channelId int, userId int) (string, error) {
+	role, err := s.repo.FetchMembershipRole(channelId, userId)
+	if err == sql.ErrNoRows {
+		return "", ErrNotMember
+	}
+	if err != nil {
+		log.Printf("[channel service error] error fetching membership role: %s", err.Error())
+		return "", fmt.Errorf("error fetching membership role")
+	}
+	return role, nil
+}
+
+func (s *service) fetchChannel(channelId int) (entities.Channel, error) {
+	channel, err := s.repo.FetchChannel(channelId)
+	if err == sql.ErrNoRows {
+		return entities.Channel{}, ErrChannelNotFound
+	}
+	if err != nil {
+		log.Printf("[channel service error] error fetching channel: %s", err.Error())
+		return entities.Channel{}, fmt.Errorf("error fetching channel")
+	}
+	return channel, nil
+}
+
+// authorizeManagement checks that userId is an admin or the owner of the
+// channel and returns it
+func (s *service) authorizeManagement(channelId int, userId int) (entities.Channel, error) {
+	role, err := s.fetchRole(channelId, userId)
+	if err != nil {
+		return entities.Channel{}, err
+	}
+	if !entities.CanManage(role) {
+		return entities.Channel{}, ErrNotAllowed
+	}
+	return s.fetchChannel(channelId)
+}
+
+func (s *service) FetchChannel(channelId int, userId int) (entities.Channel, error) {
+	if _, err := s.fetchRole(channelId, userId); err != nil {
+		return entities.Channel{}, err
+	}
+	return s.fetchChannel(channelId)
+}
+
+func (s *service) FetchUserChannels(userId int) ([]entities.Channel, error) {
+	channels, err := s.repo.FetchUserChannels(userId)
+	if err != nil {
+		log.Printf("[channel service error] error fetching channels: %s", err.Error())
+		return nil, fmt.Errorf("error fetching channels")
+	}
+	return channels, nil
+}
+
+func (s *service) UpdateChannel(channelId int, userId int, input entities.UpdateChannelInput) (entities.Channel, error) {
+	if input.Name == nil && input.Description == nil {
+		return entities.Channel{}, ErrNothingToUpdate
+	}
+
+	channel, err := s.authorizeManagement(channelId, userId)
+	if err != nil {
+		return entities.Channel{}, err
+	}
+	if channel.ArchivedAt != nil {
+		return entities.Channel{}, ErrChannelArchived
+	}
+
+	channel, err = s.repo.UpdateChannel(channelId, input)
+	if err == sql.ErrNoRows {
+		return entities.Channel{}, ErrChannelNotFound
+	}
+	if err != nil {
+		log.Printf("[channel service error] error updating channel: %s", err.Error())
+		return entities.Channel{}, fmt.Errorf("error updating channel")
+	}
+	return channel, nil
+}
+
+func (s *service) ArchiveChannel(channelId int, userId int) (entities.Channel, error) {
+	return s.setArchived(channelId, userId, true)
+}
+
+func (s *service) UnarchiveChannel(channelId int, userId int) (entities.Channel, error) {
+	return s.setArchived(channelId, userId, false)
+}
+
+func (s *service) setArchived(channelId int, userId int, archived bool) (entities.Channel, error) {
+	if _, err := s.authorizeManagement(channelId, userId); err != nil {
+		return entities.Channel{}, err
+	}
+
+	channel, err := s.repo.SetArchived(channelId, archived)
+	if err == sql.ErrNoRows {
+		return entities.Channel{}, ErrChannelNotFound
+	}
+	if err != nil {
+		log.Printf("[channel service error] error archiving channel: %s", err.Error())
+		return entities.Channel{}, fmt.Errorf("error archiving channel")
+	}
+	return channel, nil
+}
+
+// DeleteChannel deletes a channel and its history. Only its owner may.
+func (s *service) DeleteChannel(channelId int, userId int) error {
+	role, err := s.fetchRole(channelId, userId)
+	if err != nil {
+		return err
+	}
+	if role != entities.RoleOwner {
+		return ErrNotAllowed
+	}
+
+	deleted, err := s.repo.DeleteChannel(channelId)
+	if err != nil {
+		log.Printf("[channel service error] error deleting channel: %s", err.Error())
+		return fmt.Errorf("error deleting channel")
+	}
+	if !deleted {
+		return ErrChannelNotFound
+	}
+	return nil
+}
+
+func (s *service) FetchMembers(channelId int, userId int) ([]entities.Member, error) {
+	if _, err := s.fetchRole(channelId, userId); err != nil {
+		return nil, err
+	}
+
+	members, err := s.repo.FetchMembers(channelId)
+	if err != nil {
+		log.Printf("[channel service error] error fetching members: %s", err.Error())
+		return nil, fmt.Errorf("error fetching members")
+	}
+	return members, nil
+}
+
+func (s *service) fetchMember(channelId int, userId int) (entities.Member, error) {
+	member, err := s.repo.FetchMember(channelId, userId)
+	if err == sql.ErrNoRows {
+		return entities.Member{}, ErrMemberNotFound
+	}
+	if err != nil {
+		log.Printf("[channel service error] error fetching member: %s", err.Error())
+		return entities.Member{}, fmt.Errorf("error fetching member")
+	}
+	return member, nil
+}
+
+// addMember adds userId to a channel that is not archived with role
+func (s *service) addMember(channelId int, userId int, role string) (entities.Member, error) {
+	channel, err := s.fetchChannel(channelId)
+	if err != nil {
+		return entities.Member{}, err
+	}
+	if channel.Direct {
+		return entities.Member{}, ErrDirectMessage
+	}
+	if channel.ArchivedAt != nil {
+		return entities.Member{}, ErrChannelArchived
+	}
+	if err := s.checkNotBanned(channelId, userId); err != nil {
+		return entities.Member{}, err
+	}
+
+	_, err = s.repo.FetchMembershipRole(channelId, userId)
+	if err == nil {
+		return entities.Member{}, ErrAlreadyMember
+	}
+	if err != sql.ErrNoRows {
+		log.Printf("[channel service error] error fetching membership role: %s", err.Error())
+		return entities.Member{}, fmt.Errorf("error adding member")
+	}
+
+	added, err := s.repo.AddMember(channelId, userId, role)
+	if err != nil {
+		log.Printf("[channel service error] error adding member: %s", err.Error())
+		return entities.Member{}, fmt.Errorf("error adding member")
+	}
+	// The membership check above ruled out a duplicate, so the user is missing
+	if !added {
+		return entities.Member{}, ErrUserNotFound
+	}
+	return s.fetchMember(channelId, userId)
+}
+
+// JoinChannel makes userId a member of the channel
+func (s *service) JoinChannel(channelId int, userId int) (entities.Member, error) {
+	return s.addMember(channelId, userId, entities.RoleMember)
+}
+
+// LeaveChannel removes userId from the channel. The owner cannot leave.
+func (s *service) LeaveChannel(channelId int, userId int) error {
+	role, err := s.fetchRole(channelId, userId)
+	if err != nil {
+		return err
+	}
+	if role == entities.RoleOwner {
+		return ErrOwnerCannotLeave
+	}
+	channel, err := s.fetchChannel(channelId)
+	if err != nil {
+		return err
+	}
+	if channel.Direct {
+		return ErrDirectMessage
+	}
+	return s.removeMember(channelId, userId, ErrNotMember)
+}
+
+// AddMember adds a user to the channel on behalf of userId. Members may only
+// be given a role below the one of userId.
+func (s *service) AddMember(channelId int, userId int, input entities.AddMemberInput) (entities.Member, error) {
+	role := input.Role
+	if role == "" {
+		role = entities.RoleMember
+	}
+
+	actorRole, err := s.fetchRole(channelId, userId)
+	if err != nil {
+		return entities.Member{}, err
+	}
+	if !entities.CanManage(actorRole) || !entities.Outranks(actorRole, role) {
+		return entities.Member{}, ErrNotAllowed
+	}
+
+	return s.addMember(channelId, input.UserID, role)
+}
+
+// authorizeMemberChange checks that userId may change the membership of
+// memberId, which takes managing the channel and outranking them, and
+// returns the role of userId
+func (s *service) authorizeMemberChange(channelId int, userId int, memberId int) (string, error) {
+	actorRole, err := s.fetchRole(channelId, userId)
+	if err != nil {
+		return "", err
+	}
+	if !entities.CanManage(actorRole) {
+		return "", ErrNotAllowed
+	}
+
+	memberRole, err := s.repo.FetchMembershipRole(channelId, memberId)
+	if err == sql.ErrNoRows {
+		return "", ErrMemberNotFound
+	}
+	if err != nil {
+		log.Printf("[channel service error] error fetching membership role: %s", err.Error())
+		return "", fmt.Errorf("error fetching membership role")
+	}
+	if !entities.Outranks(actorRole, memberRole) {
+		return "", ErrNotAllowed
+	}
+	return actorRole, nil
+}
+
+// UpdateMember changes the role of memberId. Only members of a lower rank
+// than userId can be changed, to a role of a lower rank.
+func (s *service) UpdateMember(channelId int, userId int, memberId int, input entities.UpdateMemberInput) (entities.Member, error) {
+	actorRole, err := s.authorizeMemberChange(channelId, userId, memberId)
+	if err != nil {
+		return entities.Member{}, err
+	}
+	if !entities.Outranks(actorRole, input.Role) {
+		return entities.Member{}, ErrNotAllowed
+	}
+
+	updated, err := s.repo.SetMemberRole(channelId, memberId, input.Role)
+	if err != nil {
+		log.Printf("[channel service error] error updating member: %s", err.Error())
+		return entities.Member{}, fmt.Errorf("error updating member")
+	}
+	if !updated {
+		return entities.Member{}, ErrMemberNotFound
+	}
+	return s.fetchMember(channelId, memberId)
+}
+
+// RemoveMember removes memberId from the channel on behalf of userId
+func (s *service) RemoveMember(channelId int, userId int, memberId int) error {
+	if _, err := s.authorizeMemberChange(channelId, userId, memberId); err != nil {
+		return err
+	}
+	return s.removeMember(channelId, memberId, ErrMemberNotFound)
+}
+
+// removeMember deletes a membership, failing with notFound if there is none
+func (s *service) removeMember(channelId int, userId int, notFound error) error {
+	removed, err := s.repo.RemoveMember(channelId, userId)
+	if err != nil {
+		log.Printf("[channel service error] error removing member: %s", err.Error())
+		return fmt.Errorf("error removing member")
+	}
+	if !removed {
+		return notFound
+	}
+	return nil
+}
+
+// generateInviteCode returns a random code of 12 URL safe characters
+func generateInviteCode() (string, error) {
+	code := make([]byte, 9)
+	if _, err := rand.Read(code); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(code), nil
+}
+
+// CreateInvite creates an invite link to the channel. Only its owner and
+// admins may.
+func (s *service) CreateInvite(channelId int, userId int, input entities.CreateInviteInput) (entities.Invite, error) {
+	channel, err := s.authorizeManagement(channelId, userId)
+	if err != nil {
+		return entities.Invite{}, err
+	}
+	if channel.ArchivedAt != nil {
+		return entities.Invite{}, ErrChannelArchived
+	}
+
+	var expiresAt *time.Time
+	if input.ExpiresIn != nil {
+		expiry := time.Now().Add(time.Duration(*input.ExpiresIn) * time.Second)
+		expiresAt = &expiry
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		log.Printf("[channel service error] error generating invite code: %s", err.Error())
+		return entities.Invite{}, fmt.Errorf("error creating invite")
+	}
+
+	invite, err := s.repo.CreateInvite(channelId, userId, code, input.MaxUses, expiresAt)
+	if err != nil {
+		log.Printf("[channel service error] error creating invite: %s", err.Error())
+		return entities.Invite{}, fmt.Errorf("error creating invite")
+	}
+	return invite, nil
+}
+
+func (s *service) FetchInvites(channelId int, userId int) ([]entities.Invite, error) {
+	if _, err := s.authorizeManagement(channelId, userId); err != nil {
+		return nil, err
+	}
+
+	invites, err := s.repo.FetchInvites(channelId)
+	if err != nil {
+		log.Printf("[channel service error] error fetching invites: %s", err.Error())
+		return nil, fmt.Errorf("error fetching invites")
+	}
+	return invites, nil
+}
+
+func (s *service) RevokeInvite(channelId int, userId int, code string) error {
+	if _, err := s.authorizeManagement(channelId, userId); err != nil {
+		return err
+	}
+
+	revoked, err := s.repo.RevokeInvite(channelId, code)
+	if err != nil {
+		log.Printf("[channel service error] error revoking invite: %s", err.Error())
+		return fmt.Errorf("error revoking invite")
+	}
+	if !revoked {
+		return ErrInviteNotFound
+	}
+	return nil
+}
+
+func (s *service) fetchInvite(code string) (entities.Invite, error) {
+	invite, err := s.repo.FetchInvite(code)
+	if err == sql.ErrNoRows {
+		return entities.Invite{}, ErrInviteNotFound
+	}
+	if err != nil {
+		log.Printf("[channel service error] error fetching invite: %s", err.Error())
+		return entities.Invite{}, fmt.Errorf("error fetching invite")
+	}
+	return invite, nil
+}
+
+// AcceptInvite makes userId a member of the channel of an invite. It returns
+// the new member and the system message announcing them, to be broadcast.
+func (s *service) AcceptInvite(code string, userId int) (entities.Member, entities.Message, error) {
+	invite, err := s.fetchInvite(code)
+	if err != nil {
+		return entities.Member{}, entities.Message{}, err
+	}
+	channel, err := s.fetchChannel(invite.ChannelID)
+	if err != nil {
+		return entities.Member{}, entities.Message{}, err
+	}
+	if channel.ArchivedAt != nil {
+		return entities.Member{}, entities.Message{}, ErrChannelArchived
+	}
+	if err := s.checkNotBanned(invite.ChannelID, userId); err != nil {
+		return entities.Member{}, entities.Message{}, err
+	}
+
+	user, err := s.repo.FetchUser(userId)
+	if err != nil {
+		log.Printf("[channel service error] error fetching user: %s", err.Error())
+		return entities.Member{}, entities.Message{}, fmt.Errorf("error accepting invite")
+	}
+	msgBody, err := json.Marshal(schema.System{
+		Type:    "system",
+		Event:   "member_joined",
+		Content: user.Name + " joined the channel",
+	})
+	if err != nil {
+		return entities.Member{}, entities.Message{}, fmt.Errorf("error accepting invite")
+	}
+
+	message, joined, err := s.repo.RedeemInvite(code, userId, msgBody)
+	if err == sql.ErrNoRows {
+		return entities.Member{}, entities.Message{}, s.invalidInviteError(code)
+	}
+	if err != nil {
+		log.Printf("[channel service error] error redeeming invite: %s", err.Error())
+		return entities.Member{}, entities.Message{}, fmt.Errorf("error accepting invite")
+	}
+	if !joined {
+		return entities.Member{}, entities.Message{}, ErrAlreadyMember
+	}
+	message.User = user
+
+	member, err := s.fetchMember(invite.ChannelID, userId)
+	if err != nil {
+		return entities.Member{}, entities.Message{}, err
+	}
+	return member, message, nil
+}
+
+// invalidInviteError explains why an invite could not be redeemed
+func (s *service) invalidInviteError(code string) error {
+	invite, err := s.fetchInvite(code)
+	if err != nil {
+		return err
+	}
+	if invite.MaxUses != nil && invite.Uses >= *invite.MaxUses {
+		return ErrInviteUsedUp
+	}
+	return ErrInviteExpired
+}
+
+// checkNotBanned fails with ErrBanned if userId is banned from the channel
+func (s *service) checkNotBanned(channelId int, userId int) error {
+	banned, err := s.repo.IsBanned(channelId, userId)
+	if err != nil {
+		log.Printf("[channel service error] error checking bans: %s", err.Error())
+		return fmt.Errorf("error checking bans")
+	}
+	if banned {
+		return ErrBanned
+	}
+	return nil
+}
+
+// Moderate kicks, mutes or bans memberId on behalf of userId, who must
+// outrank them. Kicked users may join again, banned ones not until unbanned.
+func (s *service) Moderate(channelId int, userId int, memberId int, action string, input entities.ModerationInput) (entities.Moderation, error) {
+	var expiresAt *time.Time
+	if action == entities.ModerationMute {
+		if input.Duration == 0 {
+			return entities.Moderation{}, ErrInvalidDuration
+		}
+		expiry := time.Now().Add(time.Duration(input.Duration) * time.Second)
+		expiresAt = &expiry
+	}
+
+	var err error
+	if action == entities.ModerationBan {
+		err = s.authorizeModeration(channelId, userId, memberId)
+	} else {
+		_, err = s.authorizeMemberChange(channelId, userId, memberId)
+	}
+	if err != nil {
+		return entities.Moderation{}, err
+	}
+
+	moderation, err := s.repo.AddModeration(channelId, memberId, userId, action, input.Reason, expiresAt)
+	if err != nil {
+		log.Printf("[channel service error] error moderating member: %s", err.Error())
+		return entities.Moderation{}, fmt.Errorf("error moderating member")
+	}
+	return moderation, nil
+}
+
+// authorizeModeration checks that userId may ban memberId, or lift their mute
+// or ban. Unlike other member changes the target need not be a member, so
+// users who left or were kicked can be kept out too. Members must be
+// outranked by userId.
+func (s *service) authorizeModeration(channelId int, userId int, memberId int) error {
+	actorRole, err := s.fetchRole(channelId, userId)
+	if err != nil {
+		return err
+	}
+	if !entities.CanManage(actorRole) {
+		return ErrNotAllowed
+	}
+
+	memberRole, err := s.repo.FetchMembershipRole(channelId, memberId)
+	if err == sql.ErrNoRows {
+		if _, err := s.repo.FetchUser(memberId); err == sql.ErrNoRows {
+			return ErrUserNotFound
+		} else if err != nil {
+			log.Printf("[channel service error] error fetching user: %s", err.Error())
+			return fmt.Errorf("error fetching user")
+		}
+		return nil
+	}
+	if err != nil {
+		log.Printf("[channel service error] error fetching membership role: %s", err.Error())
+		return fmt.Errorf("error fetching membership role")
+	}
+	if !entities.Outranks(actorRole, memberRole) {
+		return ErrNotAllowed
+	}
+	return nil
+}
+
+// RevokeModeration lifts the mute or ban of memberId, who userId must outrank
+// like when moderating them. Nobody can lift their own.
+func (s *service) RevokeModeration(channelId int, userId int, memberId int, action string) (entities.Moderation, error) {
+	if userId == memberId {
+		return entities.Moderation{}, ErrSelfModeration
+	}
+	if err := s.authorizeModeration(channelId, userId, memberId); err != nil {
+		return entities.Moderation{}, err
+	}
+
+	moderation, err := s.repo.RevokeModeration(channelId, memberId, userId, action)
+	if err == sql.ErrNoRows {
+		return entities.Moderation{}, ErrNotModerated
+	}
+	if err != nil {
+		log.Printf("[channel service error] error revoking moderation: %s", err.Error())
+		return entities.Moderation{}, fmt.Errorf("error revoking moderation")
+	}
+	return moderation, nil
+}
+
+// FetchModerations returns the mutes and bans in force in the channel
+func (s *service) FetchModerations(channelId int, userId int) ([]entities.Moderation, error) {
+	if _, err := s.authorizeManagement(channelId, userId); err != nil {
+		return nil, err
+	}
+
+	moderations, err := s.repo.FetchActiveModerations(channelId)
+	if err != nil {
+		log.Printf("[channel service error] error fetching moderations: %s", err.Error())
+		return nil, fmt.Errorf("error fetching moderations")
+	}
+	return moderations, nil
+}
+
+// FetchFilters returns the filters the messages of the channel go through
+func (s *service) FetchFilters(channelId int, userId int) (entities.FilterConfig, error) {
+	if _, err := s.authorizeManagement(channelId, userId); err != nil {
+		return entities.FilterConfig{}, err
+	}
+
+	config, err := s.repo.FetchFilterConfig(channelId)
+	if err != nil {
+		log.Printf("[channel service error] error fetching filters: %s", err.Error())
+		return entities.FilterConfig{}, fmt.Errorf("error fetching filters")
+	}
+	return config, nil
+}
+
+// UpdateFilters replaces the filters of the channel. Compiled filters are
+// cached by the chat service, which must forget the old ones.
+func (s *service) UpdateFilters(channelId int, userId int, config entities.FilterConfig) (entities.FilterConfig, error) {
+	if _, err := s.authorizeManagement(channelId, userId); err != nil {
+		return entities.FilterConfig{}, err
+	}
+
+	if err := s.repo.SetFilterConfig(channelId, userId, config); err != nil {
+		log.Printf("[channel service error] error updating filters: %s", err.Error())
+		return entities.FilterConfig{}, fmt.Errorf("error updating filters")
+	}
+	return config, nil
+}
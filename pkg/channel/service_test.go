@@ -0,0 +1,698 @@
+package channel
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockRepository struct {
+	channel      entities.Channel
+	channelError error
+	channels     []entities.Channel
+	writeError   error
+	deleted      bool
+	role         string
+	roleError    error
+	// Roles by user, for checks on several members. Users not in it are
+	// not members.
+	roles           map[int]string
+	member          entities.Member
+	memberError     error
+	members         []entities.Member
+	added           bool
+	changed         bool
+	removed         bool
+	user            entities.User
+	userError       error
+	invite          entities.Invite
+	inviteError     error
+	invites         []entities.Invite
+	revoked         bool
+	redeemed        entities.Message
+	joined          bool
+	redeemError     error
+	banned          bool
+	moderation      entities.Moderation
+	moderationError error
+	filters         entities.FilterConfig
+	// Arguments of the last CreateInvite and RedeemInvite calls
+	expiresAt *time.Time
+	msgBody   *[]byte
+}
+
+func (mr mockRepository) CreateChannel(userId int, input entities.CreateChannelInput) (entities.Channel, error) {
+	return mr.channel, mr.writeError
+}
+
+func (mr mockRepository) FetchChannel(channelId int) (entities.Channel, error) {
+	return mr.channel, mr.channelError
+}
+
+func (mr mockRepository) FetchUserChannels(userId int) ([]entities.Channel, error) {
+	return mr.channels, mr.channelError
+}
+
+func (mr mockRepository) UpdateChannel(channelId int, input entities.UpdateChannelInput) (entities.Channel, error) {
+	updated := mr.channel
+	if input.Name != nil {
+		updated.Name = *input.Name
+	}
+	if input.Description != nil {
+		updated.Description = *input.Description
+	}
+	return updated, mr.writeError
+}
+
+func (mr mockRepository) SetArchived(channelId int, archived bool) (entities.Channel, error) {
+	updated := mr.channel
+	updated.ArchivedAt = nil
+	if archived {
+		archivedAt := "2025-07-20T10:30:00Z"
+		updated.ArchivedAt = &archivedAt
+	}
+	return updated, mr.writeError
+}
+
+func (mr mockRepository) DeleteChannel(channelId int) (bool, error) {
+	return mr.deleted, mr.writeError
+}
+
+func (mr mockRepository) FetchMembershipRole(channelId int, userId int) (string, error) {
+	if mr.roles != nil {
+		role, ok := mr.roles[userId]
+		if !ok {
+			return "", sql.ErrNoRows
+		}
+		return role, nil
+	}
+	return mr.role, mr.roleError
+}
+
+func (mr mockRepository) FetchMembers(channelId int) ([]entities.Member, error) {
+	return mr.members, mr.memberError
+}
+
+func (mr mockRepository) FetchMember(channelId int, userId int) (entities.Member, error) {
+	return mr.member, mr.memberError
+}
+
+func (mr mockRepository) AddMember(channelId int, userId int, role string) (bool, error) {
+	return mr.added, mr.writeError
+}
+
+func (mr mockRepository) SetMemberRole(channelId int, userId int, role string) (bool, error) {
+	return mr.changed, mr.writeError
+}
+
+func (mr mockRepository) RemoveMember(channelId int, userId int) (bool, error) {
+	return mr.removed, mr.writeError
+}
+
+func (mr mockRepository) FetchUser(userId int) (entities.User, error) {
+	return mr.user, mr.userError
+}
+
+func (mr mockRepository) CreateInvite(channelId int, userId int, code string, maxUses *int, expiresAt *time.Time) (entities.Invite, error) {
+	if expiresAt != nil {
+		*mr.expiresAt = *expiresAt
+	}
+	return entities.Invite{Code: code, ChannelID: channelId, MaxUses: maxUses}, mr.writeError
+}
+
+func (mr mockRepository) FetchInvite(code string) (entities.Invite, error) {
+	return mr.invite, mr.inviteError
+}
+
+func (mr mockRepository) FetchInvites(channelId int) ([]entities.Invite, error) {
+	return mr.invites, mr.inviteError
+}
+
+func (mr mockRepository) RevokeInvite(channelId int, code string) (bool, error) {
+	return mr.revoked, mr.writeError
+}
+
+func (mr mockRepository) RedeemInvite(code string, userId int, msgBody []byte) (entities.Message, bool, error) {
+	if mr.msgBody != nil {
+		*mr.msgBody = msgBody
+	}
+	return mr.redeemed, mr.joined, mr.redeemError
+}
+
+func (mr mockRepository) AddModeration(channelId int, userId int, actorId int, action string, reason string, expiresAt *time.Time) (entities.Moderation, error) {
+	moderation := entities.Moderation{ChannelID: channelId, UserID: userId, ActorID: actorId, Action: action, Reason: reason}
+	if expiresAt != nil {
+		expiry := expiresAt.Format(time.RFC3339)
+		moderation.ExpiresAt = &expiry
+	}
+	return moderation, mr.writeError
+}
+
+func (mr mockRepository) RevokeModeration(channelId int, userId int, actorId int, action string) (entities.Moderation, error) {
+	return mr.moderation, mr.moderationError
+}
+
+func (mr mockRepository) FetchActiveModerations(channelId int) ([]entities.Moderation, error) {
+	return []entities.Moderation{mr.moderation}, mr.moderationError
+}
+
+func (mr mockRepository) IsBanned(channelId int, userId int) (bool, error) {
+	return mr.banned, nil
+}
+
+func (mr mockRepository) FetchFilterConfig(channelId int) (entities.FilterConfig, error) {
+	return mr.filters, nil
+}
+
+func (mr mockRepository) SetFilterConfig(channelId int, userId int, config entities.FilterConfig) error {
+	return mr.writeError
+}
+
+func (mr mockRepository) Close() error {
+	return nil
+}
+
+func TestCreateChannelService(t *testing.T) {
+	t.Run("created", func(t *testing.T) {
+		created := entities.Channel{ID: 1, Name: "general", CreatedBy: 2}
+		s := NewService(mockRepository{channel: created})
+		result, err := s.CreateChannel(2, entities.CreateChannelInput{Name: "general"})
+		assert.NoError(t, err)
+		assert.Equal(t, created, result)
+	})
+
+	t.Run("create error", func(t *testing.T) {
+		s := NewService(mockRepository{writeError: errors.New("db error")})
+		_, err := s.CreateChannel(2, entities.CreateChannelInput{Name: "general"})
+		assert.Error(t, err)
+	})
+}
+
+func TestFetchChannelService(t *testing.T) {
+	t.Run("member", func(t *testing.T) {
+		found := entities.Channel{ID: 1, Name: "general"}
+		s := NewService(mockRepository{channel: found, role: entities.RoleMember})
+		result, err := s.FetchChannel(1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, found, result)
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		s := NewService(mockRepository{roleError: sql.ErrNoRows})
+		_, err := s.FetchChannel(1, 2)
+		assert.Equal(t, ErrNotMember, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleMember, channelError: sql.ErrNoRows})
+		_, err := s.FetchChannel(1, 2)
+		assert.Equal(t, ErrChannelNotFound, err)
+	})
+}
+
+func TestUpdateChannelService(t *testing.T) {
+	name := "announcements"
+	existing := entities.Channel{ID: 1, Name: "general"}
+
+	t.Run("renamed by admin", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, role: entities.RoleAdmin})
+		result, err := s.UpdateChannel(1, 2, entities.UpdateChannelInput{Name: &name})
+		assert.NoError(t, err)
+		assert.Equal(t, "announcements", result.Name)
+	})
+
+	t.Run("member not allowed", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, role: entities.RoleMember})
+		_, err := s.UpdateChannel(1, 2, entities.UpdateChannelInput{Name: &name})
+		assert.Equal(t, ErrNotAllowed, err)
+	})
+
+	t.Run("nothing to update", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, role: entities.RoleOwner})
+		_, err := s.UpdateChannel(1, 2, entities.UpdateChannelInput{})
+		assert.Equal(t, ErrNothingToUpdate, err)
+	})
+
+	t.Run("archived channel", func(t *testing.T) {
+		archivedAt := "2025-07-20T10:30:00Z"
+		archived := entities.Channel{ID: 1, Name: "general", ArchivedAt: &archivedAt}
+		s := NewService(mockRepository{channel: archived, role: entities.RoleOwner})
+		_, err := s.UpdateChannel(1, 2, entities.UpdateChannelInput{Name: &name})
+		assert.Equal(t, ErrChannelArchived, err)
+	})
+}
+
+func TestArchiveChannelService(t *testing.T) {
+	existing := entities.Channel{ID: 1, Name: "general"}
+
+	t.Run("archived and restored", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, role: entities.RoleOwner})
+		archived, err := s.ArchiveChannel(1, 2)
+		assert.NoError(t, err)
+		assert.NotNil(t, archived.ArchivedAt)
+
+		restored, err := s.UnarchiveChannel(1, 2)
+		assert.NoError(t, err)
+		assert.Nil(t, restored.ArchivedAt)
+	})
+
+	t.Run("member not allowed", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, role: entities.RoleMember})
+		_, err := s.ArchiveChannel(1, 2)
+		assert.Equal(t, ErrNotAllowed, err)
+	})
+}
+
+func TestDeleteChannelService(t *testing.T) {
+	t.Run("deleted by owner", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleOwner, deleted: true})
+		assert.NoError(t, s.DeleteChannel(1, 2))
+	})
+
+	t.Run("admin not allowed", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleAdmin, deleted: true})
+		assert.Equal(t, ErrNotAllowed, s.DeleteChannel(1, 2))
+	})
+
+	t.Run("already deleted", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleOwner})
+		assert.Equal(t, ErrChannelNotFound, s.DeleteChannel(1, 2))
+	})
+
+	t.Run("delete error", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleOwner, writeError: errors.New("db error")})
+		err := s.DeleteChannel(1, 2)
+		assert.Error(t, err)
+		assert.NotEqual(t, ErrChannelNotFound, err)
+	})
+}
+
+func TestFetchMembersService(t *testing.T) {
+	members := []entities.Member{{User: entities.User{ID: 2, Name: "Jane"}, Role: entities.RoleOwner}}
+
+	t.Run("member", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleReadOnly, members: members})
+		result, err := s.FetchMembers(1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, members, result)
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		s := NewService(mockRepository{roleError: sql.ErrNoRows})
+		_, err := s.FetchMembers(1, 2)
+		assert.Equal(t, ErrNotMember, err)
+	})
+}
+
+func TestJoinChannelService(t *testing.T) {
+	existing := entities.Channel{ID: 1, Name: "general"}
+	member := entities.Member{User: entities.User{ID: 2}, Role: entities.RoleMember}
+
+	t.Run("joined", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, roles: map[int]string{}, added: true, member: member})
+		result, err := s.JoinChannel(1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, member, result)
+	})
+
+	t.Run("already a member", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, roles: map[int]string{2: entities.RoleMember}})
+		_, err := s.JoinChannel(1, 2)
+		assert.Equal(t, ErrAlreadyMember, err)
+	})
+
+	t.Run("archived channel", func(t *testing.T) {
+		archivedAt := "2025-07-20T10:30:00Z"
+		archived := entities.Channel{ID: 1, Name: "general", ArchivedAt: &archivedAt}
+		s := NewService(mockRepository{channel: archived, roles: map[int]string{}, added: true})
+		_, err := s.JoinChannel(1, 2)
+		assert.Equal(t, ErrChannelArchived, err)
+	})
+
+	t.Run("banned", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, roles: map[int]string{}, added: true, banned: true})
+		_, err := s.JoinChannel(1, 2)
+		assert.Equal(t, ErrBanned, err)
+	})
+
+	t.Run("direct message", func(t *testing.T) {
+		direct := entities.Channel{ID: 1, Direct: true}
+		s := NewService(mockRepository{channel: direct, roles: map[int]string{}, added: true})
+		_, err := s.JoinChannel(1, 2)
+		assert.Equal(t, ErrDirectMessage, err)
+	})
+}
+
+func TestLeaveChannelService(t *testing.T) {
+	t.Run("left", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleAdmin, removed: true})
+		assert.NoError(t, s.LeaveChannel(1, 2))
+	})
+
+	t.Run("direct message", func(t *testing.T) {
+		s := NewService(mockRepository{channel: entities.Channel{ID: 1, Direct: true}, role: entities.RoleMember, removed: true})
+		assert.Equal(t, ErrDirectMessage, s.LeaveChannel(1, 2))
+	})
+
+	t.Run("owner cannot leave", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleOwner, removed: true})
+		assert.Equal(t, ErrOwnerCannotLeave, s.LeaveChannel(1, 2))
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		s := NewService(mockRepository{roleError: sql.ErrNoRows})
+		assert.Equal(t, ErrNotMember, s.LeaveChannel(1, 2))
+	})
+}
+
+func TestAddMemberService(t *testing.T) {
+	existing := entities.Channel{ID: 1, Name: "general"}
+	member := entities.Member{User: entities.User{ID: 3}, Role: entities.RoleMember}
+
+	t.Run("added as member by default", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, roles: map[int]string{2: entities.RoleAdmin}, added: true, member: member})
+		result, err := s.AddMember(1, 2, entities.AddMemberInput{UserID: 3})
+		assert.NoError(t, err)
+		assert.Equal(t, member, result)
+	})
+
+	t.Run("owner adds an admin", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, roles: map[int]string{2: entities.RoleOwner}, added: true})
+		_, err := s.AddMember(1, 2, entities.AddMemberInput{UserID: 3, Role: entities.RoleAdmin})
+		assert.NoError(t, err)
+	})
+
+	t.Run("admin cannot add an admin", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, roles: map[int]string{2: entities.RoleAdmin}, added: true})
+		_, err := s.AddMember(1, 2, entities.AddMemberInput{UserID: 3, Role: entities.RoleAdmin})
+		assert.Equal(t, ErrNotAllowed, err)
+	})
+
+	t.Run("member not allowed", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, roles: map[int]string{2: entities.RoleMember}, added: true})
+		_, err := s.AddMember(1, 2, entities.AddMemberInput{UserID: 3})
+		assert.Equal(t, ErrNotAllowed, err)
+	})
+
+	t.Run("already a member", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, roles: map[int]string{2: entities.RoleAdmin, 3: entities.RoleReadOnly}})
+		_, err := s.AddMember(1, 2, entities.AddMemberInput{UserID: 3})
+		assert.Equal(t, ErrAlreadyMember, err)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, roles: map[int]string{2: entities.RoleAdmin}})
+		_, err := s.AddMember(1, 2, entities.AddMemberInput{UserID: 3})
+		assert.Equal(t, ErrUserNotFound, err)
+	})
+}
+
+func TestUpdateMemberService(t *testing.T) {
+	readOnly := entities.UpdateMemberInput{Role: entities.RoleReadOnly}
+
+	t.Run("admin restricts a member", func(t *testing.T) {
+		member := entities.Member{User: entities.User{ID: 3}, Role: entities.RoleReadOnly}
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleAdmin, 3: entities.RoleMember}, changed: true, member: member})
+		result, err := s.UpdateMember(1, 2, 3, readOnly)
+		assert.NoError(t, err)
+		assert.Equal(t, member, result)
+	})
+
+	t.Run("admin cannot change another admin", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleAdmin, 3: entities.RoleAdmin}, changed: true})
+		_, err := s.UpdateMember(1, 2, 3, readOnly)
+		assert.Equal(t, ErrNotAllowed, err)
+	})
+
+	t.Run("owner cannot be demoted", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleOwner}, changed: true})
+		_, err := s.UpdateMember(1, 2, 2, readOnly)
+		assert.Equal(t, ErrNotAllowed, err)
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleOwner}})
+		_, err := s.UpdateMember(1, 2, 3, readOnly)
+		assert.Equal(t, ErrMemberNotFound, err)
+	})
+}
+
+func TestRemoveMemberService(t *testing.T) {
+	t.Run("owner removes an admin", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleOwner, 3: entities.RoleAdmin}, removed: true})
+		assert.NoError(t, s.RemoveMember(1, 2, 3))
+	})
+
+	t.Run("member not allowed", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleMember, 3: entities.RoleReadOnly}, removed: true})
+		assert.Equal(t, ErrNotAllowed, s.RemoveMember(1, 2, 3))
+	})
+
+	t.Run("remove error", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleOwner, 3: entities.RoleMember}, writeError: errors.New("db error")})
+		err := s.RemoveMember(1, 2, 3)
+		assert.Error(t, err)
+		assert.NotEqual(t, ErrMemberNotFound, err)
+	})
+}
+
+func TestCreateInviteService(t *testing.T) {
+	existing := entities.Channel{ID: 1, Name: "general"}
+
+	t.Run("created with limits", func(t *testing.T) {
+		var expiresAt time.Time
+		maxUses, expiresIn := 5, 3600
+		s := NewService(mockRepository{channel: existing, role: entities.RoleAdmin, expiresAt: &expiresAt})
+		invite, err := s.CreateInvite(1, 2, entities.CreateInviteInput{MaxUses: &maxUses, ExpiresIn: &expiresIn})
+		assert.NoError(t, err)
+		assert.Len(t, invite.Code, 12)
+		assert.Equal(t, 5, *invite.MaxUses)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+	})
+
+	t.Run("codes are random", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, role: entities.RoleOwner})
+		first, err := s.CreateInvite(1, 2, entities.CreateInviteInput{})
+		assert.NoError(t, err)
+		second, err := s.CreateInvite(1, 2, entities.CreateInviteInput{})
+		assert.NoError(t, err)
+		assert.NotEqual(t, first.Code, second.Code)
+	})
+
+	t.Run("member not allowed", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, role: entities.RoleMember})
+		_, err := s.CreateInvite(1, 2, entities.CreateInviteInput{})
+		assert.Equal(t, ErrNotAllowed, err)
+	})
+}
+
+func TestRevokeInviteService(t *testing.T) {
+	t.Run("revoked", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleAdmin, revoked: true})
+		assert.NoError(t, s.RevokeInvite(1, 2, "abc"))
+	})
+
+	t.Run("unknown invite", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleAdmin})
+		assert.Equal(t, ErrInviteNotFound, s.RevokeInvite(1, 2, "abc"))
+	})
+}
+
+func TestAcceptInviteService(t *testing.T) {
+	existing := entities.Channel{ID: 1, Name: "general"}
+	invite := entities.Invite{Code: "abc", ChannelID: 1}
+	jane := entities.User{ID: 3, Name: "Jane"}
+
+	t.Run("joined", func(t *testing.T) {
+		var msgBody []byte
+		member := entities.Member{User: jane, Role: entities.RoleMember}
+		s := NewService(mockRepository{
+			channel:  existing,
+			invite:   invite,
+			user:     jane,
+			redeemed: entities.Message{ID: 9, ChannelID: 1},
+			joined:   true,
+			member:   member,
+			msgBody:  &msgBody,
+		})
+		result, message, err := s.AcceptInvite("abc", 3)
+		assert.NoError(t, err)
+		assert.Equal(t, member, result)
+		assert.Equal(t, jane, message.User)
+		assert.JSONEq(t, `{"type":"system","event":"member_joined","content":"Jane joined the channel"}`, string(msgBody))
+	})
+
+	t.Run("unknown invite", func(t *testing.T) {
+		s := NewService(mockRepository{inviteError: sql.ErrNoRows})
+		_, _, err := s.AcceptInvite("abc", 3)
+		assert.Equal(t, ErrInviteNotFound, err)
+	})
+
+	t.Run("already a member", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, invite: invite, user: jane})
+		_, _, err := s.AcceptInvite("abc", 3)
+		assert.Equal(t, ErrAlreadyMember, err)
+	})
+
+	t.Run("used up", func(t *testing.T) {
+		maxUses := 2
+		usedUp := entities.Invite{Code: "abc", ChannelID: 1, MaxUses: &maxUses, Uses: 2}
+		s := NewService(mockRepository{channel: existing, invite: usedUp, redeemError: sql.ErrNoRows})
+		_, _, err := s.AcceptInvite("abc", 3)
+		assert.Equal(t, ErrInviteUsedUp, err)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, invite: invite, redeemError: sql.ErrNoRows})
+		_, _, err := s.AcceptInvite("abc", 3)
+		assert.Equal(t, ErrInviteExpired, err)
+	})
+
+	t.Run("banned", func(t *testing.T) {
+		s := NewService(mockRepository{channel: existing, invite: invite, joined: true, banned: true})
+		_, _, err := s.AcceptInvite("abc", 3)
+		assert.Equal(t, ErrBanned, err)
+	})
+
+	t.Run("archived channel", func(t *testing.T) {
+		archivedAt := "2025-07-20T10:30:00Z"
+		archived := entities.Channel{ID: 1, Name: "general", ArchivedAt: &archivedAt}
+		s := NewService(mockRepository{channel: archived, invite: invite, joined: true})
+		_, _, err := s.AcceptInvite("abc", 3)
+		assert.Equal(t, ErrChannelArchived, err)
+	})
+}
+
+func TestModerateService(t *testing.T) {
+	roles := map[int]string{2: entities.RoleAdmin, 3: entities.RoleMember}
+
+	t.Run("mute expires", func(t *testing.T) {
+		s := NewService(mockRepository{roles: roles})
+		moderation, err := s.Moderate(1, 2, 3, entities.ModerationMute, entities.ModerationInput{Reason: "spam", Duration: 600})
+		assert.NoError(t, err)
+		assert.Equal(t, entities.ModerationMute, moderation.Action)
+		assert.Equal(t, 2, moderation.ActorID)
+		assert.Equal(t, "spam", moderation.Reason)
+		assert.NotNil(t, moderation.ExpiresAt)
+	})
+
+	t.Run("mute without duration", func(t *testing.T) {
+		s := NewService(mockRepository{roles: roles})
+		_, err := s.Moderate(1, 2, 3, entities.ModerationMute, entities.ModerationInput{})
+		assert.Equal(t, ErrInvalidDuration, err)
+	})
+
+	t.Run("ban is permanent", func(t *testing.T) {
+		s := NewService(mockRepository{roles: roles})
+		moderation, err := s.Moderate(1, 2, 3, entities.ModerationBan, entities.ModerationInput{Duration: 600})
+		assert.NoError(t, err)
+		assert.Nil(t, moderation.ExpiresAt)
+	})
+
+	t.Run("must outrank the member", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleAdmin, 3: entities.RoleOwner}})
+		_, err := s.Moderate(1, 2, 3, entities.ModerationKick, entities.ModerationInput{})
+		assert.Equal(t, ErrNotAllowed, err)
+	})
+
+	t.Run("ban must outrank the member", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleAdmin, 3: entities.RoleAdmin}})
+		_, err := s.Moderate(1, 2, 3, entities.ModerationBan, entities.ModerationInput{})
+		assert.Equal(t, ErrNotAllowed, err)
+	})
+
+	t.Run("ban a user who left", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleAdmin}})
+		moderation, err := s.Moderate(1, 2, 3, entities.ModerationBan, entities.ModerationInput{Reason: "spam"})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, moderation.UserID)
+		assert.Equal(t, entities.ModerationBan, moderation.Action)
+	})
+
+	t.Run("ban an unknown user", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleAdmin}, userError: sql.ErrNoRows})
+		_, err := s.Moderate(1, 2, 3, entities.ModerationBan, entities.ModerationInput{})
+		assert.Equal(t, ErrUserNotFound, err)
+	})
+
+	t.Run("ban by a member", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleMember}})
+		_, err := s.Moderate(1, 2, 3, entities.ModerationBan, entities.ModerationInput{})
+		assert.Equal(t, ErrNotAllowed, err)
+	})
+
+	t.Run("kick a non-member", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleAdmin}})
+		_, err := s.Moderate(1, 2, 3, entities.ModerationKick, entities.ModerationInput{})
+		assert.Equal(t, ErrMemberNotFound, err)
+	})
+}
+
+func TestRevokeModerationService(t *testing.T) {
+	t.Run("unbanned", func(t *testing.T) {
+		ban := entities.Moderation{ID: 4, UserID: 3, Action: entities.ModerationBan}
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleAdmin}, moderation: ban})
+		result, err := s.RevokeModeration(1, 2, 3, entities.ModerationBan)
+		assert.NoError(t, err)
+		assert.Equal(t, ban, result)
+	})
+
+	t.Run("not banned", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleAdmin}, moderationError: sql.ErrNoRows})
+		_, err := s.RevokeModeration(1, 2, 3, entities.ModerationBan)
+		assert.Equal(t, ErrNotModerated, err)
+	})
+
+	t.Run("unmuted by a higher rank", func(t *testing.T) {
+		mute := entities.Moderation{ID: 5, UserID: 3, Action: entities.ModerationMute}
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleOwner, 3: entities.RoleAdmin}, moderation: mute})
+		result, err := s.RevokeModeration(1, 2, 3, entities.ModerationMute)
+		assert.NoError(t, err)
+		assert.Equal(t, mute, result)
+	})
+
+	t.Run("must outrank the member", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleAdmin, 3: entities.RoleAdmin}})
+		_, err := s.RevokeModeration(1, 2, 3, entities.ModerationMute)
+		assert.Equal(t, ErrNotAllowed, err)
+	})
+
+	t.Run("own mute", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleOwner}})
+		_, err := s.RevokeModeration(1, 2, 2, entities.ModerationMute)
+		assert.Equal(t, ErrSelfModeration, err)
+	})
+
+	t.Run("member not allowed", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleMember})
+		_, err := s.RevokeModeration(1, 2, 3, entities.ModerationMute)
+		assert.Equal(t, ErrNotAllowed, err)
+	})
+}
+
+func TestUpdateFiltersService(t *testing.T) {
+	config := entities.FilterConfig{InviteLinks: &entities.InviteFilterConfig{Action: "reject"}}
+
+	t.Run("updated", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleAdmin})
+		result, err := s.UpdateFilters(1, 2, config)
+		assert.NoError(t, err)
+		assert.Equal(t, config, result)
+	})
+
+	t.Run("member not allowed", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleMember})
+		_, err := s.UpdateFilters(1, 2, config)
+		assert.Equal(t, ErrNotAllowed, err)
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleAdmin, writeError: errors.New("db down")})
+		_, err := s.UpdateFilters(1, 2, config)
+		assert.EqualError(t, err, "error updating filters")
+	})
+}
@@ -2,15 +2,44 @@ package chat
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 
 	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/aramceballos/chat-group-server/pkg/store"
 	"github.com/gofiber/fiber/v2/log"
+	"github.com/lib/pq"
 )
 
 type Repository interface {
 	CheckUserMembership(channelId int, userId int) (bool, error)
 	FetchUserById(userId int) (entities.User, error)
-	InsertMessage(channelId int, userId int, msgBody []byte) (entities.Message, error)
+	InsertMessage(channelId int, userId int, parentId int, clientMsgId string, msgBody []byte) (entities.Message, bool, error)
+	FetchMessages(channelId int, query entities.MessageQuery) ([]entities.Message, error)
+	FetchMessage(messageId int) (entities.Message, error)
+	EditMessage(messageId int, msgBody []byte) (entities.Message, error)
+	DeleteMessage(messageId int) (entities.Message, error)
+	FetchMembershipRole(channelId int, userId int) (string, error)
+	AddReaction(messageId int, userId int, emoji string) (bool, error)
+	RemoveReaction(messageId int, userId int, emoji string) (bool, error)
+	CountReactions(messageId int, emoji string) (int, error)
+	FetchReactions(messageIds []int, viewerId int) (map[int][]entities.Reaction, error)
+	MarkRead(channelId int, userId int, messageId int) (int, error)
+	FetchUnreadCounts(userId int) ([]entities.UnreadCount, error)
+	SearchMessages(query entities.SearchQuery) ([]entities.SearchResult, error)
+	PinMessage(channelId int, messageId int, userId int, limit int) (string, error)
+	UnpinMessage(channelId int, messageId int) (bool, error)
+	CountPins(channelId int) (int, error)
+	FetchPins(channelId int) ([]entities.Pin, error)
+	SetVotes(messageId int, userId int, options []int) error
+	CountVotes(messageId int) (map[int]int, int, error)
+	FetchUserVotes(messageId int, userId int) ([]int, error)
+	FetchPostingState(channelId int, userId int) (PostingState, error)
+	FetchFilterConfig(channelId int) (entities.FilterConfig, error)
+	FlagMessage(messageId int, channelId int, reasons []string) (entities.MessageFlag, error)
+	FetchFlag(flagId int) (entities.MessageFlag, error)
+	FetchPendingFlags(channelId int) ([]entities.MessageFlag, error)
+	ResolveFlag(flagId int, status string, reviewerId int) (entities.MessageFlag, error)
 	Close() error
 }
 
@@ -101,8 +130,529 @@ func (r *repository) FetchUserById(userId int) (entities.User, error) {
 	return user, err
 }
 
-func (r *repository) InsertMessage(channelId int, userId int, msgBody []byte) (entities.Message, error) {
+// InsertMessage stores a message. A non-zero parentId makes it a reply and
+// bumps the reply count of its parent in the same transaction. When the user
+// already sent a message with the same clientMsgId, that message is returned
+// and created is false.
+func (r *repository) InsertMessage(channelId int, userId int, parentId int, clientMsgId string, msgBody []byte) (entities.Message, bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return entities.Message{}, false, err
+	}
+	defer tx.Rollback()
+
 	insertedMessage := entities.Message{}
-	err := r.db.QueryRow("INSERT INTO messages (channel_id, user_id, body) VALUES ($1, $2, $3::jsonb) RETURNING id, user_id, channel_id, body, created_at", channelId, userId, msgBody).Scan(&insertedMessage.ID, &insertedMessage.UserID, &insertedMessage.ChannelID, &insertedMessage.Body, &insertedMessage.CreatedAt)
-	return insertedMessage, err
+	var storedClientMsgId sql.NullString
+	err = tx.QueryRow("INSERT INTO messages (channel_id, user_id, body, parent_id, client_msg_id) VALUES ($1, $2, $3::jsonb, NULLIF($4, 0), NULLIF($5, '')) ON CONFLICT (user_id, client_msg_id) WHERE client_msg_id IS NOT NULL DO NOTHING RETURNING id, user_id, channel_id, parent_id, client_msg_id, body, created_at", channelId, userId, msgBody, parentId, clientMsgId).Scan(&insertedMessage.ID, &insertedMessage.UserID, &insertedMessage.ChannelID, &insertedMessage.ParentID, &storedClientMsgId, &insertedMessage.Body, &insertedMessage.CreatedAt)
+	if err == sql.ErrNoRows && clientMsgId != "" {
+		tx.Rollback()
+		existing, err := r.fetchMessageByClientId(userId, clientMsgId)
+		return existing, false, err
+	}
+	if err != nil {
+		return entities.Message{}, false, err
+	}
+	insertedMessage.ClientMsgID = storedClientMsgId.String
+
+	if parentId != 0 {
+		_, err = tx.Exec("UPDATE messages SET reply_count = reply_count + 1, last_reply_at = $1 WHERE id = $2", insertedMessage.CreatedAt, parentId)
+		if err != nil {
+			return entities.Message{}, false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entities.Message{}, false, err
+	}
+
+	return insertedMessage, true, nil
+}
+
+func (r *repository) fetchMessageByClientId(userId int, clientMsgId string) (entities.Message, error) {
+	message := entities.Message{}
+	err := r.db.QueryRow("SELECT id, user_id, channel_id, parent_id, client_msg_id, body, created_at FROM messages WHERE user_id = $1 AND client_msg_id = $2", userId, clientMsgId).Scan(&message.ID, &message.UserID, &message.ChannelID, &message.ParentID, &message.ClientMsgID, &message.Body, &message.CreatedAt)
+	if err != nil {
+		return entities.Message{}, err
+	}
+	return message, nil
+}
+
+// messageColumns selects a message joined with its author, read by scanMessage
+const messageColumns = "m.id, m.user_id, m.channel_id, m.parent_id, m.body, m.created_at, m.edited_at, m.deleted_at, m.reply_count, m.last_reply_at, u.id, u.name, u.avatar_url, u.created_at"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanMessage reads messageColumns, followed by the extra columns of the
+// query if any
+func scanMessage(row rowScanner, extra ...any) (entities.Message, error) {
+	message := entities.Message{}
+	dest := []any{&message.ID, &message.UserID, &message.ChannelID, &message.ParentID, &message.Body, &message.CreatedAt, &message.EditedAt, &message.DeletedAt, &message.ReplyCount, &message.LastReplyAt, &message.User.ID, &message.User.Name, &message.User.AvatarURL, &message.User.CreatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return entities.Message{}, err
+	}
+	return message, nil
+}
+
+func (r *repository) FetchMessages(channelId int, query entities.MessageQuery) ([]entities.Message, error) {
+	conditions := []string{"m.channel_id = $1"}
+	args := []any{channelId}
+	if query.ParentID > 0 {
+		args = append(args, query.ParentID)
+		conditions = append(conditions, fmt.Sprintf("m.parent_id = $%d", len(args)))
+	} else if !query.IncludeReplies {
+		conditions = append(conditions, "m.parent_id IS NULL")
+	}
+
+	order := "DESC"
+	if query.After > 0 {
+		args = append(args, query.After)
+		conditions = append(conditions, fmt.Sprintf("m.id > $%d", len(args)))
+		order = "ASC"
+	} else if query.Before > 0 {
+		args = append(args, query.Before)
+		conditions = append(conditions, fmt.Sprintf("m.id < $%d", len(args)))
+	}
+	args = append(args, query.Limit)
+
+	rows, err := r.db.Query(fmt.Sprintf("SELECT %s FROM messages m JOIN users u ON u.id = m.user_id WHERE %s ORDER BY m.id %s LIMIT $%d", messageColumns, strings.Join(conditions, " AND "), order, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []entities.Message{}
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (r *repository) FetchMessage(messageId int) (entities.Message, error) {
+	row := r.db.QueryRow("SELECT "+messageColumns+" FROM messages m JOIN users u ON u.id = m.user_id WHERE m.id = $1", messageId)
+	return scanMessage(row)
+}
+
+func (r *repository) EditMessage(messageId int, msgBody []byte) (entities.Message, error) {
+	message := entities.Message{}
+	err := r.db.QueryRow("UPDATE messages SET body = $1::jsonb, edited_at = NOW() WHERE id = $2 AND deleted_at IS NULL RETURNING id, user_id, channel_id, body, created_at, edited_at", msgBody, messageId).Scan(&message.ID, &message.UserID, &message.ChannelID, &message.Body, &message.CreatedAt, &message.EditedAt)
+	return message, err
+}
+
+// DeleteMessage soft deletes a message and wipes its body
+func (r *repository) DeleteMessage(messageId int) (entities.Message, error) {
+	return store.DeleteMessage(r.db, messageId)
+}
+
+func (r *repository) FetchMembershipRole(channelId int, userId int) (string, error) {
+	var role string
+	err := r.db.QueryRow("SELECT role FROM memberships WHERE channel_id = $1 AND user_id = $2", channelId, userId).Scan(&role)
+	return role, err
+}
+
+// AddReaction reports whether the reaction was new
+func (r *repository) AddReaction(messageId int, userId int, emoji string) (bool, error) {
+	result, err := r.db.Exec("INSERT INTO message_reactions (message_id, user_id, emoji) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING", messageId, userId, emoji)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RemoveReaction reports whether a reaction was removed
+func (r *repository) RemoveReaction(messageId int, userId int, emoji string) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM message_reactions WHERE message_id = $1 AND user_id = $2 AND emoji = $3", messageId, userId, emoji)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *repository) CountReactions(messageId int, emoji string) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM message_reactions WHERE message_id = $1 AND emoji = $2", messageId, emoji).Scan(&count)
+	return count, err
+}
+
+func (r *repository) FetchReactions(messageIds []int, viewerId int) (map[int][]entities.Reaction, error) {
+	rows, err := r.db.Query("SELECT message_id, emoji, COUNT(*), BOOL_OR(user_id = $2) FROM message_reactions WHERE message_id = ANY($1) GROUP BY message_id, emoji ORDER BY message_id, MIN(created_at)", pq.Array(messageIds), viewerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactions := map[int][]entities.Reaction{}
+	for rows.Next() {
+		var messageId int
+		reaction := entities.Reaction{}
+		if err := rows.Scan(&messageId, &reaction.Emoji, &reaction.Count, &reaction.Reacted); err != nil {
+			return nil, err
+		}
+		reactions[messageId] = append(reactions[messageId], reaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reactions, nil
+}
+
+// MarkRead moves the read position forward and returns the resulting one
+func (r *repository) MarkRead(channelId int, userId int, messageId int) (int, error) {
+	var lastRead int
+	err := r.db.QueryRow("UPDATE memberships SET last_read_message_id = GREATEST(last_read_message_id, $3) WHERE channel_id = $1 AND user_id = $2 RETURNING last_read_message_id", channelId, userId, messageId).Scan(&lastRead)
+	return lastRead, err
+}
+
+// mentionPattern matches @username as a whole word in a message, with the
+// regular expression characters of the username escaped
+const mentionPattern = `'(^|[^[:alnum:]_])@' || regexp_replace(u.username, '([^[:alnum:]_])', '\\\1', 'g') || '(?![[:alnum:]_])'`
+
+// FetchUnreadCounts counts, for every channel of the user, the messages from
+// others after their read position and how many of them mention @username
+func (r *repository) FetchUnreadCounts(userId int) ([]entities.UnreadCount, error) {
+	rows, err := r.db.Query(`SELECT ms.channel_id, ms.last_read_message_id, COUNT(m.id),
+		COUNT(m.id) FILTER (WHERE u.username IS NOT NULL AND m.body->>'content' ~* (`+mentionPattern+`))
+		FROM memberships ms
+		JOIN users u ON u.id = ms.user_id
+		LEFT JOIN messages m ON m.channel_id = ms.channel_id AND m.id > ms.last_read_message_id AND m.user_id <> ms.user_id AND m.deleted_at IS NULL
+		WHERE ms.user_id = $1
+		GROUP BY ms.channel_id, ms.last_read_message_id
+		ORDER BY ms.channel_id`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []entities.UnreadCount{}
+	for rows.Next() {
+		count := entities.UnreadCount{}
+		if err := rows.Scan(&count.ChannelID, &count.LastReadMessageID, &count.Unread, &count.Mentions); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// searchableText is the text of a message matched by search_vector, as built
+// by message_search_text (see migrations), HTML escaped so that highlighted
+// snippets are safe to render
+const searchableText = `replace(replace(replace(message_search_text(m.body), '&', '&amp;'), '<', '&lt;'), '>', '&gt;')`
+
+// SearchMessages ranks the live messages of the user's channels matching the
+// query text, which accepts web search syntax ("quoted phrases", -exclusions, or)
+func (r *repository) SearchMessages(query entities.SearchQuery) ([]entities.SearchResult, error) {
+	conditions := []string{"m.search_vector @@ q", "m.deleted_at IS NULL"}
+	args := []any{query.Text, query.UserID}
+	if query.ChannelID > 0 {
+		args = append(args, query.ChannelID)
+		conditions = append(conditions, fmt.Sprintf("m.channel_id = $%d", len(args)))
+	}
+	if query.AuthorID > 0 {
+		args = append(args, query.AuthorID)
+		conditions = append(conditions, fmt.Sprintf("m.user_id = $%d", len(args)))
+	}
+	if !query.From.IsZero() {
+		args = append(args, query.From)
+		conditions = append(conditions, fmt.Sprintf("m.created_at >= $%d", len(args)))
+	}
+	if !query.To.IsZero() {
+		args = append(args, query.To)
+		conditions = append(conditions, fmt.Sprintf("m.created_at < $%d", len(args)))
+	}
+	args = append(args, query.Limit, query.Offset)
+
+	rows, err := r.db.Query(fmt.Sprintf(`SELECT %s, ts_headline('english', %s, q, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2'), ts_rank(m.search_vector, q) AS rank
+		FROM messages m
+		JOIN users u ON u.id = m.user_id
+		JOIN memberships ms ON ms.channel_id = m.channel_id AND ms.user_id = $2
+		CROSS JOIN websearch_to_tsquery('english', $1) q
+		WHERE %s
+		ORDER BY rank DESC, m.id DESC
+		LIMIT $%d OFFSET $%d`, messageColumns, searchableText, strings.Join(conditions, " AND "), len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []entities.SearchResult{}
+	for rows.Next() {
+		var result entities.SearchResult
+		result.Message, err = scanMessage(rows, &result.Snippet, &result.Rank)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// PinMessage returns when the message was pinned, or sql.ErrNoRows if it
+// already is or the channel has limit pins
+func (r *repository) PinMessage(channelId int, messageId int, userId int, limit int) (string, error) {
+	var pinnedAt string
+	err := r.db.QueryRow(`INSERT INTO message_pins (message_id, channel_id, pinned_by)
+		SELECT $1, $2, $3 WHERE (SELECT COUNT(*) FROM message_pins WHERE channel_id = $2) < $4
+		ON CONFLICT DO NOTHING RETURNING pinned_at`, messageId, channelId, userId, limit).Scan(&pinnedAt)
+	return pinnedAt, err
+}
+
+// UnpinMessage reports whether a pin was removed
+func (r *repository) UnpinMessage(channelId int, messageId int) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM message_pins WHERE message_id = $1 AND channel_id = $2", messageId, channelId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *repository) CountPins(channelId int) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM message_pins WHERE channel_id = $1", channelId).Scan(&count)
+	return count, err
+}
+
+// FetchPins returns the pinned live messages of the channel, latest pin first
+func (r *repository) FetchPins(channelId int) ([]entities.Pin, error) {
+	rows, err := r.db.Query("SELECT "+messageColumns+", p.pinned_by, p.pinned_at FROM message_pins p JOIN messages m ON m.id = p.message_id JOIN users u ON u.id = m.user_id WHERE p.channel_id = $1 AND m.deleted_at IS NULL ORDER BY p.pinned_at DESC", channelId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pins := []entities.Pin{}
+	for rows.Next() {
+		var pin entities.Pin
+		pin.Message, err = scanMessage(rows, &pin.PinnedBy, &pin.PinnedAt)
+		if err != nil {
+			return nil, err
+		}
+		pin.MessageID = pin.Message.ID
+		pins = append(pins, pin)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pins, nil
+}
+
+// SetVotes replaces the votes of the user on a poll with options
+func (r *repository) SetVotes(messageId int, userId int, options []int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the poll so concurrent votes of a user cannot add up
+	if _, err := tx.Exec("SELECT 1 FROM messages WHERE id = $1 FOR UPDATE", messageId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM poll_votes WHERE message_id = $1 AND user_id = $2", messageId, userId); err != nil {
+		return err
+	}
+	if len(options) > 0 {
+		if _, err := tx.Exec("INSERT INTO poll_votes (message_id, user_id, option_index) SELECT $1, $2, UNNEST($3::int[])", messageId, userId, pq.Array(options)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CountVotes returns the votes of each option of a poll and the number of
+// users who voted
+func (r *repository) CountVotes(messageId int) (map[int]int, int, error) {
+	rows, err := r.db.Query("SELECT option_index, COUNT(*) FROM poll_votes WHERE message_id = $1 GROUP BY option_index", messageId)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	counts := map[int]int{}
+	for rows.Next() {
+		var option, count int
+		if err := rows.Scan(&option, &count); err != nil {
+			return nil, 0, err
+		}
+		counts[option] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var voters int
+	if err := r.db.QueryRow("SELECT COUNT(DISTINCT user_id) FROM poll_votes WHERE message_id = $1", messageId).Scan(&voters); err != nil {
+		return nil, 0, err
+	}
+
+	return counts, voters, nil
+}
+
+func (r *repository) FetchUserVotes(messageId int, userId int) ([]int, error) {
+	rows, err := r.db.Query("SELECT option_index FROM poll_votes WHERE message_id = $1 AND user_id = $2 ORDER BY option_index", messageId, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := []int{}
+	for rows.Next() {
+		var option int
+		if err := rows.Scan(&option); err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// PostingState is what decides whether a user may post in a channel
+type PostingState struct {
+	Archived bool
+	// Empty when the user is not a member
+	Role    string
+	Muted   bool
+	Blocked bool
+}
+
+// FetchPostingState checks in one round-trip whether the channel is archived,
+// the role of userId in it, whether they are muted, and whether the channel is
+// a direct message whose other member blocked userId or was blocked by them.
+// Channels without a row, from before channels were stored here, are not
+// archived.
+func (r *repository) FetchPostingState(channelId int, userId int) (PostingState, error) {
+	state := PostingState{}
+	err := r.db.QueryRow(`
+		SELECT
+			COALESCE((SELECT archived_at IS NOT NULL FROM channels WHERE id = $1), false),
+			COALESCE((SELECT role FROM memberships WHERE channel_id = $1 AND user_id = $2), ''),
+			EXISTS (
+				SELECT 1 FROM channel_moderations mo
+				WHERE mo.channel_id = $1 AND mo.user_id = $2 AND mo.action = $3
+					AND mo.revoked_at IS NULL AND (mo.expires_at IS NULL OR mo.expires_at > NOW())
+			),
+			EXISTS (
+				SELECT 1 FROM channels c
+				JOIN memberships ms ON ms.channel_id = c.id AND ms.user_id <> $2
+				JOIN user_blocks b ON (b.blocker_id = ms.user_id AND b.blocked_id = $2) OR (b.blocker_id = $2 AND b.blocked_id = ms.user_id)
+				WHERE c.id = $1 AND c.dm_key IS NOT NULL
+			)`, channelId, userId, entities.ModerationMute).
+		Scan(&state.Archived, &state.Role, &state.Muted, &state.Blocked)
+	return state, err
+}
+
+func (r *repository) FetchFilterConfig(channelId int) (entities.FilterConfig, error) {
+	return store.FetchFilterConfig(r.db, channelId)
+}
+
+// flagColumns selects a message flag, read by scanFlag
+const flagColumns = "f.id, f.message_id, f.channel_id, f.reasons, f.status, f.reviewed_by, f.reviewed_at, f.created_at"
+
+// flagFields are the destinations of flagColumns
+func flagFields(flag *entities.MessageFlag) []any {
+	return []any{&flag.ID, &flag.MessageID, &flag.ChannelID, pq.Array(&flag.Reasons), &flag.Status, &flag.ReviewedBy, &flag.ReviewedAt, &flag.CreatedAt}
+}
+
+func scanFlag(row rowScanner) (entities.MessageFlag, error) {
+	flag := entities.MessageFlag{}
+	if err := row.Scan(flagFields(&flag)...); err != nil {
+		return entities.MessageFlag{}, err
+	}
+	return flag, nil
+}
+
+// FlagMessage queues a message for review
+func (r *repository) FlagMessage(messageId int, channelId int, reasons []string) (entities.MessageFlag, error) {
+	return scanFlag(r.db.QueryRow("INSERT INTO message_flags AS f (message_id, channel_id, reasons) VALUES ($1, $2, $3) RETURNING "+flagColumns, messageId, channelId, pq.Array(reasons)))
+}
+
+func (r *repository) FetchFlag(flagId int) (entities.MessageFlag, error) {
+	return scanFlag(r.db.QueryRow("SELECT "+flagColumns+" FROM message_flags f WHERE f.id = $1", flagId))
+}
+
+// FetchPendingFlags returns the review queue of a channel with the flagged
+// messages, oldest first
+func (r *repository) FetchPendingFlags(channelId int) ([]entities.MessageFlag, error) {
+	rows, err := r.db.Query("SELECT "+messageColumns+", "+flagColumns+" FROM message_flags f JOIN messages m ON m.id = f.message_id JOIN users u ON u.id = m.user_id WHERE f.channel_id = $1 AND f.status = $2 ORDER BY f.created_at", channelId, entities.FlagPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := []entities.MessageFlag{}
+	for rows.Next() {
+		flag := entities.MessageFlag{}
+		message, err := scanMessage(rows, flagFields(&flag)...)
+		if err != nil {
+			return nil, err
+		}
+		flag.Message = &message
+		flags = append(flags, flag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return flags, nil
+}
+
+// ResolveFlag records the review of a pending flag and, when the message is
+// removed, deletes it in the same transaction. The deleted message is set on
+// the flag. It returns sql.ErrNoRows if the flag was already reviewed.
+func (r *repository) ResolveFlag(flagId int, status string, reviewerId int) (entities.MessageFlag, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return entities.MessageFlag{}, err
+	}
+	defer tx.Rollback()
+
+	// Resolved first, so a concurrent review of the flag does nothing
+	flag, err := scanFlag(tx.QueryRow("UPDATE message_flags AS f SET status = $2, reviewed_by = $3, reviewed_at = NOW() WHERE f.id = $1 AND f.status = $4 RETURNING "+flagColumns, flagId, status, reviewerId, entities.FlagPending))
+	if err != nil {
+		return entities.MessageFlag{}, err
+	}
+
+	if status == entities.FlagRemoved {
+		message, err := store.DeleteMessage(tx, flag.MessageID)
+		// The author may have deleted it in the meantime
+		if err != nil && err != sql.ErrNoRows {
+			return entities.MessageFlag{}, err
+		}
+		if err == nil {
+			flag.Message = &message
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entities.MessageFlag{}, err
+	}
+	return flag, nil
 }
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/aramceballos/chat-group-server/pkg/entities"
@@ -113,30 +114,572 @@ func TestInsertMessage(t *testing.T) {
 	db, mock, repo := setupMockRepository(t)
 	defer db.Close()
 
+	columns := []string{"id", "user_id", "channel_id", "parent_id", "client_msg_id", "body", "created_at"}
+
 	t.Run("success insert", func(t *testing.T) {
 		messageBodyMock := []byte("{\"type\": \"text\",\"content\": \"Sii\"}")
-		row := sqlmock.NewRows([]string{"id", "user_id", "channel_id", "body", "created_at"}).
-			AddRow(1, 1, 3, messageBodyMock, "2023-01-01 00:00:00")
+		row := sqlmock.NewRows(columns).
+			AddRow(1, 1, 3, nil, nil, messageBodyMock, "2023-01-01 00:00:00")
 
-		mock.ExpectQuery("INSERT INTO messages \\(channel_id, user_id, body\\) VALUES \\(\\$1, \\$2, \\$3::jsonb\\) RETURNING id, user_id, channel_id, body, created_at").
-			WithArgs(1, 1, messageBodyMock).
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO messages \\(channel_id, user_id, body, parent_id, client_msg_id\\) VALUES \\(\\$1, \\$2, \\$3::jsonb, NULLIF\\(\\$4, 0\\), NULLIF\\(\\$5, ''\\)\\) ON CONFLICT \\(user_id, client_msg_id\\) WHERE client_msg_id IS NOT NULL DO NOTHING RETURNING id, user_id, channel_id, parent_id, client_msg_id, body, created_at").
+			WithArgs(1, 1, messageBodyMock, 0, "").
 			WillReturnRows(row)
+		mock.ExpectCommit()
 
-		message, err := repo.InsertMessage(1, 1, messageBodyMock)
+		message, created, err := repo.InsertMessage(1, 1, 0, "", messageBodyMock)
 		assert.NoError(t, err)
+		assert.True(t, created)
 		assert.Equal(t, 1, message.ID)
 		assert.Equal(t, int64(1), message.UserID)
 		assert.Equal(t, 3, message.ChannelID)
+		assert.Nil(t, message.ParentID)
+		assert.Equal(t, "", message.ClientMsgID)
 		assert.Equal(t, messageBodyMock, []byte(message.Body))
 		assert.Equal(t, "2023-01-01 00:00:00", message.CreatedAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reply bumps parent", func(t *testing.T) {
+		row := sqlmock.NewRows(columns).
+			AddRow(2, 1, 3, 1, "abc", []byte("{}"), "2023-01-01 00:00:01")
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO messages").
+			WithArgs(3, 1, []byte("{}"), 1, "abc").
+			WillReturnRows(row)
+		mock.ExpectExec("UPDATE messages SET reply_count = reply_count \\+ 1, last_reply_at = \\$1 WHERE id = \\$2").
+			WithArgs("2023-01-01 00:00:01", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		message, created, err := repo.InsertMessage(3, 1, 1, "abc", []byte("{}"))
+		assert.NoError(t, err)
+		assert.True(t, created)
+		assert.Equal(t, 1, *message.ParentID)
+		assert.Equal(t, "abc", message.ClientMsgID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("retried client message id", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO messages").
+			WithArgs(3, 1, []byte("{}"), 0, "abc").
+			WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectRollback()
+		mock.ExpectQuery("SELECT id, user_id, channel_id, parent_id, client_msg_id, body, created_at FROM messages WHERE user_id = \\$1 AND client_msg_id = \\$2").
+			WithArgs(1, "abc").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(2, 1, 3, nil, "abc", []byte("{}"), "2023-01-01 00:00:01"))
+
+		message, created, err := repo.InsertMessage(3, 1, 0, "abc", []byte("{}"))
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, 2, message.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("database error", func(t *testing.T) {
-		mock.ExpectQuery("INSERT INTO messages \\(channel_id, user_id, body\\) VALUES \\(\\$1, \\$2, \\$3::jsonb\\) RETURNING id, user_id, channel_id, body, created_at").
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO messages").
 			WillReturnError(errors.New("database error"))
+		mock.ExpectRollback()
 
-		message, err := repo.InsertMessage(1, 1, []byte{})
+		message, created, err := repo.InsertMessage(1, 1, 0, "", []byte{})
 		assert.Error(t, err)
+		assert.False(t, created)
 		assert.Equal(t, entities.Message{}, message)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestFetchMessages(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	columns := []string{"id", "user_id", "channel_id", "parent_id", "body", "created_at", "edited_at", "deleted_at", "reply_count", "last_reply_at", "id", "name", "avatar_url", "created_at"}
+
+	t.Run("latest messages", func(t *testing.T) {
+		rows := sqlmock.NewRows(columns).
+			AddRow(2, 1, 3, nil, []byte("{}"), "2023-01-01 00:00:01", nil, nil, 2, "2023-01-01 00:00:09", 1, "John Doe", "http://example.com/avatar.jpg", "2023-01-01 00:00:00").
+			AddRow(1, 1, 3, nil, []byte("{}"), "2023-01-01 00:00:00", "2023-01-01 00:00:05", nil, 0, nil, 1, "John Doe", "http://example.com/avatar.jpg", "2023-01-01 00:00:00")
+
+		mock.ExpectQuery("SELECT (.+) FROM messages m JOIN users u ON u.id = m.user_id WHERE m.channel_id = \\$1 AND m.parent_id IS NULL ORDER BY m.id DESC LIMIT \\$2").
+			WithArgs(3, 10).
+			WillReturnRows(rows)
+
+		messages, err := repo.FetchMessages(3, entities.MessageQuery{Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, messages, 2)
+		assert.Equal(t, 2, messages[0].ID)
+		assert.Equal(t, "John Doe", messages[0].User.Name)
+		assert.Nil(t, messages[0].EditedAt)
+		assert.Equal(t, "2023-01-01 00:00:05", *messages[1].EditedAt)
+		assert.Equal(t, 2, messages[0].ReplyCount)
+		assert.Nil(t, messages[1].LastReplyAt)
+	})
+
+	t.Run("before cursor", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM messages m JOIN users u ON u.id = m.user_id WHERE m.channel_id = \\$1 AND m.parent_id IS NULL AND m.id < \\$2 ORDER BY m.id DESC LIMIT \\$3").
+			WithArgs(3, 5, 10).
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		messages, err := repo.FetchMessages(3, entities.MessageQuery{Before: 5, Limit: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("after cursor", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM messages m JOIN users u ON u.id = m.user_id WHERE m.channel_id = \\$1 AND m.id > \\$2 ORDER BY m.id ASC LIMIT \\$3").
+			WithArgs(3, 5, 10).
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		messages, err := repo.FetchMessages(3, entities.MessageQuery{After: 5, Limit: 10, IncludeReplies: true})
+		assert.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("thread replies", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM messages m JOIN users u ON u.id = m.user_id WHERE m.channel_id = \\$1 AND m.parent_id = \\$2 ORDER BY m.id DESC LIMIT \\$3").
+			WithArgs(3, 1, 10).
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		messages, err := repo.FetchMessages(3, entities.MessageQuery{ParentID: 1, Limit: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM messages m").
+			WillReturnError(errors.New("database error"))
+
+		messages, err := repo.FetchMessages(3, entities.MessageQuery{Limit: 10})
+		assert.Error(t, err)
+		assert.Nil(t, messages)
+	})
+}
+
+func TestEditMessage(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	t.Run("success edit", func(t *testing.T) {
+		body := []byte("{\"type\": \"text\",\"content\": \"Fixed\"}")
+		row := sqlmock.NewRows([]string{"id", "user_id", "channel_id", "body", "created_at", "edited_at"}).
+			AddRow(1, 1, 3, body, "2023-01-01 00:00:00", "2023-01-01 00:01:00")
+
+		mock.ExpectQuery("UPDATE messages SET body = \\$1::jsonb, edited_at = NOW\\(\\) WHERE id = \\$2 AND deleted_at IS NULL").
+			WithArgs(body, 1).
+			WillReturnRows(row)
+
+		message, err := repo.EditMessage(1, body)
+		assert.NoError(t, err)
+		assert.Equal(t, body, []byte(message.Body))
+		assert.Equal(t, "2023-01-01 00:01:00", *message.EditedAt)
+	})
+
+	t.Run("deleted message", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE messages SET body = \\$1::jsonb").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.EditMessage(1, []byte("{}"))
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+func TestDeleteMessage(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	row := sqlmock.NewRows([]string{"id", "user_id", "channel_id", "body", "created_at", "edited_at", "deleted_at"}).
+		AddRow(1, 1, 3, []byte("null"), "2023-01-01 00:00:00", nil, "2023-01-01 00:02:00")
+
+	mock.ExpectQuery("UPDATE messages SET body = 'null'::jsonb, deleted_at = NOW\\(\\) WHERE id = \\$1 AND deleted_at IS NULL").
+		WithArgs(1).
+		WillReturnRows(row)
+
+	message, err := repo.DeleteMessage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "null", string(message.Body))
+	assert.Equal(t, "2023-01-01 00:02:00", *message.DeletedAt)
+	assert.Nil(t, message.EditedAt)
+}
+
+func TestFetchMembershipRole(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT role FROM memberships WHERE channel_id = \\$1 AND user_id = \\$2").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow("admin"))
+
+	role, err := repo.FetchMembershipRole(1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, entities.RoleAdmin, role)
+}
+
+func TestAddReaction(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	t.Run("new reaction", func(t *testing.T) {
+		mock.ExpectExec("INSERT INTO message_reactions \\(message_id, user_id, emoji\\) VALUES \\(\\$1, \\$2, \\$3\\) ON CONFLICT DO NOTHING").
+			WithArgs(1, 2, "👍").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		added, err := repo.AddReaction(1, 2, "👍")
+		assert.NoError(t, err)
+		assert.True(t, added)
+	})
+
+	t.Run("duplicate reaction", func(t *testing.T) {
+		mock.ExpectExec("INSERT INTO message_reactions").
+			WithArgs(1, 2, "👍").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		added, err := repo.AddReaction(1, 2, "👍")
+		assert.NoError(t, err)
+		assert.False(t, added)
+	})
+}
+
+func TestRemoveReaction(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM message_reactions WHERE message_id = \\$1 AND user_id = \\$2 AND emoji = \\$3").
+		WithArgs(1, 2, "👍").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	removed, err := repo.RemoveReaction(1, 2, "👍")
+	assert.NoError(t, err)
+	assert.True(t, removed)
+}
+
+func TestFetchReactions(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"message_id", "emoji", "count", "reacted"}).
+		AddRow(1, "👍", 2, true).
+		AddRow(1, "🎉", 1, false).
+		AddRow(2, "👍", 1, false)
+
+	mock.ExpectQuery("SELECT message_id, emoji, COUNT\\(\\*\\), BOOL_OR\\(user_id = \\$2\\) FROM message_reactions WHERE message_id = ANY\\(\\$1\\)").
+		WillReturnRows(rows)
+
+	reactions, err := repo.FetchReactions([]int{1, 2}, 5)
+	assert.NoError(t, err)
+	assert.Len(t, reactions[1], 2)
+	assert.Equal(t, entities.Reaction{Emoji: "👍", Count: 2, Reacted: true}, reactions[1][0])
+	assert.Len(t, reactions[2], 1)
+}
+
+func TestMarkRead(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE memberships SET last_read_message_id = GREATEST\\(last_read_message_id, \\$3\\) WHERE channel_id = \\$1 AND user_id = \\$2").
+		WithArgs(1, 2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"last_read_message_id"}).AddRow(9))
+
+	lastRead, err := repo.MarkRead(1, 2, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, lastRead)
+}
+
+func TestFetchUnreadCounts(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	t.Run("success", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"channel_id", "last_read_message_id", "unread", "mentions"}).
+			AddRow(1, 10, 4, 1).
+			AddRow(2, 0, 0, 0)
+
+		mock.ExpectQuery("SELECT ms.channel_id, ms.last_read_message_id, COUNT\\(m.id\\)(.+)~\\* \\((.+)regexp_replace\\(u.username(.+)FROM memberships ms").
+			WithArgs(2).
+			WillReturnRows(rows)
+
+		counts, err := repo.FetchUnreadCounts(2)
+		assert.NoError(t, err)
+		assert.Equal(t, []entities.UnreadCount{
+			{ChannelID: 1, LastReadMessageID: 10, Unread: 4, Mentions: 1},
+			{ChannelID: 2},
+		}, counts)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery("SELECT ms.channel_id").
+			WillReturnError(errors.New("database error"))
+
+		counts, err := repo.FetchUnreadCounts(2)
+		assert.Error(t, err)
+		assert.Nil(t, counts)
+	})
+}
+
+func TestSearchMessages(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	columns := []string{"id", "user_id", "channel_id", "parent_id", "body", "created_at", "edited_at", "deleted_at", "reply_count", "last_reply_at", "id", "name", "avatar_url", "created_at", "ts_headline", "rank"}
+
+	t.Run("success", func(t *testing.T) {
+		from := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+		rows := sqlmock.NewRows(columns).
+			AddRow(3, 2, 1, nil, []byte(`{"type":"text","content":"the link"}`), "2025-07-19T10:30:00Z", nil, nil, 0, nil, 2, "Alice", "", "2025-01-01T00:00:00Z", "the <mark>link</mark>", 0.6)
+
+		mock.ExpectQuery("SELECT (.+), ts_headline\\('english', (.+)message_search_text\\(m.body\\)(.+)\\), ts_rank\\(m.search_vector, q\\) AS rank(.+)JOIN memberships ms ON ms.channel_id = m.channel_id AND ms.user_id = \\$2(.+)websearch_to_tsquery\\('english', \\$1\\) q(.+)m.channel_id = \\$3 AND m.created_at >= \\$4(.+)LIMIT \\$5 OFFSET \\$6").
+			WithArgs("link", 2, 1, from, 21, 0).
+			WillReturnRows(rows)
+
+		results, err := repo.SearchMessages(entities.SearchQuery{Text: "link", UserID: 2, ChannelID: 1, From: from, Limit: 21})
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+		assert.Equal(t, 3, results[0].Message.ID)
+		assert.Equal(t, "Alice", results[0].Message.User.Name)
+		assert.Equal(t, "the <mark>link</mark>", results[0].Snippet)
+		assert.Equal(t, 0.6, results[0].Rank)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+), ts_headline").
+			WillReturnError(errors.New("database error"))
+
+		results, err := repo.SearchMessages(entities.SearchQuery{Text: "link", UserID: 2, Limit: 21})
+		assert.Error(t, err)
+		assert.Nil(t, results)
+	})
+}
+
+func TestPinMessage(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	t.Run("pinned", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO message_pins \\(message_id, channel_id, pinned_by\\)(.+)WHERE \\(SELECT COUNT\\(\\*\\) FROM message_pins WHERE channel_id = \\$2\\) < \\$4(.+)ON CONFLICT DO NOTHING RETURNING pinned_at").
+			WithArgs(3, 1, 2, 50).
+			WillReturnRows(sqlmock.NewRows([]string{"pinned_at"}).AddRow("2025-07-19T10:30:00Z"))
+
+		pinnedAt, err := repo.PinMessage(1, 3, 2, 50)
+		assert.NoError(t, err)
+		assert.Equal(t, "2025-07-19T10:30:00Z", pinnedAt)
+	})
+
+	t.Run("nothing pinned", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO message_pins").
+			WithArgs(3, 1, 2, 50).
+			WillReturnRows(sqlmock.NewRows([]string{"pinned_at"}))
+
+		_, err := repo.PinMessage(1, 3, 2, 50)
+		assert.Equal(t, sql.ErrNoRows, err)
 	})
 }
+
+func TestUnpinMessage(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM message_pins WHERE message_id = \\$1 AND channel_id = \\$2").
+		WithArgs(3, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	removed, err := repo.UnpinMessage(1, 3)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+}
+
+func TestFetchPins(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	columns := []string{"id", "user_id", "channel_id", "parent_id", "body", "created_at", "edited_at", "deleted_at", "reply_count", "last_reply_at", "id", "name", "avatar_url", "created_at", "pinned_by", "pinned_at"}
+	rows := sqlmock.NewRows(columns).
+		AddRow(3, 5, 1, nil, []byte(`{"type":"text","content":"read the rules"}`), "2025-07-19T10:30:00Z", nil, nil, 0, nil, 5, "Alice", "", "2025-01-01T00:00:00Z", 2, "2025-07-20T08:00:00Z")
+
+	mock.ExpectQuery("SELECT (.+), p.pinned_by, p.pinned_at FROM message_pins p JOIN messages m ON m.id = p.message_id(.+)WHERE p.channel_id = \\$1 AND m.deleted_at IS NULL ORDER BY p.pinned_at DESC").
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	pins, err := repo.FetchPins(1)
+	assert.NoError(t, err)
+	assert.Len(t, pins, 1)
+	assert.Equal(t, 3, pins[0].MessageID)
+	assert.Equal(t, 2, pins[0].PinnedBy)
+	assert.Equal(t, "2025-07-20T08:00:00Z", pins[0].PinnedAt)
+	assert.Equal(t, "Alice", pins[0].Message.User.Name)
+}
+
+func TestSetVotes(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	t.Run("replaces votes", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT 1 FROM messages WHERE id = \\$1 FOR UPDATE").
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM poll_votes WHERE message_id = \\$1 AND user_id = \\$2").
+			WithArgs(3, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO poll_votes \\(message_id, user_id, option_index\\) SELECT \\$1, \\$2, UNNEST\\(\\$3::int\\[\\]\\)").
+			WithArgs(3, 2, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.SetVotes(3, 2, []int{0, 2}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("retracts votes", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT 1 FROM messages").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM poll_votes").
+			WithArgs(3, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.SetVotes(3, 2, nil))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCountVotes(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT option_index, COUNT\\(\\*\\) FROM poll_votes WHERE message_id = \\$1 GROUP BY option_index").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"option_index", "count"}).AddRow(0, 2).AddRow(2, 1))
+	mock.ExpectQuery("SELECT COUNT\\(DISTINCT user_id\\) FROM poll_votes WHERE message_id = \\$1").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	counts, voters, err := repo.CountVotes(3)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{0: 2, 2: 1}, counts)
+	assert.Equal(t, 2, voters)
+}
+
+func TestFetchUserVotes(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT option_index FROM poll_votes WHERE message_id = \\$1 AND user_id = \\$2").
+		WithArgs(3, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"option_index"}).AddRow(1))
+
+	options, err := repo.FetchUserVotes(3, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, options)
+}
+
+func TestFetchPostingState(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	t.Run("muted member", func(t *testing.T) {
+		mock.ExpectQuery("SELECT\\s+COALESCE\\(\\(SELECT archived_at IS NOT NULL FROM channels WHERE id = \\$1\\), false\\),\\s+COALESCE\\(\\(SELECT role FROM memberships WHERE channel_id = \\$1 AND user_id = \\$2\\), ''\\),\\s+EXISTS \\((.+)mo.action = \\$3(.+)\\),\\s+EXISTS \\((.+)JOIN user_blocks b (.+)WHERE c.id = \\$1 AND c.dm_key IS NOT NULL\\s+\\)").
+			WithArgs(1, 2, entities.ModerationMute).
+			WillReturnRows(sqlmock.NewRows([]string{"archived", "role", "muted", "blocked"}).AddRow(false, entities.RoleMember, true, false))
+
+		state, err := repo.FetchPostingState(1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, PostingState{Role: entities.RoleMember, Muted: true}, state)
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		mock.ExpectQuery("SELECT").
+			WithArgs(1, 3, entities.ModerationMute).
+			WillReturnRows(sqlmock.NewRows([]string{"archived", "role", "muted", "blocked"}).AddRow(false, "", false, false))
+
+		state, err := repo.FetchPostingState(1, 3)
+		assert.NoError(t, err)
+		assert.Empty(t, state.Role)
+	})
+}
+
+func TestFetchFilterConfig(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT config FROM channel_filters WHERE channel_id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"config"}).AddRow([]byte(`{"invite_links":{"action":"reject"}}`)))
+
+	config, err := repo.FetchFilterConfig(1)
+	assert.NoError(t, err)
+	assert.Equal(t, entities.FilterConfig{InviteLinks: &entities.InviteFilterConfig{Action: "reject"}}, config)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFetchPendingFlags(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	columns := []string{"id", "user_id", "channel_id", "parent_id", "body", "created_at", "edited_at", "deleted_at", "reply_count", "last_reply_at", "id", "name", "avatar_url", "created_at", "id", "message_id", "channel_id", "reasons", "status", "reviewed_by", "reviewed_at", "created_at"}
+	rows := sqlmock.NewRows(columns).
+		AddRow(7, 5, 1, nil, []byte(`{"type":"text","content":"nooooooo"}`), "2025-07-19T10:30:00Z", nil, nil, 0, nil, 5, "Alice", "", "2025-01-01T00:00:00Z", 3, 7, 1, "{\"message contains repeated characters\"}", "pending", nil, nil, "2025-07-19T10:30:00Z")
+
+	mock.ExpectQuery("SELECT (.+), f.id, f.message_id, (.+) FROM message_flags f JOIN messages m ON m.id = f.message_id(.+)WHERE f.channel_id = \\$1 AND f.status = \\$2 ORDER BY f.created_at").
+		WithArgs(1, entities.FlagPending).
+		WillReturnRows(rows)
+
+	flags, err := repo.FetchPendingFlags(1)
+	assert.NoError(t, err)
+	assert.Len(t, flags, 1)
+	assert.Equal(t, 3, flags[0].ID)
+	assert.Equal(t, []string{"message contains repeated characters"}, flags[0].Reasons)
+	assert.Equal(t, 7, flags[0].Message.ID)
+	assert.Equal(t, "Alice", flags[0].Message.User.Name)
+}
+
+func TestResolveFlag(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	columns := []string{"id", "message_id", "channel_id", "reasons", "status", "reviewed_by", "reviewed_at", "created_at"}
+
+	t.Run("removed", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE message_flags AS f SET status = \\$2, reviewed_by = \\$3, reviewed_at = NOW\\(\\) WHERE f.id = \\$1 AND f.status = \\$4").
+			WithArgs(3, entities.FlagRemoved, 2, entities.FlagPending).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 7, 1, "{spam}", "removed", 2, "2025-07-20T08:00:00Z", "2025-07-19T10:30:00Z"))
+		mock.ExpectQuery("UPDATE messages SET body = 'null'::jsonb, deleted_at = NOW\\(\\) WHERE id = \\$1 AND deleted_at IS NULL").
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "channel_id", "body", "created_at", "edited_at", "deleted_at"}).AddRow(7, 5, 1, []byte("null"), "2025-07-19T10:00:00Z", nil, "2025-07-20T08:00:00Z"))
+		mock.ExpectCommit()
+
+		flag, err := repo.ResolveFlag(3, entities.FlagRemoved, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.FlagRemoved, flag.Status)
+		assert.Equal(t, 2, *flag.ReviewedBy)
+		assert.Equal(t, 7, flag.Message.ID)
+	})
+
+	t.Run("approved", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE message_flags AS f").
+			WithArgs(3, entities.FlagApproved, 2, entities.FlagPending).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 7, 1, "{spam}", "approved", 2, "2025-07-20T08:00:00Z", "2025-07-19T10:30:00Z"))
+		mock.ExpectCommit()
+
+		flag, err := repo.ResolveFlag(3, entities.FlagApproved, 2)
+		assert.NoError(t, err)
+		assert.Nil(t, flag.Message)
+	})
+
+	t.Run("already reviewed", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE message_flags AS f").
+			WithArgs(3, entities.FlagRemoved, 2, entities.FlagPending).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err := repo.ResolveFlag(3, entities.FlagRemoved, 2)
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,25 +1,105 @@
 package chat
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/aramceballos/chat-group-server/pkg/filter"
+	"github.com/aramceballos/chat-group-server/pkg/schema"
 )
 
 type Service interface {
 	CheckUserMembership(channelId int, userId int) (bool, error)
+	CanPost(channelId int, userId int) error
 	FetchUserById(userId int) (entities.User, error)
-	InsertMessage(channelId int, userId int, msgBody []byte) (entities.Message, error)
+	InsertMessage(channelId int, userId int, parentId int, clientMsgId string, msgBody []byte) (entities.Message, bool, error)
+	FetchMessages(channelId int, query entities.MessageQuery) (entities.MessagePage, error)
+	EditMessage(channelId int, userId int, messageId int, msgBody []byte) (entities.Message, error)
+	DeleteMessage(channelId int, userId int, messageId int) (entities.Message, error)
+	AddReaction(channelId int, userId int, messageId int, emoji string) (entities.ReactionUpdate, error)
+	RemoveReaction(channelId int, userId int, messageId int, emoji string) (entities.ReactionUpdate, error)
+	MarkRead(channelId int, userId int, messageId int) (entities.ReadReceipt, error)
+	FetchUnreadCounts(userId int) ([]entities.UnreadCount, error)
+	SearchMessages(query entities.SearchQuery) (entities.SearchPage, error)
+	PinMessage(channelId int, userId int, messageId int) (entities.Pin, error)
+	UnpinMessage(channelId int, userId int, messageId int) (entities.PinUpdate, error)
+	FetchPins(channelId int) ([]entities.Pin, error)
+	Vote(channelId int, userId int, messageId int, options []int) (entities.PollTally, error)
+	FetchPollResults(channelId int, userId int, messageId int) (entities.PollResults, error)
+	ScreenMessage(channelId int, msgBody []byte) (filter.Decision, error)
+	FlagMessage(message entities.Message, reasons []string) (entities.MessageFlag, error)
+	ForgetFilters(channelId int)
+	FetchFlags(channelId int, userId int) ([]entities.MessageFlag, error)
+	ReviewFlag(channelId int, userId int, flagId int, action string) (entities.MessageFlag, error)
 }
 
+var (
+	ErrMessageNotFound    = errors.New("message not found")
+	ErrNotAllowed         = errors.New("you are not allowed to modify this message")
+	ErrInvalidEmoji       = errors.New("invalid reaction emoji")
+	ErrInvalidParent      = errors.New("replies must target a top-level message of the same channel")
+	ErrInvalidClientMsgId = errors.New("client_msg_id is too long")
+	ErrClientMsgIdInUse   = errors.New("client_msg_id was already used in another channel")
+	ErrInvalidSearch      = errors.New("search text must be between 1 and 256 characters")
+	ErrPinNotAllowed      = errors.New("only channel admins can pin messages")
+	ErrAlreadyPinned      = errors.New("message is already pinned")
+	ErrNotPinned          = errors.New("message is not pinned")
+	ErrPinLimit           = errors.New("this channel has reached its pin limit")
+	ErrChannelArchived    = errors.New("channel is archived")
+	ErrNotMember          = entities.ErrNotMember
+	ErrReadOnly           = errors.New("you have read-only access to this channel")
+	ErrBlocked            = errors.New("messages between you and this user are blocked")
+	ErrMuted              = errors.New("you are muted in this channel")
+	ErrNotAPoll           = errors.New("message is not a poll")
+	ErrPollClosed         = errors.New("poll is closed")
+	ErrInvalidVote        = errors.New("votes must be distinct options of the poll, at most one unless it is multiple choice")
+	ErrReviewNotAllowed   = errors.New("only channel admins can review flagged messages")
+	ErrFlagNotFound       = errors.New("flag not found")
+	ErrSystemMessage      = errors.New("system messages cannot be modified")
+	ErrAlreadyReviewed    = errors.New("flag was already reviewed")
+)
+
+const (
+	DefaultMessageLimit  = 50
+	MaxMessageLimit      = 100
+	MaxEmojiLength       = 64
+	MaxClientMsgIdLength = 64
+	DefaultSearchLimit   = 20
+	MaxSearchLength      = 256
+	MaxPinsPerChannel    = 50
+	// How long the compiled filters of a channel are used before they are
+	// fetched again, so updates made on other replicas apply
+	FilterCacheTTL = 30 * time.Second
+)
+
 type service struct {
 	repo Repository
+
+	// Filters of the channels compiled by ScreenMessage, swept of the
+	// expired ones every FilterCacheTTL
+	chainsMu  sync.Mutex
+	chains    map[int]cachedChain
+	lastSweep time.Time
+}
+
+// cachedChain is the chain of a channel compiled from its filters as they
+// were at loadedAt
+type cachedChain struct {
+	chain    filter.Chain
+	loadedAt time.Time
 }
 
 func NewService(repo Repository) Service {
 	return &service{
-		repo,
+		repo:   repo,
+		chains: map[int]cachedChain{},
 	}
 }
 
@@ -41,11 +121,628 @@ func (s *service) FetchUserById(userId int) (entities.User, error) {
 	return user, nil
 }
 
-func (s *service) InsertMessage(channelId int, userId int, msgBody []byte) (entities.Message, error) {
-	message, err := s.repo.InsertMessage(channelId, userId, msgBody)
+// InsertMessage stores a message. created is false when the user retried a
+// clientMsgId that was already stored, in which case that message is returned.
+// Client message ids are unique per user, so reusing one in another channel
+// is a conflict rather than a retry.
+func (s *service) InsertMessage(channelId int, userId int, parentId int, clientMsgId string, msgBody []byte) (entities.Message, bool, error) {
+	if len(clientMsgId) > MaxClientMsgIdLength {
+		return entities.Message{}, false, ErrInvalidClientMsgId
+	}
+
+	if err := s.checkCanPost(channelId, userId); err != nil {
+		return entities.Message{}, false, err
+	}
+
+	if parentId != 0 {
+		parent, err := s.fetchChannelMessage(channelId, parentId)
+		if err == ErrMessageNotFound || (err == nil && parent.ParentID != nil) {
+			return entities.Message{}, false, ErrInvalidParent
+		}
+		if err != nil {
+			return entities.Message{}, false, err
+		}
+	}
+
+	message, created, err := s.repo.InsertMessage(channelId, userId, parentId, clientMsgId, msgBody)
 	if err != nil {
 		log.Printf("[chat service error] error inserting message: %s", err.Error())
-		return entities.Message{}, fmt.Errorf("error inserting message")
+		return entities.Message{}, false, fmt.Errorf("error inserting message")
+	}
+	if !created && message.ChannelID != channelId {
+		return entities.Message{}, false, ErrClientMsgIdInUse
+	}
+	message.Body = schema.Normalize(message.Body)
+	return message, created, nil
+}
+
+// CanPost returns why userId may not write in the channel, nil if they may
+func (s *service) CanPost(channelId int, userId int) error {
+	return s.checkCanPost(channelId, userId)
+}
+
+// checkCanPost checks that userId may write in the channel: it is not
+// archived, they are a member who may post, are not muted and, in a direct
+// message, not blocked. Checked on every send, edit, reaction and vote, as any
+// of it may change while the user is connected.
+func (s *service) checkCanPost(channelId int, userId int) error {
+	state, err := s.repo.FetchPostingState(channelId, userId)
+	if err != nil {
+		log.Printf("[chat service error] error fetching posting state: %s", err.Error())
+		return fmt.Errorf("error checking posting permissions")
+	}
+	switch {
+	case state.Archived:
+		return ErrChannelArchived
+	case state.Role == "":
+		return ErrNotMember
+	case !entities.CanPost(state.Role):
+		return ErrReadOnly
+	case state.Muted:
+		return ErrMuted
+	case state.Blocked:
+		return ErrBlocked
+	}
+	return nil
+}
+
+func (s *service) FetchMessages(channelId int, query entities.MessageQuery) (entities.MessagePage, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultMessageLimit
+	}
+	if query.Limit > MaxMessageLimit {
+		query.Limit = MaxMessageLimit
+	}
+
+	if query.ParentID > 0 {
+		if _, err := s.fetchChannelMessage(channelId, query.ParentID); err != nil {
+			return entities.MessagePage{}, err
+		}
+	}
+
+	// Ask for one extra row to know if there are more messages past this page
+	limit := query.Limit
+	query.Limit++
+	messages, err := s.repo.FetchMessages(channelId, query)
+	if err != nil {
+		log.Printf("[chat service error] error fetching messages: %s", err.Error())
+		return entities.MessagePage{}, fmt.Errorf("error fetching messages")
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+
+	// Pages are always returned oldest first
+	if query.After == 0 {
+		slices.Reverse(messages)
+	}
+
+	if len(messages) > 0 {
+		messageIds := make([]int, len(messages))
+		for i, message := range messages {
+			messageIds[i] = message.ID
+		}
+		reactions, err := s.repo.FetchReactions(messageIds, query.ViewerID)
+		if err != nil {
+			log.Printf("[chat service error] error fetching reactions: %s", err.Error())
+			return entities.MessagePage{}, fmt.Errorf("error fetching reactions")
+		}
+		for i := range messages {
+			messages[i].Body = schema.Normalize(messages[i].Body)
+			messages[i].Reactions = reactions[messages[i].ID]
+		}
+	}
+
+	return entities.MessagePage{
+		Messages: messages,
+		HasMore:  hasMore,
+	}, nil
+}
+
+// fetchChannelMessage returns a live message, making sure it belongs to channelId
+func (s *service) fetchChannelMessage(channelId int, messageId int) (entities.Message, error) {
+	message, err := s.repo.FetchMessage(messageId)
+	if err == sql.ErrNoRows {
+		return entities.Message{}, ErrMessageNotFound
+	}
+	if err != nil {
+		log.Printf("[chat service error] error fetching message: %s", err.Error())
+		return entities.Message{}, fmt.Errorf("error fetching message")
+	}
+	if message.ChannelID != channelId || message.DeletedAt != nil {
+		return entities.Message{}, ErrMessageNotFound
 	}
 	return message, nil
 }
+
+// authorizeModification fetches a live message of the channel and checks that
+// userId is its author or a channel admin. System messages are stored under
+// the user they are about, but nobody may modify them.
+func (s *service) authorizeModification(channelId int, userId int, messageId int) (entities.Message, error) {
+	message, err := s.fetchChannelMessage(channelId, messageId)
+	if err != nil {
+		return entities.Message{}, err
+	}
+	if schema.IsSystem(message.Body) {
+		return entities.Message{}, ErrSystemMessage
+	}
+
+	if message.UserID == int64(userId) {
+		return message, nil
+	}
+
+	admin, err := s.isChannelAdmin(channelId, userId)
+	if err != nil {
+		return entities.Message{}, err
+	}
+	if !admin {
+		return entities.Message{}, ErrNotAllowed
+	}
+
+	return message, nil
+}
+
+func (s *service) isChannelAdmin(channelId int, userId int) (bool, error) {
+	role, err := s.repo.FetchMembershipRole(channelId, userId)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("[chat service error] error fetching membership role: %s", err.Error())
+		return false, fmt.Errorf("error fetching membership role")
+	}
+	return entities.CanManage(role), nil
+}
+
+// EditMessage changes the body of a message. Editing is writing, so the
+// editor must be allowed to post too, or edits would get around mutes.
+func (s *service) EditMessage(channelId int, userId int, messageId int, msgBody []byte) (entities.Message, error) {
+	original, err := s.authorizeModification(channelId, userId, messageId)
+	if err != nil {
+		return entities.Message{}, err
+	}
+	if err := s.checkCanPost(channelId, userId); err != nil {
+		return entities.Message{}, err
+	}
+
+	message, err := s.repo.EditMessage(messageId, msgBody)
+	if err == sql.ErrNoRows {
+		return entities.Message{}, ErrMessageNotFound
+	}
+	if err != nil {
+		log.Printf("[chat service error] error editing message: %s", err.Error())
+		return entities.Message{}, fmt.Errorf("error editing message")
+	}
+	message.Body = schema.Normalize(message.Body)
+	message.User = original.User
+	return message, nil
+}
+
+func (s *service) DeleteMessage(channelId int, userId int, messageId int) (entities.Message, error) {
+	original, err := s.authorizeModification(channelId, userId, messageId)
+	if err != nil {
+		return entities.Message{}, err
+	}
+
+	message, err := s.repo.DeleteMessage(messageId)
+	if err == sql.ErrNoRows {
+		return entities.Message{}, ErrMessageNotFound
+	}
+	if err != nil {
+		log.Printf("[chat service error] error deleting message: %s", err.Error())
+		return entities.Message{}, fmt.Errorf("error deleting message")
+	}
+	message.User = original.User
+	return message, nil
+}
+
+func (s *service) AddReaction(channelId int, userId int, messageId int, emoji string) (entities.ReactionUpdate, error) {
+	return s.updateReaction(channelId, userId, messageId, emoji, s.repo.AddReaction)
+}
+
+func (s *service) RemoveReaction(channelId int, userId int, messageId int, emoji string) (entities.ReactionUpdate, error) {
+	return s.updateReaction(channelId, userId, messageId, emoji, s.repo.RemoveReaction)
+}
+
+func (s *service) updateReaction(channelId int, userId int, messageId int, emoji string, update func(int, int, string) (bool, error)) (entities.ReactionUpdate, error) {
+	if emoji == "" || len(emoji) > MaxEmojiLength {
+		return entities.ReactionUpdate{}, ErrInvalidEmoji
+	}
+	if err := s.checkCanPost(channelId, userId); err != nil {
+		return entities.ReactionUpdate{}, err
+	}
+	if _, err := s.fetchChannelMessage(channelId, messageId); err != nil {
+		return entities.ReactionUpdate{}, err
+	}
+
+	if _, err := update(messageId, userId, emoji); err != nil {
+		log.Printf("[chat service error] error updating reaction: %s", err.Error())
+		return entities.ReactionUpdate{}, fmt.Errorf("error updating reaction")
+	}
+
+	count, err := s.repo.CountReactions(messageId, emoji)
+	if err != nil {
+		log.Printf("[chat service error] error counting reactions: %s", err.Error())
+		return entities.ReactionUpdate{}, fmt.Errorf("error counting reactions")
+	}
+
+	return entities.ReactionUpdate{
+		MessageID: messageId,
+		UserID:    userId,
+		Emoji:     emoji,
+		Count:     count,
+	}, nil
+}
+
+func (s *service) MarkRead(channelId int, userId int, messageId int) (entities.ReadReceipt, error) {
+	message, err := s.repo.FetchMessage(messageId)
+	if err == sql.ErrNoRows || (err == nil && message.ChannelID != channelId) {
+		return entities.ReadReceipt{}, ErrMessageNotFound
+	}
+	if err != nil {
+		log.Printf("[chat service error] error fetching message: %s", err.Error())
+		return entities.ReadReceipt{}, fmt.Errorf("error fetching message")
+	}
+
+	lastRead, err := s.repo.MarkRead(channelId, userId, messageId)
+	if err != nil {
+		log.Printf("[chat service error] error marking channel as read: %s", err.Error())
+		return entities.ReadReceipt{}, fmt.Errorf("error marking channel as read")
+	}
+
+	return entities.ReadReceipt{
+		UserID:    userId,
+		MessageID: lastRead,
+	}, nil
+}
+
+func (s *service) FetchUnreadCounts(userId int) ([]entities.UnreadCount, error) {
+	counts, err := s.repo.FetchUnreadCounts(userId)
+	if err != nil {
+		log.Printf("[chat service error] error fetching unread counts: %s", err.Error())
+		return nil, fmt.Errorf("error fetching unread counts")
+	}
+	return counts, nil
+}
+
+func (s *service) SearchMessages(query entities.SearchQuery) (entities.SearchPage, error) {
+	query.Text = strings.TrimSpace(query.Text)
+	if query.Text == "" || len(query.Text) > MaxSearchLength {
+		return entities.SearchPage{}, ErrInvalidSearch
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultSearchLimit
+	}
+	if query.Limit > MaxMessageLimit {
+		query.Limit = MaxMessageLimit
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	// Ask for one extra row to know if there are more results past this page
+	limit := query.Limit
+	query.Limit++
+	results, err := s.repo.SearchMessages(query)
+	if err != nil {
+		log.Printf("[chat service error] error searching messages: %s", err.Error())
+		return entities.SearchPage{}, fmt.Errorf("error searching messages")
+	}
+
+	hasMore := len(results) > limit
+	if hasMore {
+		results = results[:limit]
+	}
+	for i := range results {
+		results[i].Message.Body = schema.Normalize(results[i].Message.Body)
+	}
+
+	return entities.SearchPage{
+		Results: results,
+		HasMore: hasMore,
+	}, nil
+}
+
+func (s *service) PinMessage(channelId int, userId int, messageId int) (entities.Pin, error) {
+	admin, err := s.isChannelAdmin(channelId, userId)
+	if err != nil {
+		return entities.Pin{}, err
+	}
+	if !admin {
+		return entities.Pin{}, ErrPinNotAllowed
+	}
+
+	message, err := s.fetchChannelMessage(channelId, messageId)
+	if err != nil {
+		return entities.Pin{}, err
+	}
+
+	pinnedAt, err := s.repo.PinMessage(channelId, messageId, userId, MaxPinsPerChannel)
+	if err == sql.ErrNoRows {
+		// Nothing was pinned, either because of the limit or a previous pin
+		count, err := s.repo.CountPins(channelId)
+		if err != nil {
+			log.Printf("[chat service error] error counting pins: %s", err.Error())
+			return entities.Pin{}, fmt.Errorf("error pinning message")
+		}
+		if count >= MaxPinsPerChannel {
+			return entities.Pin{}, ErrPinLimit
+		}
+		return entities.Pin{}, ErrAlreadyPinned
+	}
+	if err != nil {
+		log.Printf("[chat service error] error pinning message: %s", err.Error())
+		return entities.Pin{}, fmt.Errorf("error pinning message")
+	}
+	message.Body = schema.Normalize(message.Body)
+
+	return entities.Pin{
+		MessageID: messageId,
+		PinnedBy:  userId,
+		PinnedAt:  pinnedAt,
+		Message:   message,
+	}, nil
+}
+
+func (s *service) UnpinMessage(channelId int, userId int, messageId int) (entities.PinUpdate, error) {
+	admin, err := s.isChannelAdmin(channelId, userId)
+	if err != nil {
+		return entities.PinUpdate{}, err
+	}
+	if !admin {
+		return entities.PinUpdate{}, ErrPinNotAllowed
+	}
+
+	removed, err := s.repo.UnpinMessage(channelId, messageId)
+	if err != nil {
+		log.Printf("[chat service error] error unpinning message: %s", err.Error())
+		return entities.PinUpdate{}, fmt.Errorf("error unpinning message")
+	}
+	if !removed {
+		return entities.PinUpdate{}, ErrNotPinned
+	}
+
+	return entities.PinUpdate{
+		MessageID: messageId,
+		UserID:    userId,
+	}, nil
+}
+
+func (s *service) FetchPins(channelId int) ([]entities.Pin, error) {
+	pins, err := s.repo.FetchPins(channelId)
+	if err != nil {
+		log.Printf("[chat service error] error fetching pins: %s", err.Error())
+		return nil, fmt.Errorf("error fetching pins")
+	}
+	for i := range pins {
+		pins[i].Message.Body = schema.Normalize(pins[i].Message.Body)
+	}
+	return pins, nil
+}
+
+// fetchPoll returns a live poll of the channel
+func (s *service) fetchPoll(channelId int, messageId int) (*schema.Poll, error) {
+	message, err := s.fetchChannelMessage(channelId, messageId)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := schema.Decode(message.Body)
+	if err != nil {
+		return nil, ErrNotAPoll
+	}
+	poll, ok := body.(*schema.Poll)
+	if !ok {
+		return nil, ErrNotAPoll
+	}
+	return poll, nil
+}
+
+// Vote replaces the votes of the user on a poll with options, indexes of the
+// poll options. No options retracts the vote.
+func (s *service) Vote(channelId int, userId int, messageId int, options []int) (entities.PollTally, error) {
+	if err := s.checkCanPost(channelId, userId); err != nil {
+		return entities.PollTally{}, err
+	}
+	poll, err := s.fetchPoll(channelId, messageId)
+	if err != nil {
+		return entities.PollTally{}, err
+	}
+	if poll.Closed(time.Now()) {
+		return entities.PollTally{}, ErrPollClosed
+	}
+
+	if len(options) > 1 && !poll.MultipleChoice {
+		return entities.PollTally{}, ErrInvalidVote
+	}
+	seen := map[int]bool{}
+	for _, option := range options {
+		if option < 0 || option >= len(poll.Options) || seen[option] {
+			return entities.PollTally{}, ErrInvalidVote
+		}
+		seen[option] = true
+	}
+
+	if err := s.repo.SetVotes(messageId, userId, options); err != nil {
+		log.Printf("[chat service error] error voting: %s", err.Error())
+		return entities.PollTally{}, fmt.Errorf("error voting")
+	}
+
+	return s.tallyPoll(messageId, poll)
+}
+
+func (s *service) FetchPollResults(channelId int, userId int, messageId int) (entities.PollResults, error) {
+	poll, err := s.fetchPoll(channelId, messageId)
+	if err != nil {
+		return entities.PollResults{}, err
+	}
+
+	tally, err := s.tallyPoll(messageId, poll)
+	if err != nil {
+		return entities.PollResults{}, err
+	}
+
+	voted, err := s.repo.FetchUserVotes(messageId, userId)
+	if err != nil {
+		log.Printf("[chat service error] error fetching votes: %s", err.Error())
+		return entities.PollResults{}, fmt.Errorf("error fetching votes")
+	}
+
+	return entities.PollResults{
+		PollTally: tally,
+		Voted:     voted,
+	}, nil
+}
+
+func (s *service) tallyPoll(messageId int, poll *schema.Poll) (entities.PollTally, error) {
+	counts, voters, err := s.repo.CountVotes(messageId)
+	if err != nil {
+		log.Printf("[chat service error] error counting votes: %s", err.Error())
+		return entities.PollTally{}, fmt.Errorf("error counting votes")
+	}
+
+	tally := entities.PollTally{
+		MessageID: messageId,
+		Counts:    make([]int, len(poll.Options)),
+		Voters:    voters,
+		Closed:    poll.Closed(time.Now()),
+	}
+	for option, count := range counts {
+		if option < len(tally.Counts) {
+			tally.Counts[option] = count
+		}
+	}
+	return tally, nil
+}
+
+// ScreenMessage runs a message body through the filters of the channel. The
+// body of the decision is the one to store, with the redactions applied.
+func (s *service) ScreenMessage(channelId int, msgBody []byte) (filter.Decision, error) {
+	chain, err := s.filterChain(channelId)
+	if err != nil {
+		log.Printf("[chat service error] error loading filters of channel %d: %s", channelId, err.Error())
+		return filter.Decision{}, fmt.Errorf("error screening message")
+	}
+
+	decision, err := chain.Run(msgBody)
+	if err != nil {
+		log.Printf("[chat service error] error running filters: %s", err.Error())
+		return filter.Decision{}, fmt.Errorf("error screening message")
+	}
+	if decision.Action == filter.Redact || decision.Action == filter.Flag {
+		decision.Body = schema.Normalize(decision.Body)
+	}
+	return decision, nil
+}
+
+// filterChain returns the compiled filters of a channel, fetching them again
+// once they are FilterCacheTTL old
+func (s *service) filterChain(channelId int) (filter.Chain, error) {
+	now := time.Now()
+	s.chainsMu.Lock()
+	cached, ok := s.chains[channelId]
+	s.chainsMu.Unlock()
+	if ok && now.Sub(cached.loadedAt) < FilterCacheTTL {
+		return cached.chain, nil
+	}
+
+	config, err := s.repo.FetchFilterConfig(channelId)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := filter.Build(config)
+	if err != nil {
+		return nil, err
+	}
+
+	s.chainsMu.Lock()
+	defer s.chainsMu.Unlock()
+	s.chains[channelId] = cachedChain{chain: chain, loadedAt: now}
+	// Channels that stopped sending, or were deleted, are dropped
+	if now.Sub(s.lastSweep) >= FilterCacheTTL {
+		for id, cached := range s.chains {
+			if now.Sub(cached.loadedAt) >= FilterCacheTTL {
+				delete(s.chains, id)
+			}
+		}
+		s.lastSweep = now
+	}
+	return chain, nil
+}
+
+// ForgetFilters drops the compiled filters of a channel, once they were
+// updated or the channel deleted, so the next message fetches them again
+func (s *service) ForgetFilters(channelId int) {
+	s.chainsMu.Lock()
+	delete(s.chains, channelId)
+	s.chainsMu.Unlock()
+}
+
+// FlagMessage queues a stored message for review by the channel admins
+func (s *service) FlagMessage(message entities.Message, reasons []string) (entities.MessageFlag, error) {
+	flag, err := s.repo.FlagMessage(message.ID, message.ChannelID, reasons)
+	if err != nil {
+		log.Printf("[chat service error] error flagging message: %s", err.Error())
+		return entities.MessageFlag{}, fmt.Errorf("error flagging message")
+	}
+	return flag, nil
+}
+
+// FetchFlags returns the review queue of the channel
+func (s *service) FetchFlags(channelId int, userId int) ([]entities.MessageFlag, error) {
+	admin, err := s.isChannelAdmin(channelId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !admin {
+		return nil, ErrReviewNotAllowed
+	}
+
+	flags, err := s.repo.FetchPendingFlags(channelId)
+	if err != nil {
+		log.Printf("[chat service error] error fetching flags: %s", err.Error())
+		return nil, fmt.Errorf("error fetching flags")
+	}
+	for i := range flags {
+		flags[i].Message.Body = schema.Normalize(flags[i].Message.Body)
+	}
+	return flags, nil
+}
+
+// ReviewFlag approves a flagged message, or removes it by deleting the
+// message. The flag of a removal carries the deleted message.
+func (s *service) ReviewFlag(channelId int, userId int, flagId int, action string) (entities.MessageFlag, error) {
+	admin, err := s.isChannelAdmin(channelId, userId)
+	if err != nil {
+		return entities.MessageFlag{}, err
+	}
+	if !admin {
+		return entities.MessageFlag{}, ErrReviewNotAllowed
+	}
+
+	flag, err := s.repo.FetchFlag(flagId)
+	if err == sql.ErrNoRows || (err == nil && flag.ChannelID != channelId) {
+		return entities.MessageFlag{}, ErrFlagNotFound
+	}
+	if err != nil {
+		log.Printf("[chat service error] error fetching flag: %s", err.Error())
+		return entities.MessageFlag{}, fmt.Errorf("error reviewing flag")
+	}
+	if flag.Status != entities.FlagPending {
+		return entities.MessageFlag{}, ErrAlreadyReviewed
+	}
+
+	status := entities.FlagApproved
+	if action == "remove" {
+		status = entities.FlagRemoved
+	}
+
+	flag, err = s.repo.ResolveFlag(flagId, status, userId)
+	if err == sql.ErrNoRows {
+		return entities.MessageFlag{}, ErrAlreadyReviewed
+	}
+	if err != nil {
+		log.Printf("[chat service error] error resolving flag: %s", err.Error())
+		return entities.MessageFlag{}, fmt.Errorf("error reviewing flag")
+	}
+	return flag, nil
+}
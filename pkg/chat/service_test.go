@@ -1,10 +1,15 @@
 package chat
 
 import (
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/aramceballos/chat-group-server/pkg/filter"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,6 +20,45 @@ type mockRepository struct {
 	userError        error
 	message          entities.Message
 	messageError     error
+	duplicate        bool
+	messages         []entities.Message
+	messagesError    error
+	stored           entities.Message
+	storedError      error
+	updated          entities.Message
+	updatedError     error
+	role             string
+	roleError        error
+	reactionError    error
+	reactionCount    int
+	reactions        map[int][]entities.Reaction
+	lastRead         int
+	readError        error
+	unread           []entities.UnreadCount
+	unreadError      error
+	results          []entities.SearchResult
+	searchError      error
+	searchQuery      *entities.SearchQuery
+	pinnedAt         string
+	pinError         error
+	unpinned         bool
+	pinCount         int
+	pins             []entities.Pin
+	pinsError        error
+	voteError        error
+	votes            *[]int
+	voteCounts       map[int]int
+	voters           int
+	userVotes        []int
+	archived         bool
+	stateError       error
+	blocked          bool
+	muted            bool
+	filters          entities.FilterConfig
+	filterFetches    *int
+	flag             entities.MessageFlag
+	flagError        error
+	resolveError     error
 }
 
 func (mr mockRepository) CheckUserMembership(channelId int, userId int) (bool, error) {
@@ -25,8 +69,137 @@ func (mr mockRepository) FetchUserById(userId int) (entities.User, error) {
 	return mr.user, mr.userError
 }
 
-func (mr mockRepository) InsertMessage(channelId int, userId int, msgBody []byte) (entities.Message, error) {
-	return mr.message, mr.messageError
+func (mr mockRepository) InsertMessage(channelId int, userId int, parentId int, clientMsgId string, msgBody []byte) (entities.Message, bool, error) {
+	return mr.message, !mr.duplicate, mr.messageError
+}
+
+func (mr mockRepository) FetchMessages(channelId int, query entities.MessageQuery) ([]entities.Message, error) {
+	if len(mr.messages) > query.Limit {
+		return mr.messages[:query.Limit], mr.messagesError
+	}
+	return mr.messages, mr.messagesError
+}
+
+func (mr mockRepository) FetchMessage(messageId int) (entities.Message, error) {
+	return mr.stored, mr.storedError
+}
+
+func (mr mockRepository) EditMessage(messageId int, msgBody []byte) (entities.Message, error) {
+	return mr.updated, mr.updatedError
+}
+
+func (mr mockRepository) DeleteMessage(messageId int) (entities.Message, error) {
+	return mr.updated, mr.updatedError
+}
+
+func (mr mockRepository) FetchMembershipRole(channelId int, userId int) (string, error) {
+	return mr.role, mr.roleError
+}
+
+func (mr mockRepository) AddReaction(messageId int, userId int, emoji string) (bool, error) {
+	return mr.reactionError == nil, mr.reactionError
+}
+
+func (mr mockRepository) RemoveReaction(messageId int, userId int, emoji string) (bool, error) {
+	return mr.reactionError == nil, mr.reactionError
+}
+
+func (mr mockRepository) CountReactions(messageId int, emoji string) (int, error) {
+	return mr.reactionCount, nil
+}
+
+func (mr mockRepository) FetchReactions(messageIds []int, viewerId int) (map[int][]entities.Reaction, error) {
+	return mr.reactions, nil
+}
+
+func (mr mockRepository) MarkRead(channelId int, userId int, messageId int) (int, error) {
+	return mr.lastRead, mr.readError
+}
+
+func (mr mockRepository) FetchUnreadCounts(userId int) ([]entities.UnreadCount, error) {
+	return mr.unread, mr.unreadError
+}
+
+func (mr mockRepository) SearchMessages(query entities.SearchQuery) ([]entities.SearchResult, error) {
+	if mr.searchQuery != nil {
+		*mr.searchQuery = query
+	}
+	if len(mr.results) > query.Limit {
+		return mr.results[:query.Limit], mr.searchError
+	}
+	return mr.results, mr.searchError
+}
+
+func (mr mockRepository) PinMessage(channelId int, messageId int, userId int, limit int) (string, error) {
+	return mr.pinnedAt, mr.pinError
+}
+
+func (mr mockRepository) UnpinMessage(channelId int, messageId int) (bool, error) {
+	return mr.unpinned, mr.pinError
+}
+
+func (mr mockRepository) CountPins(channelId int) (int, error) {
+	return mr.pinCount, nil
+}
+
+func (mr mockRepository) FetchPins(channelId int) ([]entities.Pin, error) {
+	return mr.pins, mr.pinsError
+}
+
+func (mr mockRepository) SetVotes(messageId int, userId int, options []int) error {
+	if mr.votes != nil {
+		*mr.votes = options
+	}
+	return mr.voteError
+}
+
+func (mr mockRepository) CountVotes(messageId int) (map[int]int, int, error) {
+	return mr.voteCounts, mr.voters, nil
+}
+
+func (mr mockRepository) FetchUserVotes(messageId int, userId int) ([]int, error) {
+	return mr.userVotes, nil
+}
+
+func (mr mockRepository) FetchPostingState(channelId int, userId int) (PostingState, error) {
+	state := PostingState{Archived: mr.archived, Role: mr.role, Muted: mr.muted, Blocked: mr.blocked}
+	if state.Role == "" {
+		state.Role = entities.RoleMember
+	}
+	if mr.roleError == sql.ErrNoRows {
+		state.Role = ""
+	}
+	return state, mr.stateError
+}
+
+func (mr mockRepository) FetchFilterConfig(channelId int) (entities.FilterConfig, error) {
+	if mr.filterFetches != nil {
+		*mr.filterFetches++
+	}
+	return mr.filters, nil
+}
+
+func (mr mockRepository) FlagMessage(messageId int, channelId int, reasons []string) (entities.MessageFlag, error) {
+	return entities.MessageFlag{MessageID: messageId, ChannelID: channelId, Reasons: reasons, Status: entities.FlagPending}, mr.flagError
+}
+
+func (mr mockRepository) FetchFlag(flagId int) (entities.MessageFlag, error) {
+	return mr.flag, mr.flagError
+}
+
+func (mr mockRepository) FetchPendingFlags(channelId int) ([]entities.MessageFlag, error) {
+	return []entities.MessageFlag{mr.flag}, mr.flagError
+}
+
+func (mr mockRepository) ResolveFlag(flagId int, status string, reviewerId int) (entities.MessageFlag, error) {
+	resolved := mr.flag
+	resolved.Status = status
+	resolved.ReviewedBy = &reviewerId
+	if status == entities.FlagRemoved && mr.updated.ID != 0 {
+		deleted := mr.updated
+		resolved.Message = &deleted
+	}
+	return resolved, mr.resolveError
 }
 
 func (mr mockRepository) Close() error {
@@ -89,16 +262,729 @@ func TestInsertMessageService(t *testing.T) {
 		msg := entities.Message{ID: 1, ChannelID: 1, UserID: 1}
 		mockRepo := mockRepository{message: msg}
 		s := NewService(mockRepo)
-		result, err := s.InsertMessage(1, 1, []byte("hello"))
+		result, created, err := s.InsertMessage(1, 1, 0, "", []byte("hello"))
 		assert.NoError(t, err)
+		assert.True(t, created)
 		assert.Equal(t, msg, result)
 	})
 
 	t.Run("insert error", func(t *testing.T) {
 		mockRepo := mockRepository{messageError: errors.New("insert failed")}
 		s := NewService(mockRepo)
-		result, err := s.InsertMessage(1, 1, []byte("hello"))
+		result, _, err := s.InsertMessage(1, 1, 0, "", []byte("hello"))
+		assert.Error(t, err)
+		assert.Equal(t, entities.Message{}, result)
+	})
+
+	t.Run("archived channel", func(t *testing.T) {
+		mockRepo := mockRepository{archived: true}
+		s := NewService(mockRepo)
+		_, _, err := s.InsertMessage(1, 1, 0, "", []byte("hello"))
+		assert.Equal(t, ErrChannelArchived, err)
+	})
+
+	t.Run("posting state error", func(t *testing.T) {
+		mockRepo := mockRepository{stateError: errors.New("db error")}
+		s := NewService(mockRepo)
+		_, _, err := s.InsertMessage(1, 1, 0, "", []byte("hello"))
+		assert.Error(t, err)
+		assert.NotEqual(t, ErrChannelArchived, err)
+	})
+
+	t.Run("read-only member", func(t *testing.T) {
+		mockRepo := mockRepository{role: entities.RoleReadOnly}
+		s := NewService(mockRepo)
+		_, _, err := s.InsertMessage(1, 1, 0, "", []byte("hello"))
+		assert.Equal(t, ErrReadOnly, err)
+	})
+
+	t.Run("removed member", func(t *testing.T) {
+		mockRepo := mockRepository{roleError: sql.ErrNoRows}
+		s := NewService(mockRepo)
+		_, _, err := s.InsertMessage(1, 1, 0, "", []byte("hello"))
+		assert.Equal(t, ErrNotMember, err)
+	})
+
+	t.Run("muted member", func(t *testing.T) {
+		mockRepo := mockRepository{muted: true}
+		s := NewService(mockRepo)
+		_, _, err := s.InsertMessage(1, 1, 0, "", []byte("hello"))
+		assert.Equal(t, ErrMuted, err)
+	})
+
+	t.Run("blocked direct message", func(t *testing.T) {
+		mockRepo := mockRepository{blocked: true}
+		s := NewService(mockRepo)
+		_, _, err := s.InsertMessage(1, 1, 0, "", []byte("hello"))
+		assert.Equal(t, ErrBlocked, err)
+	})
+
+	t.Run("retried client message id", func(t *testing.T) {
+		msg := entities.Message{ID: 1, ChannelID: 1, UserID: 1, ClientMsgID: "abc"}
+		mockRepo := mockRepository{message: msg, duplicate: true}
+		s := NewService(mockRepo)
+		result, created, err := s.InsertMessage(1, 1, 0, "abc", []byte("hello"))
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, msg, result)
+	})
+
+	t.Run("client message id used in another channel", func(t *testing.T) {
+		msg := entities.Message{ID: 1, ChannelID: 2, UserID: 1, ClientMsgID: "abc"}
+		s := NewService(mockRepository{message: msg, duplicate: true})
+		_, created, err := s.InsertMessage(1, 1, 0, "abc", []byte("hello"))
+		assert.Equal(t, ErrClientMsgIdInUse, err)
+		assert.False(t, created)
+	})
+
+	t.Run("client message id too long", func(t *testing.T) {
+		mockRepo := mockRepository{}
+		s := NewService(mockRepo)
+		_, _, err := s.InsertMessage(1, 1, 0, strings.Repeat("a", MaxClientMsgIdLength+1), []byte("hello"))
+		assert.ErrorIs(t, err, ErrInvalidClientMsgId)
+	})
+
+	t.Run("reply to a top-level message", func(t *testing.T) {
+		parentId := 5
+		msg := entities.Message{ID: 6, ChannelID: 1, UserID: 1, ParentID: &parentId}
+		mockRepo := mockRepository{stored: entities.Message{ID: 5, ChannelID: 1}, message: msg}
+		s := NewService(mockRepo)
+		result, _, err := s.InsertMessage(1, 1, 5, "", []byte("hello"))
+		assert.NoError(t, err)
+		assert.Equal(t, msg, result)
+	})
+
+	t.Run("reply to another channel", func(t *testing.T) {
+		mockRepo := mockRepository{stored: entities.Message{ID: 5, ChannelID: 2}}
+		s := NewService(mockRepo)
+		_, _, err := s.InsertMessage(1, 1, 5, "", []byte("hello"))
+		assert.ErrorIs(t, err, ErrInvalidParent)
+	})
+
+	t.Run("nested reply", func(t *testing.T) {
+		parentId := 4
+		mockRepo := mockRepository{stored: entities.Message{ID: 5, ChannelID: 1, ParentID: &parentId}}
+		s := NewService(mockRepo)
+		_, _, err := s.InsertMessage(1, 1, 5, "", []byte("hello"))
+		assert.ErrorIs(t, err, ErrInvalidParent)
+	})
+}
+
+func TestFetchMessagesService(t *testing.T) {
+	t.Run("latest page is returned oldest first", func(t *testing.T) {
+		mockRepo := mockRepository{messages: []entities.Message{{ID: 3}, {ID: 2}, {ID: 1}}}
+		s := NewService(mockRepo)
+		page, err := s.FetchMessages(1, entities.MessageQuery{Limit: 2})
+		assert.NoError(t, err)
+		assert.True(t, page.HasMore)
+		assert.Equal(t, []entities.Message{{ID: 2}, {ID: 3}}, page.Messages)
+	})
+
+	t.Run("reactions are attached", func(t *testing.T) {
+		reactions := []entities.Reaction{{Emoji: "👍", Count: 2, Reacted: true}}
+		mockRepo := mockRepository{
+			messages:  []entities.Message{{ID: 2}, {ID: 1}},
+			reactions: map[int][]entities.Reaction{2: reactions},
+		}
+		s := NewService(mockRepo)
+		page, err := s.FetchMessages(1, entities.MessageQuery{Limit: 10, ViewerID: 1})
+		assert.NoError(t, err)
+		assert.Nil(t, page.Messages[0].Reactions)
+		assert.Equal(t, reactions, page.Messages[1].Reactions)
+	})
+
+	t.Run("after cursor keeps ascending order", func(t *testing.T) {
+		mockRepo := mockRepository{messages: []entities.Message{{ID: 4}, {ID: 5}}}
+		s := NewService(mockRepo)
+		page, err := s.FetchMessages(1, entities.MessageQuery{After: 3, Limit: 10})
+		assert.NoError(t, err)
+		assert.False(t, page.HasMore)
+		assert.Equal(t, []entities.Message{{ID: 4}, {ID: 5}}, page.Messages)
+	})
+
+	t.Run("replies of a missing thread", func(t *testing.T) {
+		mockRepo := mockRepository{storedError: sql.ErrNoRows}
+		s := NewService(mockRepo)
+		_, err := s.FetchMessages(1, entities.MessageQuery{ParentID: 5})
+		assert.ErrorIs(t, err, ErrMessageNotFound)
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		mockRepo := mockRepository{messagesError: errors.New("db error")}
+		s := NewService(mockRepo)
+		page, err := s.FetchMessages(1, entities.MessageQuery{})
+		assert.Error(t, err)
+		assert.Equal(t, entities.MessagePage{}, page)
+	})
+}
+
+func TestEditMessageService(t *testing.T) {
+	author := entities.User{ID: 1, Name: "John"}
+	stored := entities.Message{ID: 7, UserID: 1, ChannelID: 1, User: author}
+
+	t.Run("author edits", func(t *testing.T) {
+		mockRepo := mockRepository{stored: stored, updated: entities.Message{ID: 7, UserID: 1, ChannelID: 1}}
+		s := NewService(mockRepo)
+		result, err := s.EditMessage(1, 1, 7, []byte("{}"))
+		assert.NoError(t, err)
+		assert.Equal(t, 7, result.ID)
+		assert.Equal(t, author, result.User)
+	})
+
+	t.Run("edited body is normalized", func(t *testing.T) {
+		mockRepo := mockRepository{stored: stored, updated: entities.Message{ID: 7, Body: json.RawMessage(`{ "content": "fixed",  "type": "text" }`)}}
+		s := NewService(mockRepo)
+		result, err := s.EditMessage(1, 1, 7, []byte(`{"type":"text","content":"fixed"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, `{"type":"text","content":"fixed"}`, string(result.Body))
+	})
+
+	t.Run("admin edits", func(t *testing.T) {
+		mockRepo := mockRepository{stored: stored, updated: entities.Message{ID: 7}, role: entities.RoleAdmin}
+		s := NewService(mockRepo)
+		_, err := s.EditMessage(1, 2, 7, []byte("{}"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("other member is rejected", func(t *testing.T) {
+		mockRepo := mockRepository{stored: stored, role: entities.RoleMember}
+		s := NewService(mockRepo)
+		_, err := s.EditMessage(1, 2, 7, []byte("{}"))
+		assert.ErrorIs(t, err, ErrNotAllowed)
+	})
+
+	t.Run("system message", func(t *testing.T) {
+		joined := stored
+		joined.Body = json.RawMessage(`{"type":"system","event":"member_joined","content":"John joined the channel"}`)
+		s := NewService(mockRepository{stored: joined})
+		_, err := s.EditMessage(1, 1, 7, []byte("{}"))
+		assert.Equal(t, ErrSystemMessage, err)
+	})
+
+	t.Run("muted author", func(t *testing.T) {
+		s := NewService(mockRepository{stored: stored, muted: true})
+		_, err := s.EditMessage(1, 1, 7, []byte("{}"))
+		assert.Equal(t, ErrMuted, err)
+	})
+
+	t.Run("read-only author", func(t *testing.T) {
+		s := NewService(mockRepository{stored: stored, role: entities.RoleReadOnly})
+		_, err := s.EditMessage(1, 1, 7, []byte("{}"))
+		assert.Equal(t, ErrReadOnly, err)
+	})
+
+	t.Run("blocked direct message", func(t *testing.T) {
+		s := NewService(mockRepository{stored: stored, blocked: true})
+		_, err := s.EditMessage(1, 1, 7, []byte("{}"))
+		assert.Equal(t, ErrBlocked, err)
+	})
+
+	t.Run("archived channel", func(t *testing.T) {
+		s := NewService(mockRepository{stored: stored, archived: true})
+		_, err := s.EditMessage(1, 1, 7, []byte("{}"))
+		assert.Equal(t, ErrChannelArchived, err)
+	})
+
+	t.Run("message from another channel", func(t *testing.T) {
+		mockRepo := mockRepository{stored: stored}
+		s := NewService(mockRepo)
+		_, err := s.EditMessage(2, 1, 7, []byte("{}"))
+		assert.ErrorIs(t, err, ErrMessageNotFound)
+	})
+
+	t.Run("missing message", func(t *testing.T) {
+		mockRepo := mockRepository{storedError: sql.ErrNoRows}
+		s := NewService(mockRepo)
+		_, err := s.EditMessage(1, 1, 7, []byte("{}"))
+		assert.ErrorIs(t, err, ErrMessageNotFound)
+	})
+}
+
+func TestDeleteMessageService(t *testing.T) {
+	stored := entities.Message{ID: 7, UserID: 1, ChannelID: 1}
+
+	t.Run("author deletes", func(t *testing.T) {
+		deletedAt := "2023-01-01 00:00:00"
+		mockRepo := mockRepository{stored: stored, updated: entities.Message{ID: 7, DeletedAt: &deletedAt}}
+		s := NewService(mockRepo)
+		result, err := s.DeleteMessage(1, 1, 7)
+		assert.NoError(t, err)
+		assert.NotNil(t, result.DeletedAt)
+	})
+
+	t.Run("already deleted", func(t *testing.T) {
+		deletedAt := "2023-01-01 00:00:00"
+		deleted := stored
+		deleted.DeletedAt = &deletedAt
+		mockRepo := mockRepository{stored: deleted}
+		s := NewService(mockRepo)
+		_, err := s.DeleteMessage(1, 1, 7)
+		assert.ErrorIs(t, err, ErrMessageNotFound)
+	})
+
+	t.Run("system message, even by an admin", func(t *testing.T) {
+		joined := stored
+		joined.Body = json.RawMessage(`{"type":"system","event":"member_joined","content":"John joined the channel"}`)
+		s := NewService(mockRepository{stored: joined, role: entities.RoleAdmin})
+		_, err := s.DeleteMessage(1, 2, 7)
+		assert.Equal(t, ErrSystemMessage, err)
+	})
+
+	t.Run("delete error", func(t *testing.T) {
+		mockRepo := mockRepository{stored: stored, updatedError: errors.New("db error")}
+		s := NewService(mockRepo)
+		result, err := s.DeleteMessage(1, 1, 7)
 		assert.Error(t, err)
 		assert.Equal(t, entities.Message{}, result)
 	})
 }
+
+func TestReactionService(t *testing.T) {
+	stored := entities.Message{ID: 7, UserID: 1, ChannelID: 1}
+
+	t.Run("add reaction", func(t *testing.T) {
+		mockRepo := mockRepository{stored: stored, reactionCount: 3}
+		s := NewService(mockRepo)
+		update, err := s.AddReaction(1, 2, 7, "👍")
+		assert.NoError(t, err)
+		assert.Equal(t, entities.ReactionUpdate{MessageID: 7, UserID: 2, Emoji: "👍", Count: 3}, update)
+	})
+
+	t.Run("remove reaction", func(t *testing.T) {
+		mockRepo := mockRepository{stored: stored}
+		s := NewService(mockRepo)
+		update, err := s.RemoveReaction(1, 2, 7, "👍")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, update.Count)
+	})
+
+	t.Run("empty emoji", func(t *testing.T) {
+		mockRepo := mockRepository{stored: stored}
+		s := NewService(mockRepo)
+		_, err := s.AddReaction(1, 2, 7, "")
+		assert.ErrorIs(t, err, ErrInvalidEmoji)
+	})
+
+	t.Run("message from another channel", func(t *testing.T) {
+		mockRepo := mockRepository{stored: stored}
+		s := NewService(mockRepo)
+		_, err := s.AddReaction(2, 2, 7, "👍")
+		assert.ErrorIs(t, err, ErrMessageNotFound)
+	})
+
+	t.Run("muted", func(t *testing.T) {
+		s := NewService(mockRepository{stored: stored, muted: true})
+		_, err := s.AddReaction(1, 2, 7, "👍")
+		assert.Equal(t, ErrMuted, err)
+	})
+
+	t.Run("archived channel", func(t *testing.T) {
+		s := NewService(mockRepository{stored: stored, archived: true})
+		_, err := s.RemoveReaction(1, 2, 7, "👍")
+		assert.Equal(t, ErrChannelArchived, err)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mockRepo := mockRepository{stored: stored, reactionError: errors.New("db error")}
+		s := NewService(mockRepo)
+		update, err := s.AddReaction(1, 2, 7, "👍")
+		assert.Error(t, err)
+		assert.Equal(t, entities.ReactionUpdate{}, update)
+	})
+}
+
+func TestMarkReadService(t *testing.T) {
+	stored := entities.Message{ID: 7, ChannelID: 1}
+
+	t.Run("read position moves", func(t *testing.T) {
+		mockRepo := mockRepository{stored: stored, lastRead: 7}
+		s := NewService(mockRepo)
+		receipt, err := s.MarkRead(1, 2, 7)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.ReadReceipt{UserID: 2, MessageID: 7}, receipt)
+	})
+
+	t.Run("message from another channel", func(t *testing.T) {
+		mockRepo := mockRepository{stored: stored}
+		s := NewService(mockRepo)
+		_, err := s.MarkRead(2, 2, 7)
+		assert.ErrorIs(t, err, ErrMessageNotFound)
+	})
+
+	t.Run("update error", func(t *testing.T) {
+		mockRepo := mockRepository{stored: stored, readError: errors.New("db error")}
+		s := NewService(mockRepo)
+		receipt, err := s.MarkRead(1, 2, 7)
+		assert.Error(t, err)
+		assert.Equal(t, entities.ReadReceipt{}, receipt)
+	})
+}
+
+func TestFetchUnreadCountsService(t *testing.T) {
+	t.Run("counts retrieved", func(t *testing.T) {
+		counts := []entities.UnreadCount{{ChannelID: 1, Unread: 3, Mentions: 1}}
+		mockRepo := mockRepository{unread: counts}
+		s := NewService(mockRepo)
+		result, err := s.FetchUnreadCounts(2)
+		assert.NoError(t, err)
+		assert.Equal(t, counts, result)
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		mockRepo := mockRepository{unreadError: errors.New("db error")}
+		s := NewService(mockRepo)
+		result, err := s.FetchUnreadCounts(2)
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+}
+
+func TestSearchMessagesService(t *testing.T) {
+	t.Run("results retrieved", func(t *testing.T) {
+		results := []entities.SearchResult{
+			{Message: entities.Message{ID: 3}, Snippet: "the <mark>link</mark>", Rank: 0.6},
+			{Message: entities.Message{ID: 1}, Snippet: "another <mark>link</mark>", Rank: 0.3},
+		}
+		var query entities.SearchQuery
+		mockRepo := mockRepository{results: results, searchQuery: &query}
+		s := NewService(mockRepo)
+		page, err := s.SearchMessages(entities.SearchQuery{Text: "  link ", UserID: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, results, page.Results)
+		assert.False(t, page.HasMore)
+		assert.Equal(t, "link", query.Text)
+		assert.Equal(t, DefaultSearchLimit+1, query.Limit)
+	})
+
+	t.Run("bodies are normalized", func(t *testing.T) {
+		results := []entities.SearchResult{{Message: entities.Message{ID: 3, Body: json.RawMessage(`{ "content": "a link",  "type": "text" }`)}}}
+		s := NewService(mockRepository{results: results})
+		page, err := s.SearchMessages(entities.SearchQuery{Text: "link"})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"type":"text","content":"a link"}`, string(page.Results[0].Message.Body))
+	})
+
+	t.Run("has more", func(t *testing.T) {
+		results := []entities.SearchResult{{Message: entities.Message{ID: 3}}, {Message: entities.Message{ID: 2}}, {Message: entities.Message{ID: 1}}}
+		mockRepo := mockRepository{results: results}
+		s := NewService(mockRepo)
+		page, err := s.SearchMessages(entities.SearchQuery{Text: "link", Limit: 2})
+		assert.NoError(t, err)
+		assert.Len(t, page.Results, 2)
+		assert.True(t, page.HasMore)
+	})
+
+	t.Run("empty text", func(t *testing.T) {
+		s := NewService(mockRepository{})
+		_, err := s.SearchMessages(entities.SearchQuery{Text: "   "})
+		assert.Equal(t, ErrInvalidSearch, err)
+	})
+
+	t.Run("text too long", func(t *testing.T) {
+		s := NewService(mockRepository{})
+		_, err := s.SearchMessages(entities.SearchQuery{Text: strings.Repeat("a", MaxSearchLength+1)})
+		assert.Equal(t, ErrInvalidSearch, err)
+	})
+
+	t.Run("search error", func(t *testing.T) {
+		mockRepo := mockRepository{searchError: errors.New("db error")}
+		s := NewService(mockRepo)
+		_, err := s.SearchMessages(entities.SearchQuery{Text: "link"})
+		assert.Error(t, err)
+		assert.NotEqual(t, ErrInvalidSearch, err)
+	})
+}
+
+func TestPinMessageService(t *testing.T) {
+	stored := entities.Message{ID: 3, ChannelID: 1, UserID: 5}
+
+	t.Run("pinned by admin", func(t *testing.T) {
+		mockRepo := mockRepository{role: entities.RoleAdmin, stored: stored, pinnedAt: "2025-07-19T10:30:00Z"}
+		s := NewService(mockRepo)
+		pin, err := s.PinMessage(1, 2, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.Pin{MessageID: 3, PinnedBy: 2, PinnedAt: "2025-07-19T10:30:00Z", Message: stored}, pin)
+	})
+
+	t.Run("not an admin", func(t *testing.T) {
+		mockRepo := mockRepository{role: entities.RoleMember, stored: stored}
+		s := NewService(mockRepo)
+		_, err := s.PinMessage(1, 2, 3)
+		assert.Equal(t, ErrPinNotAllowed, err)
+	})
+
+	t.Run("message of another channel", func(t *testing.T) {
+		mockRepo := mockRepository{role: entities.RoleAdmin, stored: entities.Message{ID: 3, ChannelID: 9}}
+		s := NewService(mockRepo)
+		_, err := s.PinMessage(1, 2, 3)
+		assert.Equal(t, ErrMessageNotFound, err)
+	})
+
+	t.Run("already pinned", func(t *testing.T) {
+		mockRepo := mockRepository{role: entities.RoleAdmin, stored: stored, pinError: sql.ErrNoRows, pinCount: 4}
+		s := NewService(mockRepo)
+		_, err := s.PinMessage(1, 2, 3)
+		assert.Equal(t, ErrAlreadyPinned, err)
+	})
+
+	t.Run("pin limit reached", func(t *testing.T) {
+		mockRepo := mockRepository{role: entities.RoleAdmin, stored: stored, pinError: sql.ErrNoRows, pinCount: MaxPinsPerChannel}
+		s := NewService(mockRepo)
+		_, err := s.PinMessage(1, 2, 3)
+		assert.Equal(t, ErrPinLimit, err)
+	})
+
+	t.Run("pin error", func(t *testing.T) {
+		mockRepo := mockRepository{role: entities.RoleAdmin, stored: stored, pinError: errors.New("db error")}
+		s := NewService(mockRepo)
+		_, err := s.PinMessage(1, 2, 3)
+		assert.Error(t, err)
+	})
+}
+
+func TestUnpinMessageService(t *testing.T) {
+	t.Run("unpinned by admin", func(t *testing.T) {
+		mockRepo := mockRepository{role: entities.RoleAdmin, unpinned: true}
+		s := NewService(mockRepo)
+		update, err := s.UnpinMessage(1, 2, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.PinUpdate{MessageID: 3, UserID: 2}, update)
+	})
+
+	t.Run("not an admin", func(t *testing.T) {
+		mockRepo := mockRepository{role: entities.RoleMember, unpinned: true}
+		s := NewService(mockRepo)
+		_, err := s.UnpinMessage(1, 2, 3)
+		assert.Equal(t, ErrPinNotAllowed, err)
+	})
+
+	t.Run("not pinned", func(t *testing.T) {
+		mockRepo := mockRepository{role: entities.RoleAdmin}
+		s := NewService(mockRepo)
+		_, err := s.UnpinMessage(1, 2, 3)
+		assert.Equal(t, ErrNotPinned, err)
+	})
+}
+
+func TestFetchPinsService(t *testing.T) {
+	t.Run("pins retrieved", func(t *testing.T) {
+		pins := []entities.Pin{{MessageID: 3, PinnedBy: 2}}
+		s := NewService(mockRepository{pins: pins})
+		result, err := s.FetchPins(1)
+		assert.NoError(t, err)
+		assert.Equal(t, pins, result)
+	})
+
+	t.Run("bodies are normalized", func(t *testing.T) {
+		pins := []entities.Pin{{MessageID: 3, Message: entities.Message{ID: 3, Body: json.RawMessage(`{ "content": "rules",  "type": "text" }`)}}}
+		s := NewService(mockRepository{pins: pins})
+		result, err := s.FetchPins(1)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"type":"text","content":"rules"}`, string(result[0].Message.Body))
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		s := NewService(mockRepository{pinsError: errors.New("db error")})
+		result, err := s.FetchPins(1)
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+}
+
+func TestVoteService(t *testing.T) {
+	poll := entities.Message{ID: 3, ChannelID: 1, Body: json.RawMessage(`{"type":"poll","question":"Which day?","options":["Mon","Tue","Wed"]}`)}
+	multiple := entities.Message{ID: 3, ChannelID: 1, Body: json.RawMessage(`{"type":"poll","question":"Which days?","options":["Mon","Tue","Wed"],"multiple_choice":true}`)}
+
+	t.Run("vote counted", func(t *testing.T) {
+		var votes []int
+		mockRepo := mockRepository{stored: poll, votes: &votes, voteCounts: map[int]int{1: 2, 2: 1}, voters: 3}
+		s := NewService(mockRepo)
+		tally, err := s.Vote(1, 2, 3, []int{1})
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1}, votes)
+		assert.Equal(t, entities.PollTally{MessageID: 3, Counts: []int{0, 2, 1}, Voters: 3}, tally)
+	})
+
+	t.Run("muted", func(t *testing.T) {
+		var votes []int
+		s := NewService(mockRepository{stored: poll, votes: &votes, muted: true})
+		_, err := s.Vote(1, 2, 3, []int{1})
+		assert.Equal(t, ErrMuted, err)
+		assert.Nil(t, votes)
+	})
+
+	t.Run("multiple choice", func(t *testing.T) {
+		var votes []int
+		mockRepo := mockRepository{stored: multiple, votes: &votes}
+		s := NewService(mockRepo)
+		_, err := s.Vote(1, 2, 3, []int{0, 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []int{0, 2}, votes)
+	})
+
+	t.Run("retracted", func(t *testing.T) {
+		votes := []int{1}
+		mockRepo := mockRepository{stored: poll, votes: &votes}
+		s := NewService(mockRepo)
+		_, err := s.Vote(1, 2, 3, []int{})
+		assert.NoError(t, err)
+		assert.Empty(t, votes)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		s := NewService(mockRepository{stored: poll})
+		for _, options := range [][]int{{0, 1}, {3}, {-1}} {
+			_, err := s.Vote(1, 2, 3, options)
+			assert.Equal(t, ErrInvalidVote, err)
+		}
+
+		s = NewService(mockRepository{stored: multiple})
+		_, err := s.Vote(1, 2, 3, []int{1, 1})
+		assert.Equal(t, ErrInvalidVote, err)
+	})
+
+	t.Run("closed poll", func(t *testing.T) {
+		closed := entities.Message{ID: 3, ChannelID: 1, Body: json.RawMessage(`{"type":"poll","question":"Which day?","options":["Mon","Tue"],"closes_at":"2020-01-01T00:00:00Z"}`)}
+		s := NewService(mockRepository{stored: closed})
+		_, err := s.Vote(1, 2, 3, []int{0})
+		assert.Equal(t, ErrPollClosed, err)
+	})
+
+	t.Run("not a poll", func(t *testing.T) {
+		text := entities.Message{ID: 3, ChannelID: 1, Body: json.RawMessage(`{"type":"text","content":"hi"}`)}
+		s := NewService(mockRepository{stored: text})
+		_, err := s.Vote(1, 2, 3, []int{0})
+		assert.Equal(t, ErrNotAPoll, err)
+	})
+
+	t.Run("vote error", func(t *testing.T) {
+		s := NewService(mockRepository{stored: poll, voteError: errors.New("db error")})
+		_, err := s.Vote(1, 2, 3, []int{0})
+		assert.Error(t, err)
+	})
+}
+
+func TestFetchPollResultsService(t *testing.T) {
+	poll := entities.Message{ID: 3, ChannelID: 1, Body: json.RawMessage(`{"type":"poll","question":"Which day?","options":["Mon","Tue"]}`)}
+	mockRepo := mockRepository{stored: poll, voteCounts: map[int]int{0: 1}, voters: 1, userVotes: []int{0}}
+	s := NewService(mockRepo)
+	results, err := s.FetchPollResults(1, 2, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, entities.PollResults{
+		PollTally: entities.PollTally{MessageID: 3, Counts: []int{1, 0}, Voters: 1},
+		Voted:     []int{0},
+	}, results)
+}
+
+func TestScreenMessageService(t *testing.T) {
+	t.Run("no filters", func(t *testing.T) {
+		body := []byte(`{"type":"text","content":"hello"}`)
+		s := NewService(mockRepository{})
+		decision, err := s.ScreenMessage(1, body)
+		assert.NoError(t, err)
+		assert.Equal(t, filter.Allow, decision.Action)
+		assert.Equal(t, json.RawMessage(body), decision.Body)
+	})
+
+	t.Run("redacted body keeps the encoding of its type", func(t *testing.T) {
+		filters := entities.FilterConfig{Words: &entities.WordFilterConfig{Words: []string{"darn"}, Action: "redact"}}
+		s := NewService(mockRepository{filters: filters})
+		decision, err := s.ScreenMessage(1, []byte(`{"type":"text","content":"darn"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, filter.Redact, decision.Action)
+		assert.Equal(t, `{"type":"text","content":"****"}`, string(decision.Body))
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		filters := entities.FilterConfig{InviteLinks: &entities.InviteFilterConfig{Action: "reject"}}
+		s := NewService(mockRepository{filters: filters})
+		decision, err := s.ScreenMessage(1, []byte(`{"type":"text","content":"discord.gg/abc"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, filter.Reject, decision.Action)
+		assert.Equal(t, "message contains invite links to other communities", decision.Reason())
+	})
+
+	t.Run("compiled once until forgotten", func(t *testing.T) {
+		fetches := 0
+		words := entities.FilterConfig{Words: &entities.WordFilterConfig{Words: []string{"darn"}, Action: "reject"}}
+		s := NewService(mockRepository{filters: words, filterFetches: &fetches}).(*service)
+		body := []byte(`{"type":"text","content":"darn"}`)
+
+		for i := 0; i < 2; i++ {
+			decision, err := s.ScreenMessage(1, body)
+			assert.NoError(t, err)
+			assert.Equal(t, filter.Reject, decision.Action)
+		}
+		assert.Equal(t, 1, fetches)
+
+		s.repo = mockRepository{filterFetches: &fetches}
+		s.ForgetFilters(1)
+		decision, err := s.ScreenMessage(1, body)
+		assert.NoError(t, err)
+		assert.Equal(t, filter.Allow, decision.Action)
+		assert.Equal(t, 2, fetches)
+	})
+
+	t.Run("fetched again once expired", func(t *testing.T) {
+		fetches := 0
+		s := NewService(mockRepository{filterFetches: &fetches}).(*service)
+		expired := time.Now().Add(-FilterCacheTTL)
+		s.chains[1] = cachedChain{loadedAt: expired}
+		s.chains[2] = cachedChain{loadedAt: expired}
+
+		_, err := s.ScreenMessage(1, []byte(`{"type":"text","content":"hi"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, fetches)
+		// Swept along the way
+		assert.NotContains(t, s.chains, 2)
+		assert.Contains(t, s.chains, 1)
+	})
+}
+
+func TestReviewFlagService(t *testing.T) {
+	pending := entities.MessageFlag{ID: 3, MessageID: 7, ChannelID: 1, Status: entities.FlagPending}
+
+	t.Run("approved", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleAdmin, flag: pending})
+		flag, err := s.ReviewFlag(1, 2, 3, "approve")
+		assert.NoError(t, err)
+		assert.Equal(t, entities.FlagApproved, flag.Status)
+		assert.Equal(t, 2, *flag.ReviewedBy)
+		assert.Nil(t, flag.Message)
+	})
+
+	t.Run("removed deletes the message", func(t *testing.T) {
+		deleted := entities.Message{ID: 7, ChannelID: 1}
+		s := NewService(mockRepository{role: entities.RoleAdmin, flag: pending, updated: deleted})
+		flag, err := s.ReviewFlag(1, 2, 3, "remove")
+		assert.NoError(t, err)
+		assert.Equal(t, entities.FlagRemoved, flag.Status)
+		assert.Equal(t, &deleted, flag.Message)
+	})
+
+	t.Run("member not allowed", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleMember, flag: pending})
+		_, err := s.ReviewFlag(1, 2, 3, "approve")
+		assert.Equal(t, ErrReviewNotAllowed, err)
+	})
+
+	t.Run("flag of another channel", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleAdmin, flag: pending})
+		_, err := s.ReviewFlag(5, 2, 3, "approve")
+		assert.Equal(t, ErrFlagNotFound, err)
+	})
+
+	t.Run("already reviewed", func(t *testing.T) {
+		reviewed := pending
+		reviewed.Status = entities.FlagApproved
+		s := NewService(mockRepository{role: entities.RoleAdmin, flag: reviewed})
+		_, err := s.ReviewFlag(1, 2, 3, "remove")
+		assert.Equal(t, ErrAlreadyReviewed, err)
+	})
+
+	t.Run("reviewed concurrently", func(t *testing.T) {
+		s := NewService(mockRepository{role: entities.RoleAdmin, flag: pending, resolveError: sql.ErrNoRows})
+		_, err := s.ReviewFlag(1, 2, 3, "approve")
+		assert.Equal(t, ErrAlreadyReviewed, err)
+	})
+}
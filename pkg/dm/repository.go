@@ -0,0 +1,186 @@
+package dm
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+)
+
+type Repository interface {
+	FetchUser(userId int) (entities.User, error)
+	IsBlocked(userId int, otherId int) (bool, error)
+	OpenDM(userId int, otherId int) (int, bool, error)
+	FetchDM(channelId int, userId int) (entities.DirectMessage, error)
+	FetchUserDMs(userId int) ([]entities.DirectMessage, error)
+	BlockUser(userId int, blockedId int) error
+	UnblockUser(userId int, blockedId int) error
+	FetchBlockedUsers(userId int) ([]entities.User, error)
+	Close() error
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (r *repository) Close() error {
+	return nil
+}
+
+// dmKey identifies the direct message of a pair of users, in either order
+func dmKey(userId int, otherId int) string {
+	return fmt.Sprintf("%d:%d", min(userId, otherId), max(userId, otherId))
+}
+
+func (r *repository) FetchUser(userId int) (entities.User, error) {
+	user := entities.User{}
+	err := r.db.QueryRow("SELECT id, name, avatar_url, created_at FROM users WHERE id = $1", userId).Scan(&user.ID, &user.Name, &user.AvatarURL, &user.CreatedAt)
+	return user, err
+}
+
+// IsBlocked reports whether either user blocked the other
+func (r *repository) IsBlocked(userId int, otherId int) (bool, error) {
+	var blocked bool
+	err := r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM user_blocks WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1))", userId, otherId).Scan(&blocked)
+	return blocked, err
+}
+
+// OpenDM returns the id of the direct message channel of two users, creating
+// it unless it exists. created reports whether it was created.
+func (r *repository) OpenDM(userId int, otherId int) (int, bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	key := dmKey(userId, otherId)
+	var channelId int
+	err = tx.QueryRow("INSERT INTO channels (name, created_by, dm_key) VALUES ('', $1, $2) ON CONFLICT (dm_key) DO NOTHING RETURNING id", userId, key).Scan(&channelId)
+	created := err == nil
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow("SELECT id FROM channels WHERE dm_key = $1", key).Scan(&channelId)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	if _, err := tx.Exec("INSERT INTO memberships (channel_id, user_id, role) VALUES ($1, $2, $4), ($1, $3, $4) ON CONFLICT (channel_id, user_id) DO NOTHING", channelId, userId, otherId, entities.RoleMember); err != nil {
+		return 0, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, err
+	}
+	return channelId, created, nil
+}
+
+// dmQuery selects the direct messages of $1 with the other user and the
+// latest top-level message, read by scanDM
+const dmQuery = `
+	SELECT c.id, c.created_at, u.id, u.name, u.avatar_url, u.created_at, m.id, m.user_id, m.body, m.created_at
+	FROM channels c
+	JOIN memberships me ON me.channel_id = c.id AND me.user_id = $1
+	JOIN memberships other ON other.channel_id = c.id AND other.user_id <> $1
+	JOIN users u ON u.id = other.user_id
+	LEFT JOIN LATERAL (
+		SELECT id, user_id, body, created_at FROM messages
+		WHERE channel_id = c.id AND parent_id IS NULL AND deleted_at IS NULL
+		ORDER BY id DESC
+		LIMIT 1
+	) m ON TRUE
+	WHERE c.dm_key IS NOT NULL`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDM(row rowScanner) (entities.DirectMessage, error) {
+	dm := entities.DirectMessage{}
+	var messageId *int
+	var authorId *int64
+	var body []byte
+	var createdAt *string
+	err := row.Scan(&dm.ChannelID, &dm.CreatedAt, &dm.User.ID, &dm.User.Name, &dm.User.AvatarURL, &dm.User.CreatedAt, &messageId, &authorId, &body, &createdAt)
+	if err != nil {
+		return entities.DirectMessage{}, err
+	}
+
+	if messageId != nil {
+		dm.LastMessage = &entities.Message{
+			ID:        *messageId,
+			UserID:    *authorId,
+			ChannelID: dm.ChannelID,
+			Body:      json.RawMessage(body),
+			CreatedAt: *createdAt,
+		}
+	}
+	return dm, nil
+}
+
+func (r *repository) FetchDM(channelId int, userId int) (entities.DirectMessage, error) {
+	return scanDM(r.db.QueryRow(dmQuery+" AND c.id = $2", userId, channelId))
+}
+
+// FetchUserDMs returns the direct messages of a user, latest activity first
+func (r *repository) FetchUserDMs(userId int) ([]entities.DirectMessage, error) {
+	rows, err := r.db.Query(dmQuery+" ORDER BY COALESCE(m.created_at, c.created_at) DESC, c.id DESC", userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dms := []entities.DirectMessage{}
+	for rows.Next() {
+		dm, err := scanDM(rows)
+		if err != nil {
+			return nil, err
+		}
+		dms = append(dms, dm)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return dms, nil
+}
+
+func (r *repository) BlockUser(userId int, blockedId int) error {
+	_, err := r.db.Exec("INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", userId, blockedId)
+	return err
+}
+
+func (r *repository) UnblockUser(userId int, blockedId int) error {
+	_, err := r.db.Exec("DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2", userId, blockedId)
+	return err
+}
+
+// FetchBlockedUsers returns the users blocked by userId, latest first
+func (r *repository) FetchBlockedUsers(userId int) ([]entities.User, error) {
+	rows, err := r.db.Query("SELECT u.id, u.name, u.avatar_url, u.created_at FROM user_blocks b JOIN users u ON u.id = b.blocked_id WHERE b.blocker_id = $1 ORDER BY b.created_at DESC", userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []entities.User{}
+	for rows.Next() {
+		user := entities.User{}
+		if err := rows.Scan(&user.ID, &user.Name, &user.AvatarURL, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
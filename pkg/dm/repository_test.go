@@ -0,0 +1,153 @@
+package dm
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+var dmRowColumns = []string{"id", "created_at", "user_id", "name", "avatar_url", "user_created_at", "message_id", "message_user_id", "body", "message_created_at"}
+
+func setupMockRepository(t *testing.T) (*sql.DB, sqlmock.Sqlmock, Repository) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	repo := NewRepository(db)
+	assert.NotNil(t, repo)
+	return db, mock, repo
+}
+
+func TestDMKey(t *testing.T) {
+	assert.Equal(t, "2:7", dmKey(2, 7))
+	assert.Equal(t, "2:7", dmKey(7, 2))
+}
+
+func TestIsBlocked(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM user_blocks WHERE \\(blocker_id = \\$1 AND blocked_id = \\$2\\) OR \\(blocker_id = \\$2 AND blocked_id = \\$1\\)\\)").
+		WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	blocked, err := repo.IsBlocked(2, 7)
+	assert.NoError(t, err)
+	assert.True(t, blocked)
+}
+
+func TestOpenDM(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	t.Run("created", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO channels \\(name, created_by, dm_key\\) VALUES \\('', \\$1, \\$2\\) ON CONFLICT \\(dm_key\\) DO NOTHING RETURNING id").
+			WithArgs(7, "2:7").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+		mock.ExpectExec("INSERT INTO memberships \\(channel_id, user_id, role\\) VALUES \\(\\$1, \\$2, \\$4\\), \\(\\$1, \\$3, \\$4\\) ON CONFLICT \\(channel_id, user_id\\) DO NOTHING").
+			WithArgs(10, 7, 2, entities.RoleMember).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		channelId, created, err := repo.OpenDM(7, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 10, channelId)
+		assert.True(t, created)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO channels").
+			WithArgs(2, "2:7").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT id FROM channels WHERE dm_key = \\$1").
+			WithArgs("2:7").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+		mock.ExpectExec("INSERT INTO memberships").
+			WithArgs(10, 2, 7, entities.RoleMember).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		channelId, created, err := repo.OpenDM(2, 7)
+		assert.NoError(t, err)
+		assert.Equal(t, 10, channelId)
+		assert.False(t, created)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("error rolls back", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO channels").
+			WillReturnError(errors.New("database error"))
+		mock.ExpectRollback()
+
+		_, _, err := repo.OpenDM(2, 7)
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestFetchUserDMs(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT c.id, c.created_at, u.id, u.name, u.avatar_url, u.created_at, m.id, m.user_id, m.body, m.created_at FROM channels c (.+) WHERE c.dm_key IS NOT NULL ORDER BY COALESCE\\(m.created_at, c.created_at\\) DESC").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(dmRowColumns).
+			AddRow(10, "2025-07-19T10:30:00Z", 7, "Jane", "", "2025-07-01T10:30:00Z", 55, 7, []byte(`{"type":"text","content":"hi"}`), "2025-07-19T10:31:00Z").
+			AddRow(11, "2025-07-18T10:30:00Z", 8, "John", "", "2025-07-01T10:30:00Z", nil, nil, nil, nil))
+
+	dms, err := repo.FetchUserDMs(2)
+	assert.NoError(t, err)
+	assert.Len(t, dms, 2)
+	assert.Equal(t, int64(7), dms[0].User.ID)
+	assert.Equal(t, 55, dms[0].LastMessage.ID)
+	assert.Equal(t, 10, dms[0].LastMessage.ChannelID)
+	assert.JSONEq(t, `{"type":"text","content":"hi"}`, string(dms[0].LastMessage.Body))
+	assert.Nil(t, dms[1].LastMessage)
+}
+
+func TestFetchDM(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM channels c (.+) WHERE c.dm_key IS NOT NULL AND c.id = \\$2").
+		WithArgs(2, 10).
+		WillReturnRows(sqlmock.NewRows(dmRowColumns))
+
+	_, err := repo.FetchDM(10, 2)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestBlockUser(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO user_blocks \\(blocker_id, blocked_id\\) VALUES \\(\\$1, \\$2\\) ON CONFLICT DO NOTHING").
+		WithArgs(2, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.BlockUser(2, 7))
+
+	mock.ExpectExec("DELETE FROM user_blocks WHERE blocker_id = \\$1 AND blocked_id = \\$2").
+		WithArgs(2, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.UnblockUser(2, 7))
+}
+
+func TestFetchBlockedUsers(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT u.id, u.name, u.avatar_url, u.created_at FROM user_blocks b JOIN users u ON u.id = b.blocked_id WHERE b.blocker_id = \\$1").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "avatar_url", "created_at"}).AddRow(7, "Jane", "", "2025-07-01T10:30:00Z"))
+
+	users, err := repo.FetchBlockedUsers(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []entities.User{{ID: 7, Name: "Jane", CreatedAt: "2025-07-01T10:30:00Z"}}, users)
+}
@@ -0,0 +1,130 @@
+package dm
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/aramceballos/chat-group-server/pkg/schema"
+)
+
+type Service interface {
+	OpenDM(userId int, otherId int) (entities.DirectMessage, bool, error)
+	FetchDMs(userId int) ([]entities.DirectMessage, error)
+	BlockUser(userId int, blockedId int) error
+	UnblockUser(userId int, blockedId int) error
+	FetchBlockedUsers(userId int) ([]entities.User, error)
+}
+
+var (
+	ErrInvalidRecipient = errors.New("you cannot message yourself")
+	ErrInvalidBlock     = errors.New("you cannot block yourself")
+	ErrUserNotFound     = errors.New("user not found")
+	ErrBlocked          = errors.New("messages between you and this user are blocked")
+)
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{
+		repo,
+	}
+}
+
+func (s *service) checkUser(userId int) error {
+	_, err := s.repo.FetchUser(userId)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		log.Printf("[dm service error] error fetching user: %s", err.Error())
+		return fmt.Errorf("error fetching user")
+	}
+	return nil
+}
+
+// OpenDM returns the direct message of userId with otherId, which is created
+// on first use. created reports whether it was. Users who blocked one another
+// cannot start one.
+func (s *service) OpenDM(userId int, otherId int) (entities.DirectMessage, bool, error) {
+	if userId == otherId {
+		return entities.DirectMessage{}, false, ErrInvalidRecipient
+	}
+	if err := s.checkUser(otherId); err != nil {
+		return entities.DirectMessage{}, false, err
+	}
+
+	blocked, err := s.repo.IsBlocked(userId, otherId)
+	if err != nil {
+		log.Printf("[dm service error] error checking blocks: %s", err.Error())
+		return entities.DirectMessage{}, false, fmt.Errorf("error opening direct message")
+	}
+	if blocked {
+		return entities.DirectMessage{}, false, ErrBlocked
+	}
+
+	channelId, created, err := s.repo.OpenDM(userId, otherId)
+	if err != nil {
+		log.Printf("[dm service error] error opening direct message: %s", err.Error())
+		return entities.DirectMessage{}, false, fmt.Errorf("error opening direct message")
+	}
+
+	dm, err := s.repo.FetchDM(channelId, userId)
+	if err != nil {
+		log.Printf("[dm service error] error fetching direct message: %s", err.Error())
+		return entities.DirectMessage{}, false, fmt.Errorf("error opening direct message")
+	}
+	return dm, created, nil
+}
+
+func (s *service) FetchDMs(userId int) ([]entities.DirectMessage, error) {
+	dms, err := s.repo.FetchUserDMs(userId)
+	if err != nil {
+		log.Printf("[dm service error] error fetching direct messages: %s", err.Error())
+		return nil, fmt.Errorf("error fetching direct messages")
+	}
+	for i := range dms {
+		if dms[i].LastMessage != nil {
+			dms[i].LastMessage.Body = schema.Normalize(dms[i].LastMessage.Body)
+		}
+	}
+	return dms, nil
+}
+
+// BlockUser stops blockedId and userId from messaging each other directly.
+// Blocking a user twice is not an error.
+func (s *service) BlockUser(userId int, blockedId int) error {
+	if userId == blockedId {
+		return ErrInvalidBlock
+	}
+	if err := s.checkUser(blockedId); err != nil {
+		return err
+	}
+
+	if err := s.repo.BlockUser(userId, blockedId); err != nil {
+		log.Printf("[dm service error] error blocking user: %s", err.Error())
+		return fmt.Errorf("error blocking user")
+	}
+	return nil
+}
+
+func (s *service) UnblockUser(userId int, blockedId int) error {
+	if err := s.repo.UnblockUser(userId, blockedId); err != nil {
+		log.Printf("[dm service error] error unblocking user: %s", err.Error())
+		return fmt.Errorf("error unblocking user")
+	}
+	return nil
+}
+
+func (s *service) FetchBlockedUsers(userId int) ([]entities.User, error) {
+	users, err := s.repo.FetchBlockedUsers(userId)
+	if err != nil {
+		log.Printf("[dm service error] error fetching blocked users: %s", err.Error())
+		return nil, fmt.Errorf("error fetching blocked users")
+	}
+	return users, nil
+}
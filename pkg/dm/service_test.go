@@ -0,0 +1,133 @@
+package dm
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockRepository struct {
+	userError  error
+	blocked    bool
+	blockError error
+	channelId  int
+	created    bool
+	openError  error
+	dm         entities.DirectMessage
+	dmError    error
+	dms        []entities.DirectMessage
+	users      []entities.User
+	writeError error
+}
+
+func (mr mockRepository) FetchUser(userId int) (entities.User, error) {
+	return entities.User{ID: int64(userId)}, mr.userError
+}
+
+func (mr mockRepository) IsBlocked(userId int, otherId int) (bool, error) {
+	return mr.blocked, mr.blockError
+}
+
+func (mr mockRepository) OpenDM(userId int, otherId int) (int, bool, error) {
+	return mr.channelId, mr.created, mr.openError
+}
+
+func (mr mockRepository) FetchDM(channelId int, userId int) (entities.DirectMessage, error) {
+	return mr.dm, mr.dmError
+}
+
+func (mr mockRepository) FetchUserDMs(userId int) ([]entities.DirectMessage, error) {
+	return mr.dms, mr.dmError
+}
+
+func (mr mockRepository) BlockUser(userId int, blockedId int) error {
+	return mr.writeError
+}
+
+func (mr mockRepository) UnblockUser(userId int, blockedId int) error {
+	return mr.writeError
+}
+
+func (mr mockRepository) FetchBlockedUsers(userId int) ([]entities.User, error) {
+	return mr.users, mr.writeError
+}
+
+func (mr mockRepository) Close() error {
+	return nil
+}
+
+func TestOpenDMService(t *testing.T) {
+	conversation := entities.DirectMessage{ChannelID: 10, User: entities.User{ID: 7}}
+
+	t.Run("created", func(t *testing.T) {
+		s := NewService(mockRepository{channelId: 10, created: true, dm: conversation})
+		result, created, err := s.OpenDM(2, 7)
+		assert.NoError(t, err)
+		assert.True(t, created)
+		assert.Equal(t, conversation, result)
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		s := NewService(mockRepository{channelId: 10, dm: conversation})
+		_, created, err := s.OpenDM(2, 7)
+		assert.NoError(t, err)
+		assert.False(t, created)
+	})
+
+	t.Run("with yourself", func(t *testing.T) {
+		s := NewService(mockRepository{})
+		_, _, err := s.OpenDM(2, 2)
+		assert.Equal(t, ErrInvalidRecipient, err)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		s := NewService(mockRepository{userError: sql.ErrNoRows})
+		_, _, err := s.OpenDM(2, 7)
+		assert.Equal(t, ErrUserNotFound, err)
+	})
+
+	t.Run("blocked", func(t *testing.T) {
+		s := NewService(mockRepository{blocked: true})
+		_, _, err := s.OpenDM(2, 7)
+		assert.Equal(t, ErrBlocked, err)
+	})
+
+	t.Run("open error", func(t *testing.T) {
+		s := NewService(mockRepository{openError: errors.New("db error")})
+		_, _, err := s.OpenDM(2, 7)
+		assert.Error(t, err)
+	})
+}
+
+func TestFetchDMsService(t *testing.T) {
+	dms := []entities.DirectMessage{{ChannelID: 10}}
+	s := NewService(mockRepository{dms: dms})
+	result, err := s.FetchDMs(2)
+	assert.NoError(t, err)
+	assert.Equal(t, dms, result)
+}
+
+func TestBlockUserService(t *testing.T) {
+	t.Run("blocked", func(t *testing.T) {
+		s := NewService(mockRepository{})
+		assert.NoError(t, s.BlockUser(2, 7))
+	})
+
+	t.Run("yourself", func(t *testing.T) {
+		s := NewService(mockRepository{})
+		assert.Equal(t, ErrInvalidBlock, s.BlockUser(2, 2))
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		s := NewService(mockRepository{userError: sql.ErrNoRows})
+		assert.Equal(t, ErrUserNotFound, s.BlockUser(2, 7))
+	})
+
+	t.Run("block error", func(t *testing.T) {
+		s := NewService(mockRepository{writeError: errors.New("db error")})
+		assert.Error(t, s.BlockUser(2, 7))
+	})
+}
@@ -0,0 +1,23 @@
+package entities
+
+type Channel struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	CreatedBy   int     `json:"created_by"`
+	CreatedAt   string  `json:"created_at"`
+	ArchivedAt  *string `json:"archived_at,omitempty"`
+	// Direct message channels are only reached through the dms endpoints
+	Direct bool `json:"direct,omitempty"`
+}
+
+type CreateChannelInput struct {
+	Name        string `json:"name" validate:"required,max=80" error:"name is required"`
+	Description string `json:"description" validate:"max=500"`
+}
+
+// UpdateChannelInput changes the fields that are set
+type UpdateChannelInput struct {
+	Name        *string `json:"name" validate:"omitempty,min=1,max=80"`
+	Description *string `json:"description" validate:"omitempty,max=500"`
+}
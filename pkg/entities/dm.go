@@ -0,0 +1,15 @@
+package entities
+
+// DirectMessage is a conversation between the user it is fetched for and
+// User. Its messages are those of the channel, sent and received like in any
+// other channel.
+type DirectMessage struct {
+	ChannelID   int      `json:"channel_id"`
+	User        User     `json:"user"`
+	LastMessage *Message `json:"last_message"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+type OpenDMInput struct {
+	UserID int `json:"user_id" validate:"required,min=1" error:"user_id is required"`
+}
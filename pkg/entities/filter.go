@@ -0,0 +1,61 @@
+package entities
+
+// FilterConfig sets the filters the messages of a channel go through before
+// they are stored. Filters left out are off.
+type FilterConfig struct {
+	Words         *WordFilterConfig   `json:"words,omitempty"`
+	Links         *LinkFilterConfig   `json:"links,omitempty"`
+	RepeatedChars *RepeatFilterConfig `json:"repeated_chars,omitempty"`
+	InviteLinks   *InviteFilterConfig `json:"invite_links,omitempty"`
+}
+
+// WordFilterConfig matches whole words, case insensitively, and regular
+// expressions
+type WordFilterConfig struct {
+	Words    []string `json:"words" validate:"max=500,dive,min=1,max=100"`
+	Patterns []string `json:"patterns" validate:"max=50,dive,min=1,max=200"`
+	Action   string   `json:"action" validate:"required,oneof=redact reject flag"`
+}
+
+// LinkFilterConfig matches links to domains that are not in Allow, or to one
+// of their subdomains
+type LinkFilterConfig struct {
+	Allow  []string `json:"allow" validate:"max=200,dive,fqdn"`
+	Action string   `json:"action" validate:"required,oneof=redact reject flag"`
+}
+
+// RepeatFilterConfig matches a character repeated more than Max times in a row
+type RepeatFilterConfig struct {
+	Max    int    `json:"max" validate:"min=2,max=100"`
+	Action string `json:"action" validate:"required,oneof=redact reject flag"`
+}
+
+// InviteFilterConfig matches invite links to other chat communities
+type InviteFilterConfig struct {
+	Action string `json:"action" validate:"required,oneof=redact reject flag"`
+}
+
+const (
+	FlagPending  = "pending"
+	FlagApproved = "approved"
+	FlagRemoved  = "removed"
+)
+
+// MessageFlag queues a message the filters of its channel flagged for review
+// by the channel admins
+type MessageFlag struct {
+	ID         int      `json:"id"`
+	MessageID  int      `json:"message_id"`
+	ChannelID  int      `json:"channel_id"`
+	Reasons    []string `json:"reasons"`
+	Status     string   `json:"status"`
+	ReviewedBy *int     `json:"reviewed_by,omitempty"`
+	ReviewedAt *string  `json:"reviewed_at,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+	Message    *Message `json:"message,omitempty"`
+}
+
+// ReviewFlagInput resolves a flag, keeping its message or deleting it
+type ReviewFlagInput struct {
+	Action string `json:"action" validate:"required,oneof=approve remove"`
+}
@@ -0,0 +1,18 @@
+package entities
+
+type Invite struct {
+	Code      string  `json:"code"`
+	ChannelID int     `json:"channel_id"`
+	CreatedBy int     `json:"created_by"`
+	MaxUses   *int    `json:"max_uses"`
+	Uses      int     `json:"uses"`
+	ExpiresAt *string `json:"expires_at"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// CreateInviteInput limits an invite to MaxUses redemptions and to
+// ExpiresIn seconds. Either is unlimited when unset.
+type CreateInviteInput struct {
+	MaxUses   *int `json:"max_uses" validate:"omitempty,min=1"`
+	ExpiresIn *int `json:"expires_in" validate:"omitempty,min=60,max=2592000"`
+}
@@ -1,8 +1,81 @@
 package entities
 
+import "errors"
+
+// ErrNotMember is returned by every service acting on a channel the user is
+// not a member of
+var ErrNotMember = errors.New("you are not a member of this channel")
+
+const (
+	RoleReadOnly = "read_only"
+	RoleMember   = "member"
+	RoleAdmin    = "admin"
+	RoleOwner    = "owner"
+)
+
+// Position of each role in the channel hierarchy, highest last
+var roleRanks = map[string]int{
+	RoleReadOnly: 1,
+	RoleMember:   2,
+	RoleAdmin:    3,
+	RoleOwner:    4,
+}
+
+// CanManage reports whether a member with role may manage the channel and
+// the messages of others
+func CanManage(role string) bool {
+	return role == RoleAdmin || role == RoleOwner
+}
+
+// CanPost reports whether a member with role may send messages
+func CanPost(role string) bool {
+	return role != RoleReadOnly
+}
+
+// Outranks reports whether role is above other in the channel hierarchy
+func Outranks(role string, other string) bool {
+	return roleRanks[role] > roleRanks[other]
+}
+
 type Membership struct {
-	ID        int64 `json:"id"`
-	UserID    int64 `json:"user_id"`
-	ChannelID int   `json:"channel_id"`
-	User      User  `json:"-"`
+	ID                int64  `json:"id"`
+	UserID            int64  `json:"user_id"`
+	ChannelID         int    `json:"channel_id"`
+	Role              string `json:"role"`
+	LastReadMessageID int    `json:"last_read_message_id"`
+	User              User   `json:"-"`
+}
+
+// Member is a user of a channel together with their role in it
+type Member struct {
+	User User   `json:"user"`
+	Role string `json:"role"`
+}
+
+// AddMemberInput adds a user to a channel, as a member unless role is set.
+// The owner role is never granted.
+type AddMemberInput struct {
+	UserID int    `json:"user_id" validate:"required,min=1" error:"user_id is required"`
+	Role   string `json:"role" validate:"omitempty,oneof=admin member read_only"`
+}
+
+type UpdateMemberInput struct {
+	Role string `json:"role" validate:"required,oneof=admin member read_only" error:"role is required"`
+}
+
+// ReadReceipt is broadcast when a member's read position moves forward
+type ReadReceipt struct {
+	UserID    int `json:"user_id"`
+	MessageID int `json:"message_id"`
+}
+
+type ReadInput struct {
+	MessageID int `json:"message_id" validate:"required,min=1" error:"message_id is required"`
+}
+
+type UnreadCount struct {
+	ChannelID         int `json:"channel_id"`
+	LastReadMessageID int `json:"last_read_message_id"`
+	Unread            int `json:"unread"`
+	Mentions          int `json:"mentions"`
 }
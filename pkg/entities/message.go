@@ -3,10 +3,35 @@ package entities
 import "encoding/json"
 
 type Message struct {
-	ID        int             `json:"id"`
-	UserID    int64           `json:"user_id"`
-	ChannelID int             `json:"channel_id"`
-	Body      json.RawMessage `json:"body"`
-	CreatedAt string          `json:"created_at"`
-	User      User            `json:"user,omitempty"`
+	ID          int             `json:"id"`
+	UserID      int64           `json:"user_id"`
+	ChannelID   int             `json:"channel_id"`
+	ParentID    *int            `json:"parent_id,omitempty"`
+	ClientMsgID string          `json:"client_msg_id,omitempty"`
+	Body        json.RawMessage `json:"body"`
+	CreatedAt   string          `json:"created_at"`
+	EditedAt    *string         `json:"edited_at,omitempty"`
+	DeletedAt   *string         `json:"deleted_at,omitempty"`
+	ReplyCount  int             `json:"reply_count"`
+	LastReplyAt *string         `json:"last_reply_at,omitempty"`
+	Reactions   []Reaction      `json:"reactions,omitempty"`
+	User        User            `json:"user,omitempty"`
+}
+
+// MessageQuery describes a page of channel history. Before and After are
+// message id cursors; only one of them may be set. ViewerID is the user the
+// page is fetched for. ParentID selects the replies of a thread, otherwise
+// only top-level messages are returned unless IncludeReplies is set.
+type MessageQuery struct {
+	Before         int
+	After          int
+	Limit          int
+	ViewerID       int
+	ParentID       int
+	IncludeReplies bool
+}
+
+type MessagePage struct {
+	Messages []Message `json:"messages"`
+	HasMore  bool      `json:"has_more"`
 }
@@ -0,0 +1,28 @@
+package entities
+
+const (
+	ModerationKick = "kick"
+	ModerationMute = "mute"
+	ModerationBan  = "ban"
+)
+
+// Moderation is an action taken against a member of a channel by ActorID
+type Moderation struct {
+	ID        int     `json:"id"`
+	ChannelID int     `json:"channel_id"`
+	UserID    int     `json:"user_id"`
+	Action    string  `json:"action"`
+	Reason    string  `json:"reason"`
+	ActorID   int     `json:"actor_id"`
+	ExpiresAt *string `json:"expires_at,omitempty"`
+	CreatedAt string  `json:"created_at"`
+	RevokedAt *string `json:"revoked_at,omitempty"`
+	RevokedBy *int    `json:"revoked_by,omitempty"`
+}
+
+// ModerationInput explains a moderation. Duration, in seconds, is required
+// for mutes and ignored otherwise.
+type ModerationInput struct {
+	Reason   string `json:"reason" validate:"max=500"`
+	Duration int    `json:"duration" validate:"omitempty,min=60,max=2592000"`
+}
@@ -0,0 +1,16 @@
+package entities
+
+// Pin is a message a channel admin pinned to its channel
+type Pin struct {
+	MessageID int     `json:"message_id"`
+	PinnedBy  int     `json:"pinned_by"`
+	PinnedAt  string  `json:"pinned_at"`
+	Message   Message `json:"message"`
+}
+
+// PinUpdate is broadcast when a message is unpinned, UserID being the admin
+// who unpinned it
+type PinUpdate struct {
+	MessageID int `json:"message_id"`
+	UserID    int `json:"user_id"`
+}
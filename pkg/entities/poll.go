@@ -0,0 +1,16 @@
+package entities
+
+// PollTally counts the votes of each option of a poll, in option order. It is
+// broadcast whenever the votes of a poll change.
+type PollTally struct {
+	MessageID int   `json:"message_id"`
+	Counts    []int `json:"counts"`
+	Voters    int   `json:"voters"`
+	Closed    bool  `json:"closed"`
+}
+
+// PollResults is the tally of a poll with the options the viewer voted for
+type PollResults struct {
+	PollTally
+	Voted []int `json:"voted"`
+}
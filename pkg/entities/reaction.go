@@ -0,0 +1,16 @@
+package entities
+
+// Reaction is the aggregated count of one emoji on a message
+type Reaction struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
+
+// ReactionUpdate is broadcast when a user adds or removes a reaction
+type ReactionUpdate struct {
+	MessageID int    `json:"message_id"`
+	UserID    int    `json:"user_id"`
+	Emoji     string `json:"emoji"`
+	Count     int    `json:"count"`
+}
@@ -0,0 +1,62 @@
+package entities
+
+const (
+	ReportOpen     = "open"
+	ReportTriaged  = "triaged"
+	ReportResolved = "resolved"
+)
+
+const (
+	ResolutionDismiss    = "dismiss"
+	ResolutionDelete     = "delete_message"
+	ResolutionMuteAuthor = "mute_author"
+)
+
+// Report is a message reported to the admins of its channel. Only they see
+// who reported it.
+type Report struct {
+	ID             int      `json:"id"`
+	MessageID      int      `json:"message_id"`
+	ChannelID      int      `json:"channel_id"`
+	ReporterID     int      `json:"reporter_id"`
+	Category       string   `json:"category"`
+	Details        string   `json:"details,omitempty"`
+	Status         string   `json:"status"`
+	TriagedBy      *int     `json:"triaged_by,omitempty"`
+	TriagedAt      *string  `json:"triaged_at,omitempty"`
+	TriageNote     string   `json:"triage_note,omitempty"`
+	Resolution     *string  `json:"resolution,omitempty"`
+	ResolutionNote string   `json:"resolution_note,omitempty"`
+	ResolvedBy     *int     `json:"resolved_by,omitempty"`
+	ResolvedAt     *string  `json:"resolved_at,omitempty"`
+	CreatedAt      string   `json:"created_at"`
+	Message        *Message `json:"message,omitempty"`
+}
+
+type ReportInput struct {
+	Category string `json:"category" validate:"required,oneof=spam harassment hate_speech violence sexual_content misinformation other"`
+	Details  string `json:"details" validate:"max=1000"`
+}
+
+// TriageReportInput takes a report up for review
+type TriageReportInput struct {
+	Note string `json:"note" validate:"max=1000"`
+}
+
+// ResolveReportInput closes a report. Duration, in seconds, is required to
+// mute the author and ignored otherwise.
+type ResolveReportInput struct {
+	Resolution string `json:"resolution" validate:"required,oneof=dismiss delete_message mute_author"`
+	Note       string `json:"note" validate:"max=1000"`
+	Duration   int    `json:"duration" validate:"omitempty,min=60,max=2592000"`
+}
+
+// ReportResolution is a resolved report with the message it deleted or the
+// mute it gave its author, if any. OtherReports are the other reports of the
+// message, resolved along with it.
+type ReportResolution struct {
+	Report       Report      `json:"report"`
+	OtherReports []Report    `json:"other_reports,omitempty"`
+	Message      *Message    `json:"message,omitempty"`
+	Moderation   *Moderation `json:"moderation,omitempty"`
+}
@@ -0,0 +1,30 @@
+package entities
+
+import "time"
+
+// SearchQuery is a full-text search over the channels UserID is a member of.
+// ChannelID and AuthorID narrow it down when set, and so do From and To when
+// they are not zero.
+type SearchQuery struct {
+	Text      string
+	UserID    int
+	ChannelID int
+	AuthorID  int
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
+
+// SearchResult is a matching message with its matches wrapped in <mark> tags
+// in Snippet
+type SearchResult struct {
+	Message Message `json:"message"`
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
+
+type SearchPage struct {
+	Results []SearchResult `json:"results"`
+	HasMore bool           `json:"has_more"`
+}
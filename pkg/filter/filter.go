@@ -0,0 +1,180 @@
+// Package filter screens message bodies before they are stored. A channel
+// configures a chain of filters, each of which looks at the text and links of
+// a body and may let it through, redact what it matched, reject the message or
+// flag it for review.
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aramceballos/chat-group-server/pkg/schema"
+)
+
+// Action is what a filter decides for a message. Actions are ordered by
+// severity, the most severe one of a chain decides.
+type Action int
+
+const (
+	Allow Action = iota
+	Redact
+	Flag
+	Reject
+)
+
+var actionNames = []string{"allow", "redact", "flag", "reject"}
+
+func (a Action) String() string {
+	return actionNames[a]
+}
+
+// ParseAction returns the action named name, which defaults to Allow
+func ParseAction(name string) Action {
+	if i := slices.Index(actionNames, name); i >= 0 {
+		return Action(i)
+	}
+	return Allow
+}
+
+// Outcome is what a filter decided for one text. Text is the text to keep
+// when the action is Redact.
+type Outcome struct {
+	Action Action
+	Reason string
+	Text   string
+}
+
+// Filter checks one text of a message body
+type Filter interface {
+	Check(text string) Outcome
+}
+
+// Decision is what a chain decided for a message body. Body has the
+// redactions of the chain applied, even when it flagged the message.
+type Decision struct {
+	Action  Action
+	Body    json.RawMessage
+	Reasons []string
+}
+
+// Reason explains the decision
+func (d Decision) Reason() string {
+	return strings.Join(d.Reasons, "; ")
+}
+
+// Chain runs filters one after the other over the screened fields of a body.
+// Texts redacted by a filter are passed on redacted to the next one.
+type Chain []Filter
+
+// linkChecker is implemented by the filters that look for links, the only
+// ones run over the link fields of a body
+type linkChecker interface {
+	checksLinks() bool
+}
+
+// Run screens a JSON body. It stops at the first rejection.
+func (c Chain) Run(body json.RawMessage) (Decision, error) {
+	decision := Decision{Body: body}
+	if len(c) == 0 {
+		return decision, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value map[string]interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return Decision{}, err
+	}
+
+	fields := schema.ScreenedFields(body)
+	redacted := false
+	for _, key := range fields.Text {
+		if text, ok := value[key]; ok {
+			value[key] = c.screen(text, &decision, &redacted)
+		}
+	}
+	for _, key := range fields.Links {
+		if link, ok := value[key].(string); ok {
+			c.screenLink(link, &decision)
+		}
+	}
+
+	if redacted && decision.Action != Reject {
+		var err error
+		if decision.Body, err = json.Marshal(value); err != nil {
+			return Decision{}, err
+		}
+	}
+	return decision, nil
+}
+
+// screen runs the chain over a text field, a string or a list of them
+func (c Chain) screen(value interface{}, decision *Decision, redacted *bool) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = c.screen(v[i], decision, redacted)
+		}
+	case string:
+		return c.screenText(v, decision, redacted)
+	}
+	return value
+}
+
+func (c Chain) screenText(text string, decision *Decision, redacted *bool) string {
+	for _, filter := range c {
+		if decision.Action == Reject {
+			return text
+		}
+
+		outcome := filter.Check(text)
+		if outcome.Action == Allow {
+			continue
+		}
+		if outcome.Action == Redact {
+			text = outcome.Text
+			*redacted = true
+		}
+		decision.add(outcome.Action, outcome.Reason)
+	}
+	return text
+}
+
+// screenLink runs the filters looking for links over a link field. Masking
+// it would break the body, so a redaction rejects the message instead.
+func (c Chain) screenLink(link string, decision *Decision) {
+	for _, filter := range c {
+		if decision.Action == Reject {
+			return
+		}
+		if checker, ok := filter.(linkChecker); !ok || !checker.checksLinks() {
+			continue
+		}
+
+		outcome := filter.Check(link)
+		if outcome.Action == Allow {
+			continue
+		}
+		action := outcome.Action
+		if action == Redact {
+			action = Reject
+		}
+		decision.add(action, outcome.Reason)
+	}
+}
+
+// add records the outcome of a filter, the most severe action deciding
+func (d *Decision) add(action Action, reason string) {
+	d.Action = max(d.Action, action)
+	if !slices.Contains(d.Reasons, reason) {
+		d.Reasons = append(d.Reasons, reason)
+	}
+}
+
+// mask hides a match, keeping its length
+func mask(match string) string {
+	return strings.Repeat("*", utf8.RuneCountInString(match))
+}
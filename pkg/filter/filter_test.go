@@ -0,0 +1,147 @@
+package filter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func run(t *testing.T, config entities.FilterConfig, body string) Decision {
+	t.Helper()
+	chain, err := Build(config)
+	require.NoError(t, err)
+	decision, err := chain.Run(json.RawMessage(body))
+	require.NoError(t, err)
+	return decision
+}
+
+func TestRun(t *testing.T) {
+	t.Run("no filters", func(t *testing.T) {
+		body := `{"type":"text","content":"hello"}`
+		decision := run(t, entities.FilterConfig{}, body)
+		assert.Equal(t, Allow, decision.Action)
+		assert.JSONEq(t, body, string(decision.Body))
+	})
+
+	t.Run("clean message", func(t *testing.T) {
+		config := entities.FilterConfig{Words: &entities.WordFilterConfig{Words: []string{"darn"}, Action: "reject"}}
+		decision := run(t, config, `{"type":"text","content":"hello there"}`)
+		assert.Equal(t, Allow, decision.Action)
+		assert.Empty(t, decision.Reasons)
+	})
+
+	t.Run("redacts whole words case insensitively", func(t *testing.T) {
+		config := entities.FilterConfig{Words: &entities.WordFilterConfig{Words: []string{"darn"}, Action: "redact"}}
+		decision := run(t, config, `{"type":"text","content":"Darn it, darnation"}`)
+		assert.Equal(t, Redact, decision.Action)
+		assert.JSONEq(t, `{"type":"text","content":"**** it, darnation"}`, string(decision.Body))
+	})
+
+	t.Run("rejects patterns", func(t *testing.T) {
+		config := entities.FilterConfig{Words: &entities.WordFilterConfig{Patterns: []string{`buy\s+now`}, Action: "reject"}}
+		decision := run(t, config, `{"type":"poll","question":"Deal?","options":["yes","BUY   now"]}`)
+		assert.Equal(t, Reject, decision.Action)
+		assert.Equal(t, "message contains blocked words", decision.Reason())
+	})
+
+	t.Run("skips fields that are not text", func(t *testing.T) {
+		config := entities.FilterConfig{Words: &entities.WordFilterConfig{Words: []string{"go"}, Action: "reject"}}
+		decision := run(t, config, `{"type":"code","language":"go","content":"fmt.Println()"}`)
+		assert.Equal(t, Allow, decision.Action)
+	})
+
+	t.Run("does not redact links of other body types", func(t *testing.T) {
+		config := entities.FilterConfig{
+			Words:         &entities.WordFilterConfig{Words: []string{"darn"}, Action: "redact"},
+			RepeatedChars: &entities.RepeatFilterConfig{Max: 2, Action: "redact"},
+		}
+		decision := run(t, config, `{"type":"link","url":"https://www.example.com/darn","title":"darn","image_url":"https://www.example.com/darn.png"}`)
+		assert.Equal(t, Redact, decision.Action)
+		assert.JSONEq(t, `{"type":"link","url":"https://www.example.com/darn","title":"****","image_url":"https://www.example.com/darn.png"}`, string(decision.Body))
+	})
+
+	t.Run("rejects links it would redact", func(t *testing.T) {
+		config := entities.FilterConfig{Links: &entities.LinkFilterConfig{Allow: []string{"example.com"}, Action: "redact"}}
+		decision := run(t, config, `{"type":"image","url":"https://evil.io/a.png","width":1,"height":1}`)
+		assert.Equal(t, Reject, decision.Action)
+		assert.Equal(t, "message contains links to domains that are not allowed", decision.Reason())
+	})
+
+	t.Run("flags invite links of link bodies", func(t *testing.T) {
+		config := entities.FilterConfig{InviteLinks: &entities.InviteFilterConfig{Action: "flag"}}
+		body := `{"type":"link","url":"https://discord.gg/abc"}`
+		decision := run(t, config, body)
+		assert.Equal(t, Flag, decision.Action)
+		assert.JSONEq(t, body, string(decision.Body))
+	})
+
+	t.Run("allows links to allowed domains and their subdomains", func(t *testing.T) {
+		config := entities.FilterConfig{Links: &entities.LinkFilterConfig{Allow: []string{"example.com"}, Action: "reject"}}
+		decision := run(t, config, `{"type":"text","content":"see https://docs.example.com/a and www.example.com"}`)
+		assert.Equal(t, Allow, decision.Action)
+	})
+
+	t.Run("redacts links to other domains", func(t *testing.T) {
+		config := entities.FilterConfig{Links: &entities.LinkFilterConfig{Allow: []string{"example.com"}, Action: "redact"}}
+		decision := run(t, config, `{"type":"text","content":"see https://example.com and http://evil.io/x"}`)
+		assert.Equal(t, Redact, decision.Action)
+		assert.JSONEq(t, `{"type":"text","content":"see https://example.com and ****************"}`, string(decision.Body))
+	})
+
+	t.Run("flags repeated characters", func(t *testing.T) {
+		config := entities.FilterConfig{RepeatedChars: &entities.RepeatFilterConfig{Max: 4, Action: "flag"}}
+		decision := run(t, config, `{"type":"text","content":"noooooooo"}`)
+		assert.Equal(t, Flag, decision.Action)
+		assert.Equal(t, []string{"message contains repeated characters"}, decision.Reasons)
+	})
+
+	t.Run("collapses repeated characters but not whitespace", func(t *testing.T) {
+		config := entities.FilterConfig{RepeatedChars: &entities.RepeatFilterConfig{Max: 3, Action: "redact"}}
+		decision := run(t, config, `{"type":"text","content":"wowwwwww        ok"}`)
+		assert.Equal(t, Redact, decision.Action)
+		assert.JSONEq(t, `{"type":"text","content":"wowww        ok"}`, string(decision.Body))
+	})
+
+	t.Run("rejects invite links", func(t *testing.T) {
+		config := entities.FilterConfig{InviteLinks: &entities.InviteFilterConfig{Action: "reject"}}
+		for _, content := range []string{"join discord.gg/abc", "https://t.me/+XyZ", "https://discord.com/invite/abc"} {
+			body, _ := json.Marshal(map[string]string{"type": "text", "content": content})
+			decision := run(t, config, string(body))
+			assert.Equal(t, Reject, decision.Action, content)
+			assert.Equal(t, "message contains invite links to other communities", decision.Reason())
+		}
+	})
+
+	t.Run("most severe action decides and redactions are kept", func(t *testing.T) {
+		config := entities.FilterConfig{
+			Words:         &entities.WordFilterConfig{Words: []string{"darn"}, Action: "redact"},
+			RepeatedChars: &entities.RepeatFilterConfig{Max: 3, Action: "flag"},
+		}
+		decision := run(t, config, `{"type":"text","content":"darn!!!!!"}`)
+		assert.Equal(t, Flag, decision.Action)
+		assert.Equal(t, []string{"message contains repeated characters", "message contains blocked words"}, decision.Reasons)
+		assert.JSONEq(t, `{"type":"text","content":"****!!!!!"}`, string(decision.Body))
+	})
+
+	t.Run("keeps numbers as they are", func(t *testing.T) {
+		config := entities.FilterConfig{Words: &entities.WordFilterConfig{Words: []string{"darn"}, Action: "redact"}}
+		decision := run(t, config, `{"type":"image","url":"https://cdn.example.com/a.png","width":1234567890123,"height":1,"caption":"darn"}`)
+		assert.JSONEq(t, `{"type":"image","url":"https://cdn.example.com/a.png","width":1234567890123,"height":1,"caption":"****"}`, string(decision.Body))
+	})
+}
+
+func TestBuild(t *testing.T) {
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := Build(entities.FilterConfig{Words: &entities.WordFilterConfig{Patterns: []string{"(unclosed"}, Action: "flag"}})
+		assert.ErrorContains(t, err, `invalid pattern "(unclosed"`)
+	})
+
+	t.Run("empty word filter", func(t *testing.T) {
+		chain, err := Build(entities.FilterConfig{Words: &entities.WordFilterConfig{Action: "flag"}})
+		assert.NoError(t, err)
+		assert.Empty(t, chain)
+	})
+}
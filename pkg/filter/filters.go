@@ -0,0 +1,192 @@
+package filter
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+)
+
+// Build returns the chain of a channel configuration. It fails when a word
+// pattern is not a valid regular expression.
+//
+// Repeated characters are screened first, so the masks of later redactions
+// are not taken for spam, then invite links before other links, as they are
+// the more specific reason.
+func Build(config entities.FilterConfig) (Chain, error) {
+	var chain Chain
+	if config.RepeatedChars != nil {
+		chain = append(chain, &repeatFilter{
+			max:    config.RepeatedChars.Max,
+			action: ParseAction(config.RepeatedChars.Action),
+		})
+	}
+	if config.InviteLinks != nil {
+		chain = append(chain, &patternFilter{
+			pattern: invitePattern,
+			action:  ParseAction(config.InviteLinks.Action),
+			reason:  "message contains invite links to other communities",
+			links:   true,
+		})
+	}
+	if config.Links != nil {
+		chain = append(chain, &linkFilter{
+			allow:  config.Links.Allow,
+			action: ParseAction(config.Links.Action),
+		})
+	}
+	if config.Words != nil {
+		pattern, err := wordPattern(config.Words.Words, config.Words.Patterns)
+		if err != nil {
+			return nil, err
+		}
+		if pattern != nil {
+			chain = append(chain, &patternFilter{
+				pattern: pattern,
+				action:  ParseAction(config.Words.Action),
+				reason:  "message contains blocked words",
+			})
+		}
+	}
+	return chain, nil
+}
+
+// patternFilter matches a regular expression, masking the matches when it
+// redacts. links is set when the pattern matches links.
+type patternFilter struct {
+	pattern *regexp.Regexp
+	action  Action
+	reason  string
+	links   bool
+}
+
+func (f *patternFilter) checksLinks() bool {
+	return f.links
+}
+
+func (f *patternFilter) Check(text string) Outcome {
+	if !f.pattern.MatchString(text) {
+		return Outcome{}
+	}
+	outcome := Outcome{Action: f.action, Reason: f.reason}
+	if f.action == Redact {
+		outcome.Text = f.pattern.ReplaceAllStringFunc(text, mask)
+	}
+	return outcome
+}
+
+// wordPattern joins whole words and patterns into one case insensitive
+// expression. It is nil when there is nothing to match.
+func wordPattern(words []string, patterns []string) (*regexp.Regexp, error) {
+	var alternatives []string
+	if len(words) > 0 {
+		quoted := make([]string, len(words))
+		for i, word := range words {
+			quoted[i] = regexp.QuoteMeta(word)
+		}
+		alternatives = append(alternatives, `\b(?:`+strings.Join(quoted, "|")+`)\b`)
+	}
+	for _, pattern := range patterns {
+		// Compiled alone first so the error names the pattern at fault
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %s", pattern, err.Error())
+		}
+		alternatives = append(alternatives, "(?:"+pattern+")")
+	}
+	if len(alternatives) == 0 {
+		return nil, nil
+	}
+	return regexp.Compile("(?i)" + strings.Join(alternatives, "|"))
+}
+
+// Invite links of other chat services
+var invitePattern = regexp.MustCompile(`(?i)\b(?:https?://)?(?:www\.)?(?:discord(?:app)?\.com/invite/|discord\.gg/|t\.me/joinchat/|t\.me/\+|telegram\.me/joinchat/|chat\.whatsapp\.com/|join\.slack\.com/)\S*`)
+
+// Links with a scheme or starting with www.
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"']+`)
+
+// linkFilter matches links to domains that are not allowed
+type linkFilter struct {
+	allow  []string
+	action Action
+}
+
+func (f *linkFilter) Check(text string) Outcome {
+	matched := false
+	redacted := linkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		if f.allowed(link) {
+			return link
+		}
+		matched = true
+		return mask(link)
+	})
+	if !matched {
+		return Outcome{}
+	}
+	outcome := Outcome{Action: f.action, Reason: "message contains links to domains that are not allowed"}
+	if f.action == Redact {
+		outcome.Text = redacted
+	}
+	return outcome
+}
+
+func (f *linkFilter) checksLinks() bool {
+	return true
+}
+
+func (f *linkFilter) allowed(link string) bool {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range f.allow {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// repeatFilter matches characters repeated more than max times in a row.
+// Whitespace is left alone, as code is indented with it. Redacting cuts the
+// runs down to max characters.
+type repeatFilter struct {
+	max    int
+	action Action
+}
+
+func (f *repeatFilter) Check(text string) Outcome {
+	var collapsed strings.Builder
+	matched := false
+	var previous rune
+	run := 0
+	for _, r := range text {
+		if r == previous {
+			run++
+		} else {
+			previous = r
+			run = 1
+		}
+		if run > f.max && !unicode.IsSpace(r) {
+			matched = true
+			continue
+		}
+		collapsed.WriteRune(r)
+	}
+	if !matched {
+		return Outcome{}
+	}
+	outcome := Outcome{Action: f.action, Reason: "message contains repeated characters"}
+	if f.action == Redact {
+		outcome.Text = collapsed.String()
+	}
+	return outcome
+}
@@ -0,0 +1,60 @@
+// Package msgpack converts between JSON and MessagePack, so the values that
+// are stored and broadcast as JSON can be served to MessagePack clients.
+package msgpack
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// AppendJSON appends the JSON document raw to b as MessagePack. Integral
+// numbers are encoded as integers, every other number as a float64.
+func AppendJSON(b []byte, raw []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return b, err
+	}
+	return msgp.AppendIntf(b, normalize(v))
+}
+
+// normalize replaces the json.Numbers of v with int64 or float64
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalize(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+	}
+	return v
+}
+
+// ReadJSON reads the next MessagePack object of b as a JSON document and
+// returns the remaining bytes. Binary values become base64 strings, as they
+// do with encoding/json.
+func ReadJSON(b []byte) (json.RawMessage, []byte, error) {
+	v, o, err := msgp.ReadIntfBytes(b)
+	if err != nil {
+		return nil, b, err
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, b, err
+	}
+	return raw, o, nil
+}
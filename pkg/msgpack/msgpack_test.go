@@ -0,0 +1,57 @@
+package msgpack_test
+
+import (
+	"testing"
+
+	"github.com/aramceballos/chat-group-server/pkg/msgpack"
+	"github.com/stretchr/testify/assert"
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestAppendJSON(t *testing.T) {
+	t.Run("round trips a document", func(t *testing.T) {
+		doc := `{"type":"file","size_in_bytes":2048,"ratio":0.5,"tags":["a",null,true]}`
+
+		b, err := msgpack.AppendJSON(nil, []byte(doc))
+		assert.NoError(t, err)
+
+		raw, rest, err := msgpack.ReadJSON(b)
+		assert.NoError(t, err)
+		assert.Empty(t, rest)
+		assert.JSONEq(t, doc, string(raw))
+	})
+
+	t.Run("keeps integers integral", func(t *testing.T) {
+		b, err := msgpack.AppendJSON(nil, []byte(`{"count":3,"avg":1.5}`))
+		assert.NoError(t, err)
+
+		v, _, err := msgp.ReadIntfBytes(b)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"count": int64(3), "avg": 1.5}, v)
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		_, err := msgpack.AppendJSON(nil, []byte(`{"type":`))
+		assert.Error(t, err)
+	})
+}
+
+func TestReadJSON(t *testing.T) {
+	t.Run("returns the remaining bytes", func(t *testing.T) {
+		b := msgp.AppendString(nil, "first")
+		b = msgp.AppendInt(b, 2)
+
+		raw, rest, err := msgpack.ReadJSON(b)
+		assert.NoError(t, err)
+		assert.Equal(t, `"first"`, string(raw))
+		assert.Equal(t, msgp.AppendInt(nil, 2), rest)
+	})
+
+	t.Run("truncated input", func(t *testing.T) {
+		b := msgp.AppendMapHeader(nil, 2)
+		b = msgp.AppendString(b, "type")
+
+		_, _, err := msgpack.ReadJSON(b)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,216 @@
+package report
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/aramceballos/chat-group-server/pkg/store"
+	"github.com/lib/pq"
+)
+
+type Repository interface {
+	FetchMessage(messageId int) (entities.Message, error)
+	FetchMembershipRole(channelId int, userId int) (string, error)
+	IsDirectChannel(channelId int) (bool, error)
+	CreateReport(messageId int, channelId int, reporterId int, input entities.ReportInput) (entities.Report, bool, error)
+	FetchReport(reportId int) (entities.Report, error)
+	FetchReports(channelId int, statuses []string) ([]entities.Report, error)
+	TriageReport(reportId int, userId int, note string) (entities.Report, error)
+	ResolveReport(report entities.Report, userId int, input entities.ResolveReportInput, muteUntil *time.Time) (entities.ReportResolution, error)
+	Close() error
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (r *repository) Close() error {
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// reportColumns selects a report, read by scanReport
+const reportColumns = "r.id, r.message_id, r.channel_id, r.reporter_id, r.category, r.details, r.status, r.triaged_by, r.triaged_at, r.triage_note, r.resolution, r.resolution_note, r.resolved_by, r.resolved_at, r.created_at"
+
+// reportFields are the destinations of reportColumns
+func reportFields(report *entities.Report) []any {
+	return []any{&report.ID, &report.MessageID, &report.ChannelID, &report.ReporterID, &report.Category, &report.Details, &report.Status, &report.TriagedBy, &report.TriagedAt, &report.TriageNote, &report.Resolution, &report.ResolutionNote, &report.ResolvedBy, &report.ResolvedAt, &report.CreatedAt}
+}
+
+func scanReport(row rowScanner) (entities.Report, error) {
+	report := entities.Report{}
+	if err := row.Scan(reportFields(&report)...); err != nil {
+		return entities.Report{}, err
+	}
+	return report, nil
+}
+
+// FetchMessage returns a message without its author, deleted or not
+func (r *repository) FetchMessage(messageId int) (entities.Message, error) {
+	message := entities.Message{}
+	err := r.db.QueryRow("SELECT id, user_id, channel_id, body, created_at, deleted_at FROM messages WHERE id = $1", messageId).Scan(&message.ID, &message.UserID, &message.ChannelID, &message.Body, &message.CreatedAt, &message.DeletedAt)
+	return message, err
+}
+
+func (r *repository) FetchMembershipRole(channelId int, userId int) (string, error) {
+	var role string
+	err := r.db.QueryRow("SELECT role FROM memberships WHERE channel_id = $1 AND user_id = $2", channelId, userId).Scan(&role)
+	return role, err
+}
+
+// IsDirectChannel reports whether a channel is a direct message. Channels
+// without a row, from before channels were stored, are not.
+func (r *repository) IsDirectChannel(channelId int) (bool, error) {
+	var direct bool
+	err := r.db.QueryRow("SELECT dm_key IS NOT NULL FROM channels WHERE id = $1", channelId).Scan(&direct)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return direct, err
+}
+
+// CreateReport stores a report. created is false when the user already
+// reported the message.
+func (r *repository) CreateReport(messageId int, channelId int, reporterId int, input entities.ReportInput) (entities.Report, bool, error) {
+	report, err := scanReport(r.db.QueryRow("INSERT INTO reports AS r (message_id, channel_id, reporter_id, category, details) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (message_id, reporter_id) DO NOTHING RETURNING "+reportColumns, messageId, channelId, reporterId, input.Category, input.Details))
+	if err == sql.ErrNoRows {
+		return entities.Report{}, false, nil
+	}
+	if err != nil {
+		return entities.Report{}, false, err
+	}
+	return report, true, nil
+}
+
+func (r *repository) FetchReport(reportId int) (entities.Report, error) {
+	return scanReport(r.db.QueryRow("SELECT "+reportColumns+" FROM reports r WHERE r.id = $1", reportId))
+}
+
+// FetchReports returns the reports of a channel in one of statuses with the
+// reported messages, oldest first
+func (r *repository) FetchReports(channelId int, statuses []string) ([]entities.Report, error) {
+	rows, err := r.db.Query("SELECT "+reportColumns+", m.id, m.user_id, m.channel_id, m.body, m.created_at, m.edited_at, m.deleted_at, u.id, u.name, u.avatar_url, u.created_at FROM reports r JOIN messages m ON m.id = r.message_id JOIN users u ON u.id = m.user_id WHERE r.channel_id = $1 AND r.status = ANY($2) ORDER BY r.created_at", channelId, pq.Array(statuses))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []entities.Report{}
+	for rows.Next() {
+		report := entities.Report{}
+		message := entities.Message{}
+		dest := append(reportFields(&report), &message.ID, &message.UserID, &message.ChannelID, &message.Body, &message.CreatedAt, &message.EditedAt, &message.DeletedAt, &message.User.ID, &message.User.Name, &message.User.AvatarURL, &message.User.CreatedAt)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		report.Message = &message
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+// TriageReport takes an open report up for review. It returns sql.ErrNoRows
+// if the report is not open.
+func (r *repository) TriageReport(reportId int, userId int, note string) (entities.Report, error) {
+	return scanReport(r.db.QueryRow("UPDATE reports AS r SET status = $2, triaged_by = $3, triaged_at = NOW(), triage_note = $4 WHERE r.id = $1 AND r.status = $5 RETURNING "+reportColumns, reportId, entities.ReportTriaged, userId, note, entities.ReportOpen))
+}
+
+// ResolveReport closes a report, with the other reports of its message that
+// are still open, and carries out its resolution in the same transaction:
+// deleting the message, or muting its author until muteUntil. It returns
+// sql.ErrNoRows if the report was already resolved.
+func (r *repository) ResolveReport(report entities.Report, userId int, input entities.ResolveReportInput, muteUntil *time.Time) (entities.ReportResolution, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return entities.ReportResolution{}, err
+	}
+	defer tx.Rollback()
+
+	// Closed first, so a concurrent resolution of the reports does nothing
+	resolution, err := resolveReports(tx, report, userId, input)
+	if err != nil {
+		return entities.ReportResolution{}, err
+	}
+
+	switch input.Resolution {
+	case entities.ResolutionDelete:
+		message, err := store.DeleteMessage(tx, report.MessageID)
+		// The message may have been deleted since it was reported
+		if err != nil && err != sql.ErrNoRows {
+			return entities.ReportResolution{}, err
+		}
+		if err == nil {
+			resolution.Message = &message
+		}
+	case entities.ResolutionMuteAuthor:
+		var authorId int
+		if err := tx.QueryRow("SELECT user_id FROM messages WHERE id = $1", report.MessageID).Scan(&authorId); err != nil {
+			return entities.ReportResolution{}, err
+		}
+		moderation, err := store.AddModeration(tx, report.ChannelID, authorId, userId, entities.ModerationMute, muteReason(report, input), muteUntil)
+		if err != nil {
+			return entities.ReportResolution{}, err
+		}
+		resolution.Moderation = &moderation
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entities.ReportResolution{}, err
+	}
+	return resolution, nil
+}
+
+// resolveReports closes the reports of the message of report that are not
+// resolved yet. It returns sql.ErrNoRows if report is not one of them.
+func resolveReports(tx *sql.Tx, report entities.Report, userId int, input entities.ResolveReportInput) (entities.ReportResolution, error) {
+	rows, err := tx.Query("UPDATE reports AS r SET status = $2, resolution = $3, resolution_note = $4, resolved_by = $5, resolved_at = NOW() WHERE r.message_id = $1 AND r.status <> $2 RETURNING "+reportColumns, report.MessageID, entities.ReportResolved, input.Resolution, input.Note, userId)
+	if err != nil {
+		return entities.ReportResolution{}, err
+	}
+	defer rows.Close()
+
+	resolution := entities.ReportResolution{}
+	found := false
+	for rows.Next() {
+		resolved, err := scanReport(rows)
+		if err != nil {
+			return entities.ReportResolution{}, err
+		}
+		if resolved.ID == report.ID {
+			resolution.Report = resolved
+			found = true
+		} else {
+			resolution.OtherReports = append(resolution.OtherReports, resolved)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return entities.ReportResolution{}, err
+	}
+	if !found {
+		return entities.ReportResolution{}, sql.ErrNoRows
+	}
+
+	return resolution, nil
+}
+
+// muteReason is the note of the resolution, or the category of the report
+func muteReason(report entities.Report, input entities.ResolveReportInput) string {
+	if input.Note != "" {
+		return input.Note
+	}
+	return "reported for " + report.Category
+}
@@ -0,0 +1,197 @@
+package report
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+var reportRowColumns = []string{"id", "message_id", "channel_id", "reporter_id", "category", "details", "status", "triaged_by", "triaged_at", "triage_note", "resolution", "resolution_note", "resolved_by", "resolved_at", "created_at"}
+
+func setupMockRepository(t *testing.T) (*sql.DB, sqlmock.Sqlmock, Repository) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	repo := NewRepository(db)
+	assert.NotNil(t, repo)
+	return db, mock, repo
+}
+
+func TestCreateReport(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	input := entities.ReportInput{Category: "spam", Details: "ads"}
+
+	t.Run("created", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO reports AS r \\(message_id, channel_id, reporter_id, category, details\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5\\) ON CONFLICT \\(message_id, reporter_id\\) DO NOTHING RETURNING r.id").
+			WithArgs(7, 1, 2, "spam", "ads").
+			WillReturnRows(sqlmock.NewRows(reportRowColumns).AddRow(3, 7, 1, 2, "spam", "ads", "open", nil, nil, "", nil, "", nil, nil, "2025-07-19T10:30:00Z"))
+
+		report, created, err := repo.CreateReport(7, 1, 2, input)
+		assert.NoError(t, err)
+		assert.True(t, created)
+		assert.Equal(t, 3, report.ID)
+		assert.Equal(t, entities.ReportOpen, report.Status)
+		assert.Nil(t, report.Resolution)
+	})
+
+	t.Run("already reported", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO reports").
+			WithArgs(7, 1, 2, "spam", "ads").
+			WillReturnRows(sqlmock.NewRows(reportRowColumns))
+
+		_, created, err := repo.CreateReport(7, 1, 2, input)
+		assert.NoError(t, err)
+		assert.False(t, created)
+	})
+}
+
+func TestIsDirectChannel(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	t.Run("direct message", func(t *testing.T) {
+		mock.ExpectQuery("SELECT dm_key IS NOT NULL FROM channels WHERE id = \\$1").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"direct"}).AddRow(true))
+
+		direct, err := repo.IsDirectChannel(1)
+		assert.NoError(t, err)
+		assert.True(t, direct)
+	})
+
+	t.Run("channel without a row", func(t *testing.T) {
+		mock.ExpectQuery("SELECT dm_key IS NOT NULL FROM channels").
+			WithArgs(1).
+			WillReturnError(sql.ErrNoRows)
+
+		direct, err := repo.IsDirectChannel(1)
+		assert.NoError(t, err)
+		assert.False(t, direct)
+	})
+}
+
+func TestFetchReports(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	columns := append(append([]string{}, reportRowColumns...), "id", "user_id", "channel_id", "body", "created_at", "edited_at", "deleted_at", "id", "name", "avatar_url", "created_at")
+	rows := sqlmock.NewRows(columns).
+		AddRow(3, 7, 1, 2, "spam", "", "open", nil, nil, "", nil, "", nil, nil, "2025-07-19T10:30:00Z", 7, 5, 1, []byte(`{"type":"text","content":"buy now"}`), "2025-07-19T10:00:00Z", nil, nil, 5, "Alice", "", "2025-01-01T00:00:00Z")
+
+	mock.ExpectQuery("SELECT r.id, (.+) FROM reports r JOIN messages m ON m.id = r.message_id JOIN users u ON u.id = m.user_id WHERE r.channel_id = \\$1 AND r.status = ANY\\(\\$2\\) ORDER BY r.created_at").
+		WithArgs(1, `{"open","triaged"}`).
+		WillReturnRows(rows)
+
+	reports, err := repo.FetchReports(1, []string{entities.ReportOpen, entities.ReportTriaged})
+	assert.NoError(t, err)
+	assert.Len(t, reports, 1)
+	assert.Equal(t, 2, reports[0].ReporterID)
+	assert.Equal(t, 7, reports[0].Message.ID)
+	assert.Equal(t, "Alice", reports[0].Message.User.Name)
+}
+
+func TestTriageReport(t *testing.T) {
+	db, mock, repo := setupMockRepository(t)
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE reports AS r SET status = \\$2, triaged_by = \\$3, triaged_at = NOW\\(\\), triage_note = \\$4 WHERE r.id = \\$1 AND r.status = \\$5").
+		WithArgs(3, entities.ReportTriaged, 4, "looking", entities.ReportOpen).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := repo.TriageReport(3, 4, "looking")
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestResolveReport(t *testing.T) {
+	report := entities.Report{ID: 3, MessageID: 7, ChannelID: 1, Category: "spam"}
+
+	t.Run("delete message", func(t *testing.T) {
+		db, mock, repo := setupMockRepository(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE reports AS r SET status = \\$2, resolution = \\$3, resolution_note = \\$4, resolved_by = \\$5, resolved_at = NOW\\(\\) WHERE r.message_id = \\$1 AND r.status <> \\$2").
+			WithArgs(7, entities.ReportResolved, entities.ResolutionDelete, "", 4).
+			WillReturnRows(sqlmock.NewRows(reportRowColumns).AddRow(3, 7, 1, 2, "spam", "", "resolved", nil, nil, "", "delete_message", "", 4, "2025-07-20T08:00:00Z", "2025-07-19T10:30:00Z"))
+		mock.ExpectQuery("UPDATE messages SET body = 'null'::jsonb, deleted_at = NOW\\(\\) WHERE id = \\$1 AND deleted_at IS NULL").
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "channel_id", "body", "created_at", "edited_at", "deleted_at"}).AddRow(7, 5, 1, []byte("null"), "2025-07-19T10:00:00Z", nil, "2025-07-20T08:00:00Z"))
+		mock.ExpectCommit()
+
+		resolution, err := repo.ResolveReport(report, 4, entities.ResolveReportInput{Resolution: entities.ResolutionDelete}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.ResolutionDelete, *resolution.Report.Resolution)
+		assert.Equal(t, 7, resolution.Message.ID)
+		assert.Nil(t, resolution.Moderation)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("mute author", func(t *testing.T) {
+		db, mock, repo := setupMockRepository(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE reports AS r").
+			WithArgs(7, entities.ReportResolved, entities.ResolutionMuteAuthor, "", 4).
+			WillReturnRows(sqlmock.NewRows(reportRowColumns).AddRow(3, 7, 1, 2, "spam", "", "resolved", nil, nil, "", "mute_author", "", 4, "2025-07-20T08:00:00Z", "2025-07-19T10:30:00Z"))
+		mock.ExpectQuery("SELECT user_id FROM messages WHERE id = \\$1").
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(5))
+		mock.ExpectExec("UPDATE channel_moderations SET revoked_at = NOW\\(\\), revoked_by = \\$3 WHERE channel_id = \\$1 AND user_id = \\$2 AND action = \\$4 AND revoked_at IS NULL").
+			WithArgs(1, 5, 4, entities.ModerationMute).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("INSERT INTO channel_moderations AS mo \\(channel_id, user_id, action, reason, actor_id, expires_at\\)").
+			WithArgs(1, 5, entities.ModerationMute, "reported for spam", 4, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "channel_id", "user_id", "action", "reason", "actor_id", "expires_at", "created_at", "revoked_at", "revoked_by"}).AddRow(9, 1, 5, "mute", "reported for spam", 4, "2025-07-20T08:10:00Z", "2025-07-20T08:00:00Z", nil, nil))
+		mock.ExpectCommit()
+
+		resolution, err := repo.ResolveReport(report, 4, entities.ResolveReportInput{Resolution: entities.ResolutionMuteAuthor}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, resolution.Moderation.UserID)
+		assert.Equal(t, "reported for spam", resolution.Moderation.Reason)
+		assert.Nil(t, resolution.Message)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("resolves the other reports of the message", func(t *testing.T) {
+		db, mock, repo := setupMockRepository(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE reports AS r").
+			WithArgs(7, entities.ReportResolved, entities.ResolutionDismiss, "", 4).
+			WillReturnRows(sqlmock.NewRows(reportRowColumns).
+				AddRow(3, 7, 1, 2, "spam", "", "resolved", nil, nil, "", "dismiss", "", 4, "2025-07-20T08:00:00Z", "2025-07-19T10:30:00Z").
+				AddRow(5, 7, 1, 6, "other", "", "resolved", nil, nil, "", "dismiss", "", 4, "2025-07-20T08:00:00Z", "2025-07-19T10:40:00Z"))
+		mock.ExpectCommit()
+
+		resolution, err := repo.ResolveReport(report, 4, entities.ResolveReportInput{Resolution: entities.ResolutionDismiss}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, resolution.Report.ID)
+		assert.Len(t, resolution.OtherReports, 1)
+		assert.Equal(t, 5, resolution.OtherReports[0].ID)
+		assert.Equal(t, entities.ReportResolved, resolution.OtherReports[0].Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already resolved", func(t *testing.T) {
+		db, mock, repo := setupMockRepository(t)
+		defer db.Close()
+
+		// Only other reports of the message were still open
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE reports AS r").
+			WithArgs(7, entities.ReportResolved, entities.ResolutionDismiss, "", 4).
+			WillReturnRows(sqlmock.NewRows(reportRowColumns).AddRow(5, 7, 1, 6, "other", "", "resolved", nil, nil, "", "dismiss", "", 4, "2025-07-20T08:00:00Z", "2025-07-19T10:40:00Z"))
+		mock.ExpectRollback()
+
+		_, err := repo.ResolveReport(report, 4, entities.ResolveReportInput{Resolution: entities.ResolutionDismiss}, nil)
+		assert.Equal(t, sql.ErrNoRows, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
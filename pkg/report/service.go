@@ -0,0 +1,235 @@
+package report
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/aramceballos/chat-group-server/pkg/schema"
+)
+
+type Service interface {
+	ReportMessage(userId int, messageId int, input entities.ReportInput) (entities.Report, error)
+	FetchReports(channelId int, userId int, status string) ([]entities.Report, error)
+	TriageReport(channelId int, userId int, reportId int, input entities.TriageReportInput) (entities.Report, error)
+	ResolveReport(channelId int, userId int, reportId int, input entities.ResolveReportInput) (entities.ReportResolution, error)
+}
+
+var (
+	ErrMessageNotFound  = errors.New("message not found")
+	ErrOwnMessage       = errors.New("you cannot report your own message")
+	ErrSystemMessage    = errors.New("system messages cannot be reported")
+	ErrDirectMessage    = errors.New("messages of direct conversations cannot be reported")
+	ErrAlreadyReported  = errors.New("you already reported this message")
+	ErrNotAllowed       = errors.New("only channel admins can review reports")
+	ErrReportNotFound   = errors.New("report not found")
+	ErrInvalidStatus    = errors.New("status must be open, triaged or resolved")
+	ErrNotOpen          = errors.New("report was already triaged")
+	ErrAlreadyResolved  = errors.New("report was already resolved")
+	ErrInvalidDuration  = errors.New("muting the author requires a duration")
+	ErrCannotMuteAuthor = errors.New("you are not allowed to mute the author of this message")
+)
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{
+		repo,
+	}
+}
+
+// ReportMessage reports a live message on behalf of a member of its channel.
+// Messages of other channels are not found, so reports cannot be used to
+// probe them.
+func (s *service) ReportMessage(userId int, messageId int, input entities.ReportInput) (entities.Report, error) {
+	message, err := s.repo.FetchMessage(messageId)
+	if err == sql.ErrNoRows {
+		return entities.Report{}, ErrMessageNotFound
+	}
+	if err != nil {
+		log.Printf("[report service error] error fetching message: %s", err.Error())
+		return entities.Report{}, fmt.Errorf("error reporting message")
+	}
+	if message.DeletedAt != nil {
+		return entities.Report{}, ErrMessageNotFound
+	}
+
+	if _, err := s.repo.FetchMembershipRole(message.ChannelID, userId); err == sql.ErrNoRows {
+		return entities.Report{}, ErrMessageNotFound
+	} else if err != nil {
+		log.Printf("[report service error] error fetching membership role: %s", err.Error())
+		return entities.Report{}, fmt.Errorf("error reporting message")
+	}
+	// Both members of a direct message are plain members, so nobody could
+	// review its reports
+	if direct, err := s.repo.IsDirectChannel(message.ChannelID); err != nil {
+		log.Printf("[report service error] error fetching channel: %s", err.Error())
+		return entities.Report{}, fmt.Errorf("error reporting message")
+	} else if direct {
+		return entities.Report{}, ErrDirectMessage
+	}
+	if schema.IsSystem(message.Body) {
+		return entities.Report{}, ErrSystemMessage
+	}
+	if message.UserID == int64(userId) {
+		return entities.Report{}, ErrOwnMessage
+	}
+
+	report, created, err := s.repo.CreateReport(messageId, message.ChannelID, userId, input)
+	if err != nil {
+		log.Printf("[report service error] error creating report: %s", err.Error())
+		return entities.Report{}, fmt.Errorf("error reporting message")
+	}
+	if !created {
+		return entities.Report{}, ErrAlreadyReported
+	}
+	return report, nil
+}
+
+// authorizeReview fetches the role of userId, who must be an admin or the
+// owner of the channel
+func (s *service) authorizeReview(channelId int, userId int) (string, error) {
+	role, err := s.repo.FetchMembershipRole(channelId, userId)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("[report service error] error fetching membership role: %s", err.Error())
+		return "", fmt.Errorf("error fetching membership role")
+	}
+	if !entities.CanManage(role) {
+		return "", ErrNotAllowed
+	}
+	return role, nil
+}
+
+// FetchReports returns the reports of the channel with status, or the ones
+// still to resolve when status is empty
+func (s *service) FetchReports(channelId int, userId int, status string) ([]entities.Report, error) {
+	statuses := []string{entities.ReportOpen, entities.ReportTriaged}
+	switch status {
+	case "":
+	case entities.ReportOpen, entities.ReportTriaged, entities.ReportResolved:
+		statuses = []string{status}
+	default:
+		return nil, ErrInvalidStatus
+	}
+
+	if _, err := s.authorizeReview(channelId, userId); err != nil {
+		return nil, err
+	}
+
+	reports, err := s.repo.FetchReports(channelId, statuses)
+	if err != nil {
+		log.Printf("[report service error] error fetching reports: %s", err.Error())
+		return nil, fmt.Errorf("error fetching reports")
+	}
+	for i := range reports {
+		reports[i].Message.Body = schema.Normalize(reports[i].Message.Body)
+	}
+	return reports, nil
+}
+
+func (s *service) fetchReport(channelId int, reportId int) (entities.Report, error) {
+	report, err := s.repo.FetchReport(reportId)
+	if err == sql.ErrNoRows || (err == nil && report.ChannelID != channelId) {
+		return entities.Report{}, ErrReportNotFound
+	}
+	if err != nil {
+		log.Printf("[report service error] error fetching report: %s", err.Error())
+		return entities.Report{}, fmt.Errorf("error fetching report")
+	}
+	return report, nil
+}
+
+// TriageReport takes an open report up for review, so other admins know it
+// is being looked at
+func (s *service) TriageReport(channelId int, userId int, reportId int, input entities.TriageReportInput) (entities.Report, error) {
+	if _, err := s.authorizeReview(channelId, userId); err != nil {
+		return entities.Report{}, err
+	}
+	if _, err := s.fetchReport(channelId, reportId); err != nil {
+		return entities.Report{}, err
+	}
+
+	report, err := s.repo.TriageReport(reportId, userId, input.Note)
+	if err == sql.ErrNoRows {
+		return entities.Report{}, ErrNotOpen
+	}
+	if err != nil {
+		log.Printf("[report service error] error triaging report: %s", err.Error())
+		return entities.Report{}, fmt.Errorf("error triaging report")
+	}
+	return report, nil
+}
+
+// ResolveReport dismisses a report, deletes the reported message or mutes its
+// author, and records the resolution on every unresolved report of the
+// message. Muting takes an admin who outranks the
+// author, like any mute.
+func (s *service) ResolveReport(channelId int, userId int, reportId int, input entities.ResolveReportInput) (entities.ReportResolution, error) {
+	var muteUntil *time.Time
+	if input.Resolution == entities.ResolutionMuteAuthor {
+		if input.Duration == 0 {
+			return entities.ReportResolution{}, ErrInvalidDuration
+		}
+		expiry := time.Now().Add(time.Duration(input.Duration) * time.Second)
+		muteUntil = &expiry
+	}
+
+	role, err := s.authorizeReview(channelId, userId)
+	if err != nil {
+		return entities.ReportResolution{}, err
+	}
+	report, err := s.fetchReport(channelId, reportId)
+	if err != nil {
+		return entities.ReportResolution{}, err
+	}
+	if report.Status == entities.ReportResolved {
+		return entities.ReportResolution{}, ErrAlreadyResolved
+	}
+
+	if muteUntil != nil {
+		if err := s.checkCanMuteAuthor(report, userId, role); err != nil {
+			return entities.ReportResolution{}, err
+		}
+	}
+
+	resolution, err := s.repo.ResolveReport(report, userId, input, muteUntil)
+	if err == sql.ErrNoRows {
+		return entities.ReportResolution{}, ErrAlreadyResolved
+	}
+	if err != nil {
+		log.Printf("[report service error] error resolving report: %s", err.Error())
+		return entities.ReportResolution{}, fmt.Errorf("error resolving report")
+	}
+	return resolution, nil
+}
+
+// checkCanMuteAuthor checks that userId, with role, outranks the author of the
+// reported message. Authors who left the channel can be muted by any admin.
+func (s *service) checkCanMuteAuthor(report entities.Report, userId int, role string) error {
+	message, err := s.repo.FetchMessage(report.MessageID)
+	if err != nil {
+		log.Printf("[report service error] error fetching message: %s", err.Error())
+		return fmt.Errorf("error resolving report")
+	}
+	if message.UserID == int64(userId) {
+		return ErrCannotMuteAuthor
+	}
+
+	authorRole, err := s.repo.FetchMembershipRole(report.ChannelID, int(message.UserID))
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		log.Printf("[report service error] error fetching membership role: %s", err.Error())
+		return fmt.Errorf("error resolving report")
+	}
+	if !entities.Outranks(role, authorRole) {
+		return ErrCannotMuteAuthor
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package report
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockRepository struct {
+	message      entities.Message
+	messageError error
+	// Roles by user. Users not in it are not members.
+	roles         map[int]string
+	roleError     error
+	direct        bool
+	report        entities.Report
+	reportError   error
+	duplicate     bool
+	writeError    error
+	resolveError  error
+	triageError   error
+	statuses      *[]string
+	resolvedUntil **time.Time
+}
+
+func (mr mockRepository) FetchMessage(messageId int) (entities.Message, error) {
+	return mr.message, mr.messageError
+}
+
+func (mr mockRepository) FetchMembershipRole(channelId int, userId int) (string, error) {
+	if mr.roleError != nil {
+		return "", mr.roleError
+	}
+	role, ok := mr.roles[userId]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return role, nil
+}
+
+func (mr mockRepository) IsDirectChannel(channelId int) (bool, error) {
+	return mr.direct, nil
+}
+
+func (mr mockRepository) CreateReport(messageId int, channelId int, reporterId int, input entities.ReportInput) (entities.Report, bool, error) {
+	if mr.duplicate {
+		return entities.Report{}, false, mr.writeError
+	}
+	report := entities.Report{MessageID: messageId, ChannelID: channelId, ReporterID: reporterId, Category: input.Category, Status: entities.ReportOpen}
+	return report, true, mr.writeError
+}
+
+func (mr mockRepository) FetchReport(reportId int) (entities.Report, error) {
+	return mr.report, mr.reportError
+}
+
+func (mr mockRepository) FetchReports(channelId int, statuses []string) ([]entities.Report, error) {
+	if mr.statuses != nil {
+		*mr.statuses = statuses
+	}
+	report := mr.report
+	report.Message = &mr.message
+	return []entities.Report{report}, mr.reportError
+}
+
+func (mr mockRepository) TriageReport(reportId int, userId int, note string) (entities.Report, error) {
+	triaged := mr.report
+	triaged.Status = entities.ReportTriaged
+	triaged.TriagedBy = &userId
+	triaged.TriageNote = note
+	return triaged, mr.triageError
+}
+
+func (mr mockRepository) ResolveReport(report entities.Report, userId int, input entities.ResolveReportInput, muteUntil *time.Time) (entities.ReportResolution, error) {
+	if mr.resolvedUntil != nil {
+		*mr.resolvedUntil = muteUntil
+	}
+	report.Status = entities.ReportResolved
+	report.Resolution = &input.Resolution
+	report.ResolvedBy = &userId
+	return entities.ReportResolution{Report: report}, mr.resolveError
+}
+
+func (mr mockRepository) Close() error {
+	return nil
+}
+
+func TestReportMessageService(t *testing.T) {
+	message := entities.Message{ID: 7, UserID: 5, ChannelID: 1}
+	roles := map[int]string{2: entities.RoleMember, 5: entities.RoleMember}
+	input := entities.ReportInput{Category: "spam"}
+
+	t.Run("reported", func(t *testing.T) {
+		s := NewService(mockRepository{message: message, roles: roles})
+		report, err := s.ReportMessage(2, 7, input)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.Report{MessageID: 7, ChannelID: 1, ReporterID: 2, Category: "spam", Status: entities.ReportOpen}, report)
+	})
+
+	t.Run("already reported", func(t *testing.T) {
+		s := NewService(mockRepository{message: message, roles: roles, duplicate: true})
+		_, err := s.ReportMessage(2, 7, input)
+		assert.Equal(t, ErrAlreadyReported, err)
+	})
+
+	t.Run("own message", func(t *testing.T) {
+		s := NewService(mockRepository{message: message, roles: roles})
+		_, err := s.ReportMessage(5, 7, input)
+		assert.Equal(t, ErrOwnMessage, err)
+	})
+
+	t.Run("system message", func(t *testing.T) {
+		joined := message
+		joined.Body = []byte(`{"type":"system","event":"member_joined","content":"Alice joined the channel"}`)
+		s := NewService(mockRepository{message: joined, roles: roles})
+		_, err := s.ReportMessage(2, 7, input)
+		assert.Equal(t, ErrSystemMessage, err)
+	})
+
+	t.Run("direct message", func(t *testing.T) {
+		s := NewService(mockRepository{message: message, roles: roles, direct: true})
+		_, err := s.ReportMessage(2, 7, input)
+		assert.Equal(t, ErrDirectMessage, err)
+	})
+
+	t.Run("message of another channel", func(t *testing.T) {
+		s := NewService(mockRepository{message: message, roles: map[int]string{5: entities.RoleMember}})
+		_, err := s.ReportMessage(2, 7, input)
+		assert.Equal(t, ErrMessageNotFound, err)
+	})
+
+	t.Run("deleted message", func(t *testing.T) {
+		deleted := message
+		deletedAt := "2025-07-19T10:30:00Z"
+		deleted.DeletedAt = &deletedAt
+		s := NewService(mockRepository{message: deleted, roles: roles})
+		_, err := s.ReportMessage(2, 7, input)
+		assert.Equal(t, ErrMessageNotFound, err)
+	})
+
+	t.Run("message not found", func(t *testing.T) {
+		s := NewService(mockRepository{messageError: sql.ErrNoRows, roles: roles})
+		_, err := s.ReportMessage(2, 7, input)
+		assert.Equal(t, ErrMessageNotFound, err)
+	})
+}
+
+func TestFetchReportsService(t *testing.T) {
+	t.Run("unresolved by default", func(t *testing.T) {
+		var statuses []string
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleAdmin}, statuses: &statuses})
+		reports, err := s.FetchReports(1, 2, "")
+		assert.NoError(t, err)
+		assert.Len(t, reports, 1)
+		assert.Equal(t, []string{entities.ReportOpen, entities.ReportTriaged}, statuses)
+	})
+
+	t.Run("by status", func(t *testing.T) {
+		var statuses []string
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleOwner}, statuses: &statuses})
+		_, err := s.FetchReports(1, 2, entities.ReportResolved)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{entities.ReportResolved}, statuses)
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleAdmin}})
+		_, err := s.FetchReports(1, 2, "closed")
+		assert.Equal(t, ErrInvalidStatus, err)
+	})
+
+	t.Run("member not allowed", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleMember}})
+		_, err := s.FetchReports(1, 2, "")
+		assert.Equal(t, ErrNotAllowed, err)
+	})
+}
+
+func TestTriageReportService(t *testing.T) {
+	open := entities.Report{ID: 3, MessageID: 7, ChannelID: 1, Status: entities.ReportOpen}
+	roles := map[int]string{2: entities.RoleAdmin}
+
+	t.Run("triaged", func(t *testing.T) {
+		s := NewService(mockRepository{roles: roles, report: open})
+		report, err := s.TriageReport(1, 2, 3, entities.TriageReportInput{Note: "looking"})
+		assert.NoError(t, err)
+		assert.Equal(t, entities.ReportTriaged, report.Status)
+		assert.Equal(t, 2, *report.TriagedBy)
+		assert.Equal(t, "looking", report.TriageNote)
+	})
+
+	t.Run("not open", func(t *testing.T) {
+		s := NewService(mockRepository{roles: roles, report: open, triageError: sql.ErrNoRows})
+		_, err := s.TriageReport(1, 2, 3, entities.TriageReportInput{})
+		assert.Equal(t, ErrNotOpen, err)
+	})
+
+	t.Run("report of another channel", func(t *testing.T) {
+		s := NewService(mockRepository{roles: roles, report: open})
+		_, err := s.TriageReport(4, 2, 3, entities.TriageReportInput{})
+		assert.Equal(t, ErrReportNotFound, err)
+	})
+}
+
+func TestResolveReportService(t *testing.T) {
+	triaged := entities.Report{ID: 3, MessageID: 7, ChannelID: 1, Status: entities.ReportTriaged}
+	message := entities.Message{ID: 7, UserID: 5, ChannelID: 1}
+	roles := map[int]string{2: entities.RoleAdmin, 5: entities.RoleMember}
+
+	t.Run("dismissed", func(t *testing.T) {
+		s := NewService(mockRepository{roles: roles, report: triaged})
+		resolution, err := s.ResolveReport(1, 2, 3, entities.ResolveReportInput{Resolution: entities.ResolutionDismiss, Note: "fine"})
+		assert.NoError(t, err)
+		assert.Equal(t, entities.ReportResolved, resolution.Report.Status)
+		assert.Equal(t, entities.ResolutionDismiss, *resolution.Report.Resolution)
+	})
+
+	t.Run("mute author expires", func(t *testing.T) {
+		var until *time.Time
+		s := NewService(mockRepository{roles: roles, report: triaged, message: message, resolvedUntil: &until})
+		_, err := s.ResolveReport(1, 2, 3, entities.ResolveReportInput{Resolution: entities.ResolutionMuteAuthor, Duration: 600})
+		assert.NoError(t, err)
+		assert.NotNil(t, until)
+	})
+
+	t.Run("mute without duration", func(t *testing.T) {
+		s := NewService(mockRepository{roles: roles, report: triaged, message: message})
+		_, err := s.ResolveReport(1, 2, 3, entities.ResolveReportInput{Resolution: entities.ResolutionMuteAuthor})
+		assert.Equal(t, ErrInvalidDuration, err)
+	})
+
+	t.Run("mute an author of the same rank", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleAdmin, 5: entities.RoleAdmin}, report: triaged, message: message})
+		_, err := s.ResolveReport(1, 2, 3, entities.ResolveReportInput{Resolution: entities.ResolutionMuteAuthor, Duration: 600})
+		assert.Equal(t, ErrCannotMuteAuthor, err)
+	})
+
+	t.Run("mute an author who left", func(t *testing.T) {
+		s := NewService(mockRepository{roles: map[int]string{2: entities.RoleAdmin}, report: triaged, message: message})
+		_, err := s.ResolveReport(1, 2, 3, entities.ResolveReportInput{Resolution: entities.ResolutionMuteAuthor, Duration: 600})
+		assert.NoError(t, err)
+	})
+
+	t.Run("already resolved", func(t *testing.T) {
+		resolved := triaged
+		resolved.Status = entities.ReportResolved
+		s := NewService(mockRepository{roles: roles, report: resolved})
+		_, err := s.ResolveReport(1, 2, 3, entities.ResolveReportInput{Resolution: entities.ResolutionDelete})
+		assert.Equal(t, ErrAlreadyResolved, err)
+	})
+
+	t.Run("resolved concurrently", func(t *testing.T) {
+		s := NewService(mockRepository{roles: roles, report: triaged, resolveError: sql.ErrNoRows})
+		_, err := s.ResolveReport(1, 2, 3, entities.ResolveReportInput{Resolution: entities.ResolutionDelete})
+		assert.Equal(t, ErrAlreadyResolved, err)
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		s := NewService(mockRepository{roles: roles, report: triaged, resolveError: errors.New("db down")})
+		_, err := s.ResolveReport(1, 2, 3, entities.ResolveReportInput{Resolution: entities.ResolutionDelete})
+		assert.EqualError(t, err, "error resolving report")
+	})
+
+	t.Run("report not found", func(t *testing.T) {
+		s := NewService(mockRepository{roles: roles, reportError: sql.ErrNoRows})
+		_, err := s.ResolveReport(1, 2, 3, entities.ResolveReportInput{Resolution: entities.ResolutionDismiss})
+		assert.Equal(t, ErrReportNotFound, err)
+	})
+}
@@ -0,0 +1,197 @@
+// Package schema holds the types of message bodies. Every body is a JSON
+// object whose "type" field selects a registered Go struct, which defines the
+// fields the body may have and how they are validated.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Body is the content of a message of one type. Validate runs after the rules
+// of the struct tags, for the ones tags cannot express.
+type Body interface {
+	Validate() error
+}
+
+var (
+	ErrMissingType = errors.New("Message body must have a 'type' field")
+	ErrUnknownType = errors.New("unsupported message type")
+	ErrSystemType  = errors.New("system messages cannot be sent by clients")
+)
+
+type bodyType struct {
+	new    func() Body
+	system bool
+	fields Fields
+}
+
+// Fields are the fields of a body type holding what its author wrote, tagged
+// `screen:"text"` or `screen:"link"`. Message filters screen them: text may be
+// redacted, links may only be rejected or flagged, as masking them would
+// break the body.
+type Fields struct {
+	Text  []string
+	Links []string
+}
+
+var (
+	registry = map[string]bodyType{}
+	validate = validator.New()
+)
+
+func init() {
+	// Report fields by their JSON name
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	})
+}
+
+// Register makes bodies of type typ decode into the value returned by new,
+// which must be a pointer to a struct with a "type" JSON field. Fields holding
+// what the author wrote are tagged with screen, see Fields. It panics if typ
+// is registered twice.
+func Register(typ string, new func() Body) {
+	register(typ, bodyType{new: new})
+}
+
+// RegisterSystem registers a type only the server may post
+func RegisterSystem(typ string, new func() Body) {
+	register(typ, bodyType{new: new, system: true})
+}
+
+func register(typ string, t bodyType) {
+	if _, ok := registry[typ]; ok {
+		panic("schema: body type " + typ + " registered twice")
+	}
+	t.fields = screenedFields(reflect.TypeOf(t.new()).Elem())
+	registry[typ] = t
+}
+
+// screenedFields lists the JSON names of the fields of a body struct by their
+// screen tag, in declaration order
+func screenedFields(typ reflect.Type) Fields {
+	fields := Fields{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		switch field.Tag.Get("screen") {
+		case "text":
+			fields.Text = append(fields.Text, name)
+		case "link":
+			fields.Links = append(fields.Links, name)
+		}
+	}
+	return fields
+}
+
+// ScreenedFields returns the fields to screen of the type of raw, none when
+// it has no registered type
+func ScreenedFields(raw []byte) Fields {
+	typ, err := typeOf(raw)
+	if err != nil {
+		return Fields{}
+	}
+	return registry[typ].fields
+}
+
+// typeOf reads the "type" field of a body
+func typeOf(raw []byte) (string, error) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return "", err
+	}
+	return header.Type, nil
+}
+
+// Types returns the registered types, sorted
+func Types() []string {
+	types := make([]string, 0, len(registry))
+	for typ := range registry {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// IsSystem reports whether raw is a body of a type only the server may post
+func IsSystem(raw []byte) bool {
+	typ, err := typeOf(raw)
+	if err != nil {
+		return false
+	}
+	return registry[typ].system
+}
+
+// Parse decodes a body sent by a client, which may not be of a system type
+func Parse(raw []byte) (Body, error) {
+	return decode(raw, false)
+}
+
+// Decode decodes a body of any registered type
+func Decode(raw []byte) (Body, error) {
+	return decode(raw, true)
+}
+
+func decode(raw []byte, allowSystem bool) (Body, error) {
+	typ, err := typeOf(raw)
+	if err != nil {
+		return nil, errors.New("Invalid message body JSON")
+	}
+	if typ == "" {
+		return nil, ErrMissingType
+	}
+
+	t, ok := registry[typ]
+	if !ok {
+		return nil, ErrUnknownType
+	}
+	if t.system && !allowSystem {
+		return nil, ErrSystemType
+	}
+
+	body := t.new()
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(body); err != nil {
+		return nil, fmt.Errorf("invalid %s message: %s", typ, strings.TrimPrefix(err.Error(), "json: "))
+	}
+
+	if err := validate.Struct(body); err != nil {
+		var fieldErrors validator.ValidationErrors
+		if errors.As(err, &fieldErrors) {
+			field := fieldErrors[0]
+			return nil, fmt.Errorf("invalid %s message: '%s' failed the '%s' rule", typ, field.Field(), field.Tag())
+		}
+		return nil, err
+	}
+	if err := body.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s message: %s", typ, err.Error())
+	}
+
+	return body, nil
+}
+
+// Normalize re-encodes a stored body through its type, so it is served with
+// the same contract it is accepted with. Bodies that do not decode, like the
+// null body of a deleted message, are returned unchanged.
+func Normalize(raw json.RawMessage) json.RawMessage {
+	body, err := Decode(raw)
+	if err != nil {
+		return raw
+	}
+	normalized, err := json.Marshal(body)
+	if err != nil {
+		return raw
+	}
+	return normalized
+}
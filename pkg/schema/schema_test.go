@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("valid bodies", func(t *testing.T) {
+		bodies := map[string]Body{
+			`{"type":"text","content":"hello"}`:                                                      &Text{Type: "text", Content: "hello"},
+			`{"type":"image","url":"https://cdn.example.com/a.png","width":640,"height":480}`:        &Image{Type: "image", URL: "https://cdn.example.com/a.png", Width: 640, Height: 480},
+			`{"type":"link","url":"https://example.com","title":"Example"}`:                          &Link{Type: "link", URL: "https://example.com", Title: "Example"},
+			`{"type":"code","language":"go","content":"package main"}`:                               &Code{Type: "code", Language: "go", Content: "package main"},
+			`{"type":"poll","question":"Lunch?","options":["pizza","sushi"],"multiple_choice":true}`: &Poll{Type: "poll", Question: "Lunch?", Options: []string{"pizza", "sushi"}, MultipleChoice: true},
+		}
+		for raw, expected := range bodies {
+			body, err := Parse([]byte(raw))
+			assert.NoError(t, err, raw)
+			assert.Equal(t, expected, body, raw)
+		}
+	})
+
+	t.Run("file with an empty file", func(t *testing.T) {
+		body, err := Parse([]byte(`{"type":"file","file_id":"f1","filename":"empty.txt","mime_type":"text/plain","url":"https://cdn.example.com/f1","size_in_bytes":0}`))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), *body.(*File).SizeInBytes)
+	})
+
+	t.Run("invalid bodies", func(t *testing.T) {
+		bodies := map[string]string{
+			`{"content":"hello"}`:                                                    ErrMissingType.Error(),
+			`{"type":"video"}`:                                                       ErrUnknownType.Error(),
+			`{"type":"system","event":"member_joined"}`:                              ErrSystemType.Error(),
+			`{"type":"text","content":"hello","color":"red"}`:                        `invalid text message: unknown field "color"`,
+			`{"type":"text","content":"   "}`:                                        "invalid text message: 'content' must not be blank",
+			`{"type":"file","file_id":"f1","filename":"a","mime_type":"text/plain"}`: "invalid file message: 'url' failed the 'required' rule",
+			`{"type":"image","url":"https://cdn.example.com/a.png","width":640}`:     "invalid image message: 'height' failed the 'required' rule",
+			`{"type":"poll","question":"Lunch?","options":["pizza"]}`:                "invalid poll message: 'options' failed the 'min' rule",
+			`{"type":"poll","question":"Lunch?","options":["pizza","pizza"]}`:        "invalid poll message: 'options' failed the 'unique' rule",
+			`{"type":"code","content":"package main"}`:                               "invalid code message: 'language' failed the 'required' rule",
+			`{"type":"text","content":1}`:                                            "invalid text message: cannot unmarshal number into Go struct field Text.content of type string",
+			`[1, 2]`:                                                                 "Invalid message body JSON",
+		}
+		for raw, message := range bodies {
+			_, err := Parse([]byte(raw))
+			if assert.Error(t, err, raw) {
+				assert.Equal(t, message, err.Error(), raw)
+			}
+		}
+	})
+}
+
+func TestDecode(t *testing.T) {
+	body, err := Decode([]byte(`{"type":"system","event":"member_joined","content":"Alice joined"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &System{Type: "system", Event: "member_joined", Content: "Alice joined"}, body)
+}
+
+func TestRegister(t *testing.T) {
+	assert.Panics(t, func() {
+		Register("text", func() Body { return &Text{} })
+	})
+	assert.Equal(t, []string{"code", "file", "image", "link", "poll", "system", "text"}, Types())
+}
+
+func TestScreenedFields(t *testing.T) {
+	assert.Equal(t, Fields{Text: []string{"title", "description"}, Links: []string{"url", "image_url"}}, ScreenedFields([]byte(`{"type":"link"}`)))
+	assert.Equal(t, Fields{Text: []string{"question", "options"}}, ScreenedFields([]byte(`{"type":"poll"}`)))
+	assert.Equal(t, Fields{}, ScreenedFields([]byte(`{"type":"unknown"}`)))
+}
+
+func TestIsSystem(t *testing.T) {
+	assert.True(t, IsSystem([]byte(`{"type":"system","event":"member_joined"}`)))
+	assert.False(t, IsSystem([]byte(`{"type":"text","content":"hi"}`)))
+	assert.False(t, IsSystem([]byte(`null`)))
+}
+
+func TestNormalize(t *testing.T) {
+	t.Run("re-encodes through the type", func(t *testing.T) {
+		raw := json.RawMessage(`{ "content": "hello",  "type": "text" }`)
+		assert.JSONEq(t, `{"type":"text","content":"hello"}`, string(Normalize(raw)))
+	})
+
+	t.Run("leaves undecodable bodies", func(t *testing.T) {
+		assert.Equal(t, json.RawMessage(`null`), Normalize(json.RawMessage(`null`)))
+		legacy := json.RawMessage(`{"type":"text","content":"hi","extra":true}`)
+		assert.Equal(t, legacy, Normalize(legacy))
+	})
+}
@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("text", func() Body { return &Text{} })
+	Register("file", func() Body { return &File{} })
+	Register("image", func() Body { return &Image{} })
+	Register("link", func() Body { return &Link{} })
+	Register("code", func() Body { return &Code{} })
+	Register("poll", func() Body { return &Poll{} })
+	RegisterSystem("system", func() Body { return &System{} })
+}
+
+type Text struct {
+	Type    string `json:"type"`
+	Content string `json:"content" validate:"required" screen:"text"`
+}
+
+func (t *Text) Validate() error {
+	if strings.TrimSpace(t.Content) == "" {
+		return errors.New("'content' must not be blank")
+	}
+	return nil
+}
+
+type File struct {
+	Type        string `json:"type"`
+	FileID      string `json:"file_id" validate:"required"`
+	Filename    string `json:"filename" validate:"required" screen:"text"`
+	MimeType    string `json:"mime_type" validate:"required"`
+	URL         string `json:"url" validate:"required,url" screen:"link"`
+	SizeInBytes *int64 `json:"size_in_bytes" validate:"required,min=0"`
+}
+
+func (f *File) Validate() error { return nil }
+
+type Image struct {
+	Type         string `json:"type"`
+	URL          string `json:"url" validate:"required,url" screen:"link"`
+	Width        int    `json:"width" validate:"required,min=1"`
+	Height       int    `json:"height" validate:"required,min=1"`
+	MimeType     string `json:"mime_type,omitempty" validate:"omitempty,startswith=image/"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty" validate:"omitempty,url" screen:"link"`
+	Caption      string `json:"caption,omitempty" validate:"max=1000" screen:"text"`
+}
+
+func (i *Image) Validate() error { return nil }
+
+// Link is a shared URL with the preview clients fetched for it
+type Link struct {
+	Type        string `json:"type"`
+	URL         string `json:"url" validate:"required,url" screen:"link"`
+	Title       string `json:"title,omitempty" validate:"max=300" screen:"text"`
+	Description string `json:"description,omitempty" validate:"max=1000" screen:"text"`
+	ImageURL    string `json:"image_url,omitempty" validate:"omitempty,url" screen:"link"`
+}
+
+func (l *Link) Validate() error { return nil }
+
+type Code struct {
+	Type     string `json:"type"`
+	Language string `json:"language" validate:"required,max=32"`
+	Content  string `json:"content" validate:"required" screen:"text"`
+}
+
+func (c *Code) Validate() error { return nil }
+
+// Poll takes votes until ClosesAt, if set
+type Poll struct {
+	Type           string     `json:"type"`
+	Question       string     `json:"question" validate:"required,max=300" screen:"text"`
+	Options        []string   `json:"options" validate:"min=2,max=10,unique,dive,required,max=100" screen:"text"`
+	MultipleChoice bool       `json:"multiple_choice"`
+	ClosesAt       *time.Time `json:"closes_at,omitempty"`
+}
+
+func (p *Poll) Validate() error { return nil }
+
+// Closed reports whether the poll stopped taking votes at now
+func (p *Poll) Closed(now time.Time) bool {
+	return p.ClosesAt != nil && !now.Before(*p.ClosesAt)
+}
+
+// System announces something that happened in the channel, like a member
+// joining. Clients cannot post it.
+type System struct {
+	Type    string `json:"type"`
+	Event   string `json:"event" validate:"required"`
+	Content string `json:"content,omitempty"`
+}
+
+func (s *System) Validate() error { return nil }
@@ -0,0 +1,84 @@
+// Package store holds the queries that several repositories run, often within
+// their own transactions, so that they do not depend on each other
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+)
+
+// Querier runs queries on the database or in a transaction
+type Querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type RowScanner interface {
+	Scan(dest ...any) error
+}
+
+// InsertSystemMessage stores a message posted by the server about userId,
+// e.g. them joining the channel. System messages cannot be edited, deleted or
+// reported, even by the user they are about.
+func InsertSystemMessage(q Querier, channelId int, userId int, msgBody []byte) (entities.Message, error) {
+	message := entities.Message{}
+	err := q.QueryRow("INSERT INTO messages (channel_id, user_id, body) VALUES ($1, $2, $3::jsonb) RETURNING id, user_id, channel_id, body, created_at", channelId, userId, msgBody).Scan(&message.ID, &message.UserID, &message.ChannelID, &message.Body, &message.CreatedAt)
+	return message, err
+}
+
+// DeleteMessage clears the body of a message and marks it deleted. It returns
+// sql.ErrNoRows if the message does not exist or was already deleted.
+func DeleteMessage(q Querier, messageId int) (entities.Message, error) {
+	message := entities.Message{}
+	err := q.QueryRow("UPDATE messages SET body = 'null'::jsonb, deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL RETURNING id, user_id, channel_id, body, created_at, edited_at, deleted_at", messageId).Scan(&message.ID, &message.UserID, &message.ChannelID, &message.Body, &message.CreatedAt, &message.EditedAt, &message.DeletedAt)
+	return message, err
+}
+
+// FetchFilterConfig returns the filters of a channel, none if they were never
+// set
+func FetchFilterConfig(q Querier, channelId int) (entities.FilterConfig, error) {
+	var raw []byte
+	err := q.QueryRow("SELECT config FROM channel_filters WHERE channel_id = $1", channelId).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return entities.FilterConfig{}, nil
+	}
+	if err != nil {
+		return entities.FilterConfig{}, err
+	}
+
+	config := entities.FilterConfig{}
+	err = json.Unmarshal(raw, &config)
+	return config, err
+}
+
+// ModerationColumns selects a moderation, read by ScanModeration
+const ModerationColumns = "mo.id, mo.channel_id, mo.user_id, mo.action, mo.reason, COALESCE(mo.actor_id, 0), mo.expires_at, mo.created_at, mo.revoked_at, mo.revoked_by"
+
+func ScanModeration(row RowScanner) (entities.Moderation, error) {
+	moderation := entities.Moderation{}
+	err := row.Scan(&moderation.ID, &moderation.ChannelID, &moderation.UserID, &moderation.Action, &moderation.Reason, &moderation.ActorID, &moderation.ExpiresAt, &moderation.CreatedAt, &moderation.RevokedAt, &moderation.RevokedBy)
+	if err != nil {
+		return entities.Moderation{}, err
+	}
+	return moderation, nil
+}
+
+// AddModeration records a moderation of userId by actorId within tx. Kicks
+// and bans remove the membership of userId, and a mute or ban replaces the
+// one of the same kind in force.
+func AddModeration(tx *sql.Tx, channelId int, userId int, actorId int, action string, reason string, expiresAt *time.Time) (entities.Moderation, error) {
+	if action == entities.ModerationKick || action == entities.ModerationBan {
+		if _, err := tx.Exec("DELETE FROM memberships WHERE channel_id = $1 AND user_id = $2", channelId, userId); err != nil {
+			return entities.Moderation{}, err
+		}
+	}
+	if action == entities.ModerationMute || action == entities.ModerationBan {
+		if _, err := tx.Exec("UPDATE channel_moderations SET revoked_at = NOW(), revoked_by = $3 WHERE channel_id = $1 AND user_id = $2 AND action = $4 AND revoked_at IS NULL", channelId, userId, actorId, action); err != nil {
+			return entities.Moderation{}, err
+		}
+	}
+
+	return ScanModeration(tx.QueryRow("INSERT INTO channel_moderations AS mo (channel_id, user_id, action, reason, actor_id, expires_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING "+ModerationColumns, channelId, userId, action, reason, actorId, expiresAt))
+}
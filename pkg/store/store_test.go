@@ -0,0 +1,97 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aramceballos/chat-group-server/pkg/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	return db, mock
+}
+
+func TestDeleteMessage(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE messages SET body = 'null'::jsonb, deleted_at = NOW\\(\\) WHERE id = \\$1 AND deleted_at IS NULL").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "channel_id", "body", "created_at", "edited_at", "deleted_at"}).
+				AddRow(5, 2, 1, []byte("null"), "2025-07-19T10:30:00Z", nil, "2025-07-19T11:00:00Z"))
+
+		message, err := DeleteMessage(db, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, message.ID)
+		assert.NotNil(t, message.DeletedAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already deleted", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE messages SET body").
+			WithArgs(5).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := DeleteMessage(db, 5)
+		assert.Equal(t, sql.ErrNoRows, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestFetchFilterConfig(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery("SELECT config FROM channel_filters WHERE channel_id = \\$1").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"config"}).AddRow([]byte(`{"words":{"words":["spam"],"patterns":[],"action":"reject"}}`)))
+
+		config, err := FetchFilterConfig(db, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"spam"}, config.Words.Words)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("never set", func(t *testing.T) {
+		mock.ExpectQuery("SELECT config FROM channel_filters").
+			WithArgs(1).
+			WillReturnError(sql.ErrNoRows)
+
+		config, err := FetchFilterConfig(db, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, entities.FilterConfig{}, config)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestAddModeration(t *testing.T) {
+	db, mock := setupMockDB(t)
+	defer db.Close()
+
+	t.Run("mute within the transaction", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE channel_moderations SET revoked_at = NOW\\(\\), revoked_by = \\$3").
+			WithArgs(1, 3, 2, entities.ModerationMute).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("INSERT INTO channel_moderations AS mo (.+) RETURNING mo.id").
+			WithArgs(1, 3, entities.ModerationMute, "spam", 2, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "channel_id", "user_id", "action", "reason", "actor_id", "expires_at", "created_at", "revoked_at", "revoked_by"}).
+				AddRow(4, 1, 3, entities.ModerationMute, "spam", 2, nil, "2025-07-19T10:30:00Z", nil, nil))
+		mock.ExpectCommit()
+
+		tx, err := db.Begin()
+		assert.NoError(t, err)
+		moderation, err := AddModeration(tx, 1, 3, 2, entities.ModerationMute, "spam", nil)
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Commit())
+		assert.Equal(t, 4, moderation.ID)
+		assert.Equal(t, entities.ModerationMute, moderation.Action)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}